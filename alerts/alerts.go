@@ -0,0 +1,287 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	// KindThreshold triggers when the base/quote rate crosses Threshold.
+	KindThreshold = "threshold"
+	// KindChange triggers when the base/quote rate moves more than
+	// Threshold percent from the previous publication. An empty quote
+	// matches every published currency.
+	KindChange = "change"
+
+	DirectionAbove = "above"
+	DirectionBelow = "below"
+	DirectionCross = "cross"
+)
+
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type Service struct {
+	Store   storage.AlertStore
+	H       *rakuten.Handler
+	Client  *http.Client
+	Retries RetryPolicy
+
+	// AllowPrivateNetworks lets webhooks reach loopback, link-local and
+	// private addresses, which are refused by default.
+	AllowPrivateNetworks bool
+
+	// Logger receives failed deliveries. It defaults to the global logger.
+	Logger *zap.Logger
+
+	// wake tells Run that Evaluate recorded deliveries
+	wake chan struct{}
+
+	// evaluated holds the publication dates Evaluate has evaluated, nil
+	// before its first call
+	evaluateMu sync.Mutex
+	evaluated  map[string]bool
+}
+
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func NewService(store storage.AlertStore, h *rakuten.Handler) *Service {
+	s := &Service{
+		Store: store,
+		H:     h,
+		Retries: RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+		},
+		wake: make(chan struct{}, 1),
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: s.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would dial on the webhook's behalf, past checkDial
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	s.Client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	return s
+}
+
+type CreateRuleRequest struct {
+	Kind       string `json:"kind"`
+	Base       string `json:"base"`
+	Quote      string `json:"quote"`
+	Direction  string `json:"direction"`
+	Threshold  string `json:"threshold"`
+	WebhookURL string `json:"webhook_url"`
+	Secret     string `json:"secret"`
+
+	// KeyID is the API key creating the rule, set by the router.
+	KeyID string `json:"-"`
+}
+
+type Rule struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Base       string    `json:"base"`
+	Quote      string    `json:"quote,omitempty"`
+	Direction  string    `json:"direction,omitempty"`
+	Threshold  string    `json:"threshold"`
+	WebhookURL string    `json:"webhook_url"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Delivery struct {
+	ID             string    `json:"id"`
+	RuleID         string    `json:"rule_id"`
+	Quote          string    `json:"quote"`
+	Date           string    `json:"date"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CreateRule validates and stores a rule. When no secret is given one is
+// generated; the secret is only ever returned from this call.
+func (s *Service) CreateRule(ctx context.Context, req *CreateRuleRequest) (*Rule, error) {
+	rule := storage.AlertRule{
+		Kind:       strings.ToLower(req.Kind),
+		Base:       strings.ToUpper(req.Base),
+		Quote:      strings.ToUpper(req.Quote),
+		Direction:  strings.ToLower(req.Direction),
+		Threshold:  req.Threshold,
+		WebhookURL: req.WebhookURL,
+		KeyID:      req.KeyID,
+		Secret:     req.Secret,
+	}
+	if rule.Base == "" {
+		rule.Base = "EUR"
+	}
+
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+	if u, _ := url.Parse(rule.WebhookURL); u != nil {
+		if err := s.checkWebhookHost(ctx, u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		rule.Secret = secret
+	}
+
+	id, err := s.Store.CreateAlertRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.ID = id
+	rule.CreatedAt = time.Now()
+	response := toRule(rule)
+	response.Secret = rule.Secret
+	return &response, nil
+}
+
+// GetRules returns the rules created by keyID, or every rule when keyID is
+// empty.
+func (s *Service) GetRules(ctx context.Context, keyID string) ([]Rule, error) {
+	rules, err := s.Store.GetAlertRules(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		response = append(response, toRule(rule))
+	}
+	return response, nil
+}
+
+// DeleteRule deletes a rule created by keyID, or any rule when keyID is
+// empty. Other keys' rules are not found.
+func (s *Service) DeleteRule(ctx context.Context, id, keyID string) error {
+	return s.Store.DeleteAlertRule(ctx, id, keyID)
+}
+
+// GetDeliveries returns the deliveries of a rule created by keyID, or of any
+// rule when keyID is empty. Other keys' rules are not found.
+func (s *Service) GetDeliveries(ctx context.Context, ruleID, keyID string) ([]Delivery, error) {
+	rule, err := s.Store.GetAlertRule(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != "" && rule.KeyID != keyID {
+		return nil, storage.ErrNotFound
+	}
+
+	deliveries, err := s.Store.GetAlertDeliveries(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]Delivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		response = append(response, Delivery{
+			ID:             d.ID,
+			RuleID:         d.RuleID,
+			Quote:          d.Quote,
+			Date:           d.Date.Format("2006-01-02"),
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			Error:          d.Error,
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+		})
+	}
+	return response, nil
+}
+
+func validateRule(rule storage.AlertRule) error {
+	switch rule.Kind {
+	case KindThreshold:
+		if rule.Quote == "" {
+			return errors.Wrap(ErrInvalidRule, "quote is required for threshold rules")
+		}
+		switch rule.Direction {
+		case DirectionAbove, DirectionBelow, DirectionCross:
+		default:
+			return errors.Wrap(ErrInvalidRule, "direction must be one of above, below or cross")
+		}
+	case KindChange:
+		if rule.Direction != "" {
+			return errors.Wrap(ErrInvalidRule, "direction is not supported for change rules")
+		}
+	default:
+		return errors.Wrap(ErrInvalidRule, "kind must be threshold or change")
+	}
+
+	if !currencyCode.MatchString(rule.Base) {
+		return errors.Wrap(ErrInvalidRule, "base must be a three letter currency code")
+	}
+	if rule.Quote != "" && !currencyCode.MatchString(rule.Quote) {
+		return errors.Wrap(ErrInvalidRule, "quote must be a three letter currency code")
+	}
+	if rule.Quote == rule.Base {
+		return errors.Wrap(ErrInvalidRule, "base and quote must differ")
+	}
+
+	threshold, ok := new(big.Rat).SetString(rule.Threshold)
+	if !ok || threshold.Sign() <= 0 {
+		return errors.Wrap(ErrInvalidRule, "threshold must be a positive number")
+	}
+
+	u, err := url.Parse(rule.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Wrap(ErrInvalidRule, "webhook_url must be an absolute http or https URL")
+	}
+
+	return nil
+}
+
+func toRule(rule storage.AlertRule) Rule {
+	return Rule{
+		ID:         rule.ID,
+		Kind:       rule.Kind,
+		Base:       rule.Base,
+		Quote:      rule.Quote,
+		Direction:  rule.Direction,
+		Threshold:  rule.Threshold,
+		WebhookURL: rule.WebhookURL,
+		CreatedAt:  rule.CreatedAt,
+	}
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate webhook secret")
+	}
+	return hex.EncodeToString(b), nil
+}
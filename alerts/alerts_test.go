@@ -0,0 +1,351 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestService_CreateRule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().CreateAlertRule(gAny, gAny).Return("rule-1", nil)
+
+	s := NewService(alertStore, nil)
+
+	_, err := s.CreateRule(context.Background(), &CreateRuleRequest{
+		Kind:       KindThreshold,
+		Base:       "USD",
+		Quote:      "JPY",
+		Direction:  "sideways",
+		Threshold:  "150",
+		WebhookURL: "http://example.com/hook",
+	})
+	if !errors.Is(err, ErrInvalidRule) {
+		t.Fatal("expected ErrInvalidRule")
+	}
+
+	rule, err := s.CreateRule(context.Background(), &CreateRuleRequest{
+		Kind:       KindChange,
+		Threshold:  "2",
+		WebhookURL: "http://example.com/hook",
+	})
+	if err != nil {
+		t.Fatal("unexpected err")
+	}
+	if rule.ID != "rule-1" || rule.Base != "EUR" || rule.Secret == "" {
+		t.Fatal("unexpected rule")
+	}
+
+	for _, webhookURL := range []string{
+		"ftp://example.com/hook",
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://10.1.2.3/hook",
+		"https://192.168.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := s.CreateRule(context.Background(), &CreateRuleRequest{
+			Kind:       KindChange,
+			Threshold:  "2",
+			WebhookURL: webhookURL,
+		})
+		if !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s: expected ErrInvalidRule, got %v", webhookURL, err)
+		}
+	}
+}
+
+func TestService_Evaluate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	previousDate := time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC)
+	latestDate := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	rakutenStore := mock_storage.NewMockRakutenStore(ctrl)
+	rakutenStore.EXPECT().GetCurrencyRates(gAny, gAny).DoAndReturn(func(_ context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+		if filter.GetLatestDate {
+			return []storage.Rate{
+				{Base: "EUR", Quote: "USD", Rate: "1", Date: latestDate},
+				{Base: "EUR", Quote: "JPY", Rate: "151", Date: latestDate},
+			}, nil
+		}
+		return []storage.Rate{
+			{Base: "EUR", Quote: "USD", Rate: "1", Date: previousDate},
+			{Base: "EUR", Quote: "JPY", Rate: "149", Date: previousDate},
+			{Base: "EUR", Quote: "USD", Rate: "1", Date: latestDate},
+			{Base: "EUR", Quote: "JPY", Rate: "151", Date: latestDate},
+		}, nil
+	}).Times(2)
+
+	rules := []storage.AlertRule{
+		{ID: "above", Kind: KindThreshold, Base: "USD", Quote: "JPY", Direction: DirectionAbove, Threshold: "150", Secret: "secret"},
+		{ID: "below", Kind: KindThreshold, Base: "USD", Quote: "JPY", Direction: DirectionBelow, Threshold: "150", Secret: "secret"},
+		{ID: "change", Kind: KindChange, Base: "EUR", Threshold: "5", Secret: "secret"},
+	}
+
+	for i := range rules {
+		rules[i].WebhookURL = "https://example.com/hook"
+	}
+
+	var recorded []storage.AlertDelivery
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().GetAlertRules(gAny, "").Return(rules, nil)
+	alertStore.EXPECT().CreateAlertDelivery(gAny, gAny).DoAndReturn(func(_ context.Context, d storage.AlertDelivery) (string, error) {
+		recorded = append(recorded, d)
+		return "delivery-1", nil
+	})
+
+	s := NewService(alertStore, rakuten.NewHandler(rakutenStore))
+
+	// only recorded, the worker sends it
+	if err := s.Evaluate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorded) != 1 || recorded[0].RuleID != "above" || recorded[0].Status != DeliveryPending || recorded[0].Attempts != 0 {
+		t.Fatalf("unexpected deliveries %+v", recorded)
+	}
+	payload := Payload{}
+	if err := json.Unmarshal([]byte(recorded[0].Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Rate != "151" || payload.PreviousRate != "149" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	select {
+	case <-s.wake:
+	default:
+		t.Fatal("expected the worker to be woken")
+	}
+}
+
+func TestService_Evaluate_Continues(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	rakutenStore := mock_storage.NewMockRakutenStore(ctrl)
+	rakutenStore.EXPECT().GetCurrencyRates(gAny, gAny).DoAndReturn(func(_ context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+		if filter.GetLatestDate {
+			return []storage.Rate{{Base: "EUR", Quote: "JPY", Rate: "160", Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)}}, nil
+		}
+		return []storage.Rate{
+			{Base: "EUR", Quote: "JPY", Rate: "140", Date: time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC)},
+			{Base: "EUR", Quote: "JPY", Rate: "160", Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)},
+		}, nil
+	}).Times(2)
+
+	rules := []storage.AlertRule{
+		{ID: "first", Kind: KindChange, Base: "EUR", Threshold: "5", WebhookURL: "https://example.com/hook"},
+		{ID: "second", Kind: KindChange, Base: "EUR", Threshold: "5", WebhookURL: "https://example.com/hook"},
+	}
+
+	var recorded []string
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().GetAlertRules(gAny, "").Return(rules, nil)
+	alertStore.EXPECT().CreateAlertDelivery(gAny, gAny).DoAndReturn(func(_ context.Context, d storage.AlertDelivery) (string, error) {
+		if d.RuleID == "first" {
+			return "", errors.New("database is locked")
+		}
+		recorded = append(recorded, d.RuleID)
+		return "delivery-2", nil
+	}).Times(2)
+
+	s := NewService(alertStore, rakuten.NewHandler(rakutenStore))
+	if err := s.Evaluate(context.Background()); err == nil {
+		t.Fatal("expected the failure to be returned")
+	}
+	if len(recorded) != 1 || recorded[0] != "second" {
+		t.Fatalf("expected the second rule to be recorded, got %v", recorded)
+	}
+}
+
+func TestService_Evaluate_EveryNewPublication(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	jpy := map[int]string{3: "140", 4: "141", 5: "150", 6: "160", 9: "161"}
+
+	// day 4 is the latest publication on the first call, days 5 and 6 are
+	// ingested together before the second
+	latest := 4
+	rakutenStore := mock_storage.NewMockRakutenStore(ctrl)
+	rakutenStore.EXPECT().GetCurrencyRates(gAny, gAny).DoAndReturn(func(_ context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+		if filter.GetLatestDate {
+			return []storage.Rate{{Base: "EUR", Quote: "JPY", Rate: jpy[latest], Date: day(latest)}}, nil
+		}
+		var rates []storage.Rate
+		for d := 3; d <= latest; d++ {
+			if rate, ok := jpy[d]; ok {
+				rates = append(rates, storage.Rate{Base: "EUR", Quote: "JPY", Rate: rate, Date: day(d)})
+			}
+		}
+		return rates, nil
+	}).AnyTimes()
+
+	rules := []storage.AlertRule{{ID: "change", Kind: KindChange, Base: "EUR", Threshold: "5", WebhookURL: "https://example.com/hook"}}
+
+	var recorded []Payload
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().GetAlertRules(gAny, "").Return(rules, nil).AnyTimes()
+	alertStore.EXPECT().CreateAlertDelivery(gAny, gAny).DoAndReturn(func(_ context.Context, d storage.AlertDelivery) (string, error) {
+		payload := Payload{}
+		if err := json.Unmarshal([]byte(d.Payload), &payload); err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, payload)
+		return "delivery", nil
+	}).AnyTimes()
+
+	s := NewService(alertStore, rakuten.NewHandler(rakutenStore))
+	if err := s.Evaluate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 0 {
+		t.Fatalf("expected no deliveries for day 4, got %+v", recorded)
+	}
+
+	latest = 6
+	if err := s.Evaluate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 ||
+		recorded[0].Date != "2023-01-05" || recorded[0].PreviousDate != "2023-01-04" ||
+		recorded[1].Date != "2023-01-06" || recorded[1].PreviousDate != "2023-01-05" {
+		t.Fatalf("expected days 5 and 6 to be compared with the day before, got %+v", recorded)
+	}
+
+	// nothing new, and day 9 moves too little
+	if err := s.Evaluate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	latest = 9
+	if err := s.Evaluate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("expected no more deliveries, got %+v", recorded)
+	}
+}
+
+func TestService_DeliverDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", body) {
+			t.Error("invalid signature")
+		}
+		if r.Header.Get(DeliveryHeader) != "delivery-1" {
+			t.Error("missing delivery id")
+		}
+	}))
+	defer receiver.Close()
+
+	rule := storage.AlertRule{ID: "above", WebhookURL: receiver.URL, Secret: "secret"}
+	delivery := storage.AlertDelivery{ID: "delivery-1", RuleID: "above", Payload: `{"rule_id":"above"}`, Status: DeliveryPending}
+
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().GetDueAlertDeliveries(gAny, gAny).DoAndReturn(func(context.Context, int) ([]storage.AlertDelivery, error) {
+		return []storage.AlertDelivery{delivery}, nil
+	}).Times(2)
+	alertStore.EXPECT().GetAlertRule(gAny, "above").Return(rule, nil).Times(2)
+	alertStore.EXPECT().UpdateAlertDelivery(gAny, gAny).DoAndReturn(func(_ context.Context, d storage.AlertDelivery) error {
+		delivery = d
+		return nil
+	}).Times(2)
+
+	s := NewService(alertStore, nil)
+	s.AllowPrivateNetworks = true
+
+	before := time.Now()
+	if err := s.DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusServiceUnavailable ||
+		delivery.NextAttemptAt.Before(before.Add(s.Retries.Backoff)) {
+		t.Fatalf("expected a retry to be scheduled, got %+v", delivery)
+	}
+
+	if err := s.DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 2 || delivery.Error != "" {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+}
+
+func TestService_DeliverDue_PrivateAddress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	received := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer receiver.Close()
+
+	var delivery storage.AlertDelivery
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().GetDueAlertDeliveries(gAny, gAny).Return([]storage.AlertDelivery{{ID: "delivery-1", RuleID: "above", Status: DeliveryPending}}, nil)
+	alertStore.EXPECT().GetAlertRule(gAny, "above").Return(storage.AlertRule{ID: "above", WebhookURL: receiver.URL}, nil)
+	alertStore.EXPECT().UpdateAlertDelivery(gAny, gAny).DoAndReturn(func(_ context.Context, d storage.AlertDelivery) error {
+		delivery = d
+		return nil
+	})
+
+	s := NewService(alertStore, nil)
+	if err := s.DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if received || delivery.Status != DeliveryFailed || !strings.Contains(delivery.Error, "not allowed") {
+		t.Fatalf("expected the loopback receiver to be refused, got %+v", delivery)
+	}
+}
+
+func TestService_GetDeliveries_OtherKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	alertStore := mock_storage.NewMockAlertStore(ctrl)
+	alertStore.EXPECT().GetAlertRule(gAny, "rule-1").Return(storage.AlertRule{ID: "rule-1", KeyID: "key-a"}, nil).Times(3)
+	alertStore.EXPECT().GetAlertDeliveries(gAny, "rule-1").Return(nil, nil).Times(2)
+
+	s := NewService(alertStore, nil)
+
+	if _, err := s.GetDeliveries(context.Background(), "rule-1", "key-b"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound for another key's rule", err)
+	}
+	for _, keyID := range []string{"key-a", ""} {
+		if _, err := s.GetDeliveries(context.Background(), "rule-1", keyID); err != nil {
+			t.Fatalf("unexpected err for key %q: %v", keyID, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package alerts
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// previousLookback is how far back Evaluate looks for publications it has
+// not evaluated yet and for the publication preceding each of them, enough
+// to span ECB holidays.
+const previousLookback = 14 * 24 * time.Hour
+
+type Payload struct {
+	RuleID        string `json:"rule_id"`
+	Kind          string `json:"kind"`
+	Base          string `json:"base"`
+	Quote         string `json:"quote"`
+	Direction     string `json:"direction,omitempty"`
+	Threshold     string `json:"threshold"`
+	Date          string `json:"date"`
+	Rate          string `json:"rate"`
+	PreviousDate  string `json:"previous_date"`
+	PreviousRate  string `json:"previous_rate"`
+	ChangePercent string `json:"change_percent"`
+}
+
+// Evaluate compares every publication stored since the previous call, up
+// to previousLookback before the latest one, with the publication before
+// it, and records a webhook delivery for every rule it triggers, which Run
+// sends. The first call only evaluates the latest publication. Each rule
+// fires at most once per quote and publication date, so evaluating a
+// publication again is a no-op. A rule that cannot be recorded does not
+// stop the others, its publication is evaluated again by the next call;
+// the first error is returned.
+func (s *Service) Evaluate(ctx context.Context) error {
+	s.evaluateMu.Lock()
+	defer s.evaluateMu.Unlock()
+
+	latest, err := s.H.GetCurrencyRate(ctx, &rakuten.GetCurrencyRateRequest{GetLatestDate: true})
+	if err != nil {
+		return err
+	}
+	if len(latest.Rates) == 0 {
+		return nil
+	}
+
+	history, err := s.H.GetCurrencyRateRange(ctx, &rakuten.GetCurrencyRateRangeRequest{
+		StartDate: latest.Date.Add(-previousLookback),
+		EndDate:   latest.Date,
+	})
+	if err != nil {
+		return err
+	}
+
+	var rules []storage.AlertRule
+	evaluated := make(map[string]bool, len(history))
+	var firstErr error
+	recorded := false
+	for i := range history {
+		date := history[i].Date.Format("2006-01-02")
+		switch {
+		case s.evaluated[date], i == 0:
+			// evaluated before, or nothing to compare with
+			evaluated[date] = true
+			continue
+		case s.evaluated == nil && i < len(history)-1:
+			evaluated[date] = true
+			continue
+		}
+
+		if rules == nil {
+			if rules, err = s.Store.GetAlertRules(ctx, ""); err != nil {
+				return err
+			}
+		}
+
+		failed := false
+		for _, rule := range rules {
+			for _, payload := range triggered(toRule(rule), &history[i-1], &history[i]) {
+				if err := s.enqueue(ctx, rule, payload); err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					failed = true
+					continue
+				}
+				recorded = true
+			}
+		}
+		evaluated[date] = !failed
+	}
+	s.evaluated = evaluated
+
+	if recorded && s.wake != nil {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return firstErr
+}
+
+func triggered(rule Rule, previous, latest *rakuten.CurrencyRatesResponse) []Payload {
+	threshold, ok := new(big.Rat).SetString(rule.Threshold)
+	if !ok {
+		return nil
+	}
+
+	quotes := []string{rule.Quote}
+	if rule.Quote == "" {
+		quotes = quotes[:0]
+		for quote := range latest.Rates {
+			if quote != rule.Base {
+				quotes = append(quotes, quote)
+			}
+		}
+		sort.Strings(quotes)
+	}
+
+	var payloads []Payload
+	for _, quote := range quotes {
+		prev, err := previous.CrossRate(rule.Base, quote)
+		if err != nil {
+			continue
+		}
+		cur, err := latest.CrossRate(rule.Base, quote)
+		if err != nil {
+			continue
+		}
+
+		change := new(big.Rat).Sub(cur, prev)
+		change.Quo(change, prev)
+		change.Mul(change, big.NewRat(100, 1))
+
+		fire := false
+		switch rule.Kind {
+		case KindThreshold:
+			up := prev.Cmp(threshold) < 0 && cur.Cmp(threshold) >= 0
+			down := prev.Cmp(threshold) > 0 && cur.Cmp(threshold) <= 0
+			fire = (up && rule.Direction != DirectionBelow) || (down && rule.Direction != DirectionAbove)
+		case KindChange:
+			fire = new(big.Rat).Abs(change).Cmp(threshold) > 0
+		}
+		if !fire {
+			continue
+		}
+
+		payloads = append(payloads, Payload{
+			RuleID:        rule.ID,
+			Kind:          rule.Kind,
+			Base:          rule.Base,
+			Quote:         quote,
+			Direction:     rule.Direction,
+			Threshold:     rule.Threshold,
+			Date:          latest.Date.Format("2006-01-02"),
+			Rate:          rakuten.FormatDecimal(cur),
+			PreviousDate:  previous.Date.Format("2006-01-02"),
+			PreviousRate:  rakuten.FormatDecimal(prev),
+			ChangePercent: rakuten.FormatDecimal(change),
+		})
+	}
+
+	return payloads
+}
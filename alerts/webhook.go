@@ -0,0 +1,258 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+
+	// SignatureHeader carries "sha256=" followed by the hex encoded
+	// HMAC-SHA256 of the request body keyed with the rule secret.
+	SignatureHeader = "X-Rakuten-Signature"
+	DeliveryHeader  = "X-Rakuten-Delivery"
+)
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+const (
+	// DefaultDeliveryInterval is how often Run looks for due deliveries
+	// besides when Evaluate records new ones.
+	DefaultDeliveryInterval = time.Second
+
+	// deliveryBatch is how many due deliveries DeliverDue reads at a time.
+	deliveryBatch = 100
+)
+
+// enqueue records the delivery of payload for the worker to send. A
+// delivery already recorded for the rule, quote and publication is left
+// as it is.
+func (s *Service) enqueue(ctx context.Context, rule storage.AlertRule, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal alert payload")
+	}
+	date, err := time.Parse("2006-01-02", payload.Date)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Store.CreateAlertDelivery(ctx, storage.AlertDelivery{
+		RuleID:  rule.ID,
+		Quote:   payload.Quote,
+		Date:    date,
+		Payload: string(body),
+		Status:  DeliveryPending,
+	})
+	return err
+}
+
+// Run delivers the pending deliveries every interval, and as soon as
+// Evaluate records new ones, until ctx is done.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	for {
+		if err := s.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			logging.With(ctx, s.Logger).Error("failed to deliver alerts", zap.Error(err))
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// DeliverDue makes one attempt at every pending delivery that is due,
+// retrying network errors, 429 and 5xx responses later with exponential
+// backoff until s.Retries.MaxAttempts. The delivery log is updated after
+// every attempt. Only storage errors are returned; a webhook that keeps
+// failing is logged and marked failed.
+func (s *Service) DeliverDue(ctx context.Context) error {
+	rules := map[string]*storage.AlertRule{}
+	for {
+		deliveries, err := s.Store.GetDueAlertDeliveries(ctx, deliveryBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			rule, ok := rules[delivery.RuleID]
+			if !ok {
+				r, err := s.Store.GetAlertRule(ctx, delivery.RuleID)
+				if err != nil && !errors.Is(err, storage.ErrNotFound) {
+					return err
+				}
+				if err == nil {
+					rule = &r
+				}
+				rules[delivery.RuleID] = rule
+			}
+			if rule == nil {
+				// deleted along with its deliveries meanwhile
+				continue
+			}
+
+			if err := s.attempt(ctx, *rule, delivery); err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < deliveryBatch {
+			return nil
+		}
+	}
+}
+
+// attempt POSTs delivery to the rule's webhook once and records the
+// outcome, scheduling the next attempt when it is to be retried.
+func (s *Service) attempt(ctx context.Context, rule storage.AlertRule, delivery storage.AlertDelivery) error {
+	delivery.Attempts++
+
+	retry := false
+	status, err := s.post(ctx, rule, delivery.ID, []byte(delivery.Payload))
+	if ctx.Err() != nil {
+		// interrupted by shutdown, the attempt does not count
+		return ctx.Err()
+	}
+	delivery.ResponseStatus = status
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+		retry = !errors.Is(err, errBlockedAddress)
+	case status >= 200 && status < 300:
+		delivery.Status = DeliveryDelivered
+		delivery.Error = ""
+	default:
+		delivery.Error = fmt.Sprintf("unexpected response status %d", status)
+		retry = status == http.StatusTooManyRequests || status >= 500
+	}
+
+	if delivery.Status != DeliveryDelivered {
+		if retry && delivery.Attempts < s.Retries.MaxAttempts {
+			delivery.NextAttemptAt = time.Now().Add(s.Retries.Backoff << (delivery.Attempts - 1))
+		} else {
+			delivery.Status = DeliveryFailed
+		}
+	}
+
+	if err := s.Store.UpdateAlertDelivery(ctx, delivery); err != nil {
+		return err
+	}
+
+	if delivery.Status == DeliveryFailed {
+		logging.With(ctx, s.Logger).Warn("alert delivery failed",
+			zap.String("delivery_id", delivery.ID),
+			zap.String("rule_id", rule.ID),
+			zap.Int("attempts", delivery.Attempts),
+			zap.String("error", delivery.Error),
+		)
+	}
+	return nil
+}
+
+func (s *Service) post(ctx context.Context, rule storage.AlertRule, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(rule.Secret, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// blockedIP reports whether webhooks must not reach ip: loopback,
+// link-local, private, shared and unspecified addresses would let rules
+// call internal services.
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// errBlockedAddress is returned when dialing an address blockedIP refuses.
+// Such deliveries are not retried.
+var errBlockedAddress = errors.New("address is not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// checkDial refuses connections to blocked addresses, whatever the
+// webhook host resolves to when it is called.
+func (s *Service) checkDial(network, address string, _ syscall.RawConn) error {
+	if s.AllowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+		return errors.Wrapf(errBlockedAddress, "webhook address %s", host)
+	}
+	return nil
+}
+
+// checkWebhookHost rejects webhook hosts that are, or resolve to, blocked
+// addresses. Names that do not resolve are left to checkDial.
+func (s *Service) checkWebhookHost(ctx context.Context, host string) error {
+	if s.AllowPrivateNetworks {
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errors.Wrap(ErrInvalidRule, "webhook_url must not point to a loopback, link-local or private address")
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil
+		}
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if blockedIP(ip) {
+			return errors.Wrap(ErrInvalidRule, "webhook_url must not point to a loopback, link-local or private address")
+		}
+	}
+	return nil
+}
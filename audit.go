@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// recordTimeout bounds writing an ingestion run to the audit log, which
+// happens once the command's context may already be done.
+const recordTimeout = 10 * time.Second
+
+// newRun starts the audit record of an ingestion of the feed at url by the
+// source command.
+func newRun(source, url string) storage.IngestionRun {
+	return storage.IngestionRun{Source: source, URL: url, StartedAt: time.Now()}
+}
+
+// recordRun completes run with the feed, which is nil when it could not be
+// loaded, the rows counted by result and the outcome err, and writes it to
+// the audit log. Failing to write it is only logged, so that it does not
+// fail the ingestion.
+func recordRun(store storage.IngestionStore, logger *zap.Logger, run storage.IngestionRun, feed *rakuten.Feed, result rakuten.IngestResult, err error) string {
+	run.FinishedAt = time.Now()
+	run.Inserted = result.Inserted
+	run.Updated = result.Updated
+	run.Rejected = result.Rejected
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	if feed != nil {
+		run.HTTPStatus = feed.StatusCode
+		run.FeedHash = feed.SHA256
+		if first, last := feed.Dates(); !first.IsZero() {
+			run.FirstDate, run.LastDate = &first, &last
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	id, recordErr := store.CreateIngestionRun(ctx, run)
+	if recordErr != nil {
+		logger.Error("failed to record ingestion run", zap.String("source", run.Source), zap.Error(recordErr))
+	}
+	return id
+}
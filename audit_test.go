@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/memory"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRecordRun_Correction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+	ctx := context.Background()
+
+	h := rakuten.NewHandler(memory.New())
+	if _, err := h.IngestCurrencyRates(ctx, rakuten.Rates{Rates: rakuten.RateList{
+		{Quote: "USD", Rate: "1.05", Date: "2023-01-04"},
+		{Quote: "JPY", Rate: "139.5", Date: "2023-01-04"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// corrects the USD rate of the 4th and publishes the 5th
+	result, err := h.IngestCurrencyRates(ctx, rakuten.Rates{Rates: rakuten.RateList{
+		{Quote: "USD", Rate: "1.06", Date: "2023-01-04"},
+		{Quote: "JPY", Rate: "139.5", Date: "2023-01-04"},
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+		{Quote: "JPY", Rate: "140", Date: "2023-01-05"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recorded storage.IngestionRun
+	runs := mock_storage.NewMockIngestionStore(ctrl)
+	runs.EXPECT().CreateIngestionRun(gAny, gAny).DoAndReturn(func(_ context.Context, run storage.IngestionRun) (string, error) {
+		recorded = run
+		return "run-1", nil
+	})
+
+	recordRun(runs, zap.NewNop(), newRun("ingest", "testdata"), nil, result, nil)
+	if recorded.Inserted != 2 || recorded.Updated != 1 || recorded.Rejected != 0 || recorded.Error != "" {
+		t.Fatalf("expected 2 rows inserted and 1 updated, got %+v", recorded)
+	}
+}
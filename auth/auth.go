@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	// ScopeRatesRead grants access to the rate, analysis, stream and
+	// GraphQL endpoints.
+	ScopeRatesRead = "rates:read"
+	// ScopeAlerts grants access to the /alerts endpoints.
+	ScopeAlerts = "alerts"
+	// ScopeAdmin grants access to everything, including /admin.
+	ScopeAdmin = "admin"
+
+	// QuotaBlock rejects requests once the monthly quota is used up,
+	// QuotaWarn only flags them.
+	QuotaBlock = "block"
+	QuotaWarn  = "warn"
+
+	keyPrefix = "rk_"
+
+	DefaultRateLimit = 60
+)
+
+var (
+	ErrInvalidKey     = errors.New("invalid api key")
+	ErrInvalidRequest = errors.New("invalid api key request")
+)
+
+var knownScopes = map[string]bool{
+	ScopeRatesRead: true,
+	ScopeAlerts:    true,
+	ScopeAdmin:     true,
+}
+
+// reservedNames are the names of the bootstrap admin key and of the maker
+// of ingested changes, which issued keys may not take.
+var reservedNames = map[string]bool{
+	"admin":     true,
+	"ingestion": true,
+}
+
+// Key is an authenticated API key. A zero RateLimit or MonthlyQuota means
+// unlimited.
+type Key struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	RateLimit    int        `json:"rate_limit"`
+	Burst        int        `json:"burst"`
+	MonthlyQuota int        `json:"monthly_quota"`
+	QuotaMode    string     `json:"quota_mode"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type Service struct {
+	Store   storage.APIKeyStore
+	Limiter *Limiter
+
+	// AdminKey, when set, is accepted as an unlimited key with the admin
+	// scope so that the first real keys can be issued.
+	AdminKey string
+
+	// Logger defaults to the global logger.
+	Logger *zap.Logger
+}
+
+func NewService(store storage.APIKeyStore, adminKey string) *Service {
+	return &Service{
+		Store:    store,
+		Limiter:  NewLimiter(),
+		AdminKey: adminKey,
+	}
+}
+
+type IssueKeyRequest struct {
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	RateLimit    int      `json:"rate_limit"`
+	Burst        int      `json:"burst"`
+	MonthlyQuota int      `json:"monthly_quota"`
+	QuotaMode    string   `json:"quota_mode"`
+}
+
+type IssuedKey struct {
+	Key
+	// Secret is the full API key. It is not stored and cannot be
+	// retrieved again.
+	Secret string `json:"key"`
+}
+
+// IssueKey creates a key allowed RateLimit requests per minute with bursts
+// of up to Burst requests. Only the SHA-256 hash of the key is stored.
+func (s *Service) IssueKey(ctx context.Context, req *IssueKeyRequest) (*IssuedKey, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.Wrap(ErrInvalidRequest, "name is required")
+	}
+	if reservedNames[strings.ToLower(strings.TrimSpace(req.Name))] {
+		return nil, errors.Wrapf(ErrInvalidRequest, "name %q is reserved", req.Name)
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+	if req.RateLimit < 0 || req.Burst < 0 || req.MonthlyQuota < 0 {
+		return nil, errors.Wrap(ErrInvalidRequest, "rate_limit, burst and monthly_quota must not be negative")
+	}
+
+	quotaMode := req.QuotaMode
+	switch quotaMode {
+	case "":
+		quotaMode = QuotaBlock
+	case QuotaBlock, QuotaWarn:
+	default:
+		return nil, errors.Wrap(ErrInvalidRequest, "quota_mode must be block or warn")
+	}
+
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = DefaultRateLimit
+	}
+	burst := req.Burst
+	if burst == 0 {
+		burst = rateLimit
+	}
+
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "failed to generate api key")
+	}
+	secret := keyPrefix + hex.EncodeToString(b)
+
+	key := storage.APIKey{
+		Name:         req.Name,
+		Prefix:       secret[:len(keyPrefix)+8],
+		Hash:         hashKey(secret),
+		Scopes:       req.Scopes,
+		RateLimit:    rateLimit,
+		Burst:        burst,
+		MonthlyQuota: req.MonthlyQuota,
+		QuotaMode:    quotaMode,
+	}
+
+	id, err := s.Store.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = id
+	key.CreatedAt = time.Now()
+	return &IssuedKey{Key: toKey(key), Secret: secret}, nil
+}
+
+func (s *Service) GetKeys(ctx context.Context) ([]Key, error) {
+	keys, err := s.Store.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, toKey(key))
+	}
+	return response, nil
+}
+
+func (s *Service) UpdateScopes(ctx context.Context, id string, scopes []string) error {
+	if err := validateScopes(scopes); err != nil {
+		return err
+	}
+	return s.Store.UpdateAPIKeyScopes(ctx, id, scopes)
+}
+
+func (s *Service) RevokeKey(ctx context.Context, id string) error {
+	return s.Store.RevokeAPIKey(ctx, id)
+}
+
+// Authenticate resolves a raw API key to a Key, returning ErrInvalidKey for
+// unknown or revoked keys.
+func (s *Service) Authenticate(ctx context.Context, secret string) (*Key, error) {
+	if s.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.AdminKey)) == 1 {
+		return &Key{ID: "admin", Name: "admin", Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := s.Store.GetAPIKeyByHash(ctx, hashKey(secret))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := toKey(key)
+	return &k, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return errors.Wrapf(ErrInvalidRequest, "unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func toKey(key storage.APIKey) Key {
+	return Key{
+		ID:           key.ID,
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		Scopes:       key.Scopes,
+		RateLimit:    key.RateLimit,
+		Burst:        key.Burst,
+		MonthlyQuota: key.MonthlyQuota,
+		QuotaMode:    key.QuotaMode,
+		CreatedAt:    key.CreatedAt,
+		RevokedAt:    key.RevokedAt,
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestService_Require(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	secret := "rk_0123456789abcdef0123456789abcdef01234567"
+
+	keyStore := mock_storage.NewMockAPIKeyStore(ctrl)
+	keyStore.EXPECT().GetAPIKeyByHash(gAny, hashKey(secret)).Return(storage.APIKey{
+		ID:        "key-1",
+		Scopes:    []string{ScopeRatesRead},
+		RateLimit: 60,
+		Burst:     2,
+	}, nil).AnyTimes()
+	keyStore.EXPECT().GetAPIKeyByHash(gAny, gAny).Return(storage.APIKey{}, storage.ErrNotFound).AnyTimes()
+
+	s := NewService(keyStore, "bootstrap")
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := KeyFromContext(r.Context()); !ok {
+			t.Error("expected key in context")
+		}
+	}
+
+	serve := func(scope, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/rates/latest", nil)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		s.Require(scope, handler)(w, r)
+		return w
+	}
+
+	if w := serve(ScopeRatesRead, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without key, got %d", w.Code)
+	}
+	if w := serve(ScopeRatesRead, "rk_unknown"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown key, got %d", w.Code)
+	}
+	if w := serve(ScopeAdmin, secret); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for missing scope, got %d", w.Code)
+	}
+	if w := serve(ScopeAdmin, "bootstrap"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for admin key, got %d", w.Code)
+	}
+
+	for _, remaining := range []string{"1", "0"} {
+		w := serve(ScopeRatesRead, secret)
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != remaining {
+			t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+		}
+	}
+
+	w := serve(ScopeRatesRead, secret)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+
+	key := &Key{ID: "key-1", RateLimit: 60, Burst: 1}
+
+	if !l.Allow(key).Allowed {
+		t.Fatal("expected first request to be allowed")
+	}
+
+	d := l.Allow(key)
+	if d.Allowed || d.RetryAfter != time.Second {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow(key).Allowed {
+		t.Fatal("expected refilled bucket to allow request")
+	}
+}
+
+func TestService_IssueKey_ReservedName(t *testing.T) {
+	s := NewService(nil, "bootstrap")
+
+	for _, name := range []string{"admin", "ingestion", " Ingestion "} {
+		_, err := s.IssueKey(context.Background(), &IssueKeyRequest{Name: name, Scopes: []string{ScopeAdmin}})
+		if !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("expected ErrInvalidRequest for %q, got %v", name, err)
+		}
+	}
+}
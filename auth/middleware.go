@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/logging"
+)
+
+type contextKey struct{}
+
+// KeyFromContext returns the key that authenticated the request, if any.
+func KeyFromContext(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(contextKey{}).(*Key)
+	return key, ok
+}
+
+// Require wraps next so that it is only served to requests carrying a key
+// with scope, via "Authorization: Bearer <key>" or "X-API-Key: <key>",
+// that is within its rate limit. RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers are set on every rate limited response.
+func (s *Service) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-API-Key")
+		if secret == "" {
+			secret = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if secret == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rakuten"`)
+			writeError(w, http.StatusUnauthorized, "api key required")
+			return
+		}
+
+		key, err := s.Authenticate(r.Context(), secret)
+		if err == ErrInvalidKey {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rakuten", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "invalid api key")
+			return
+		}
+		if err != nil {
+			logging.With(r.Context(), s.Logger).Error("failed to authenticate api key", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		if !key.HasScope(scope) {
+			writeError(w, http.StatusForbidden, "api key lacks the "+scope+" scope")
+			return
+		}
+
+		d := s.Limiter.Allow(key)
+		if d.Limit > 0 {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(d.Reset))
+		}
+		if !d.Allowed {
+			w.Header().Set("Retry-After", ceilSeconds(d.RetryAfter))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		next(w, r.WithContext(NewContext(r.Context(), key)))
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	response, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		// shouldn't happen
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// NewContext returns a copy of ctx carrying key, as Require does for
+// authenticated requests.
+func NewContext(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter keeps one token bucket per API key. Buckets refill at
+// Key.RateLimit tokens per minute up to Key.Burst tokens.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request would be allowed.
+	RetryAfter time.Duration
+}
+
+// Allow takes a token from key's bucket if one is available.
+func (l *Limiter) Allow(key *Key) Decision {
+	if key.RateLimit <= 0 {
+		return Decision{Allowed: true}
+	}
+
+	burst := float64(key.Burst)
+	if burst <= 0 {
+		burst = float64(key.RateLimit)
+	}
+	perSecond := float64(key.RateLimit) / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key.ID]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key.ID] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	d := Decision{Limit: int(burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / perSecond)
+	}
+
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((burst - b.tokens) / perSecond)
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
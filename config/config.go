@@ -0,0 +1,507 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/syahnur197/rakuten/health"
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+// FileEnv names the environment variable holding the config file path when
+// the -config flag is not given.
+const FileEnv = "RAKUTEN_CONFIG"
+
+const redacted = "[redacted]"
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type Config struct {
+	HTTP     HTTPConfig     `yaml:"http"`
+	DB       DBConfig       `yaml:"db"`
+	ECB      ECBConfig      `yaml:"ecb"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Health   HealthConfig   `yaml:"health"`
+	Approval ApprovalConfig `yaml:"approval"`
+	Quality  QualityConfig  `yaml:"quality"`
+	Log      LogConfig      `yaml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+}
+
+type HTTPConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DBConfig struct {
+	// Driver is "postgres" or "sqlite". SQLite keeps everything in the
+	// file at Path and ignores the connection settings.
+	Driver string `yaml:"driver"`
+	Path   string `yaml:"path"`
+
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+
+	// ConnectTimeout bounds how long startup waits for the database.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+
+	// PasswordFile, when set, is read for the password instead, e.g. a
+	// Docker or Kubernetes secret.
+	PasswordFile string `yaml:"password_file"`
+}
+
+type ECBConfig struct {
+	URL            string        `yaml:"url"`
+	IngestInterval time.Duration `yaml:"ingest_interval"`
+}
+
+type AuthConfig struct {
+	AdminAPIKey     string `yaml:"admin_api_key"`
+	AdminAPIKeyFile string `yaml:"admin_api_key_file"`
+}
+
+type HealthConfig struct {
+	MaxMissedPublications int           `yaml:"max_missed_publications"`
+	MaxIngestionAge       time.Duration `yaml:"max_ingestion_age"`
+}
+
+type ApprovalConfig struct {
+	// Required holds every ingested publication and rate override until a
+	// second admin approves it.
+	Required bool `yaml:"required"`
+}
+
+type QualityConfig struct {
+	// Rules is a comma-separated list of the data quality rules applied to
+	// ingested rates, "all" or "none".
+	Rules      string  `yaml:"rules"`
+	MaxJump    float64 `yaml:"max_jump"`
+	JumpWindow int     `yaml:"jump_window"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+type TracingConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+// Default returns the configuration used for every setting that is not
+// given, which matches the docker-compose setup.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:            ":4000",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    time.Minute,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		DB: DBConfig{
+			Driver: DriverPostgres,
+			Path:   "rakuten.db",
+
+			Host:     "localhost",
+			Port:     5555,
+			User:     "rakuten",
+			Password: "rakuten",
+			Name:     "rakuten",
+			SSLMode:  "disable",
+
+			ConnectTimeout: time.Minute,
+		},
+		ECB: ECBConfig{
+			URL:            rakuten.DefaultECBURL,
+			IngestInterval: time.Hour,
+		},
+		Health: HealthConfig{
+			MaxMissedPublications: health.DefaultMaxMissedPublications,
+			MaxIngestionAge:       health.DefaultMaxIngestionAge,
+		},
+		Quality: QualityConfig{
+			Rules:      "all",
+			MaxJump:    rakuten.DefaultMaxJump,
+			JumpWindow: rakuten.DefaultJumpWindow,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// option is a setting that can be overridden by an environment variable
+// and a flag.
+type option struct {
+	flag  string
+	env   string
+	usage string
+	value interface{} // *string, *int, *float64, *bool or *time.Duration
+
+	// secret options have their default hidden from -help
+	secret bool
+}
+
+func (c *Config) options() []option {
+	return []option{
+		{"http-addr", "HTTP_ADDR", "listen address", &c.HTTP.Addr, false},
+		{"http-read-timeout", "HTTP_READ_TIMEOUT", "time to read a request, body included", &c.HTTP.ReadTimeout, false},
+		{"http-write-timeout", "HTTP_WRITE_TIMEOUT", "time to write a response", &c.HTTP.WriteTimeout, false},
+		{"http-idle-timeout", "HTTP_IDLE_TIMEOUT", "keep-alive timeout", &c.HTTP.IdleTimeout, false},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "deadline to drain connections on shutdown", &c.HTTP.ShutdownTimeout, false},
+		{"db-driver", "DB_DRIVER", "database driver, postgres or sqlite", &c.DB.Driver, false},
+		{"db-path", "DB_PATH", "SQLite database file", &c.DB.Path, false},
+		{"db-host", "DB_HOST", "database host", &c.DB.Host, false},
+		{"db-port", "DB_PORT", "database port", &c.DB.Port, false},
+		{"db-user", "DB_USER", "database user", &c.DB.User, false},
+		{"db-password", "DB_PASSWORD", "database password", &c.DB.Password, true},
+		{"db-password-file", "DB_PASSWORD_FILE", "file to read the database password from", &c.DB.PasswordFile, false},
+		{"db-name", "DB_NAME", "database name", &c.DB.Name, false},
+		{"db-sslmode", "DB_SSLMODE", "database sslmode", &c.DB.SSLMode, false},
+		{"db-connect-timeout", "DB_CONNECT_TIMEOUT", "how long startup waits for the database", &c.DB.ConnectTimeout, false},
+		{"ecb-url", "ECB_URL", "ECB reference rates feed", &c.ECB.URL, false},
+		{"ingest-interval", "INGEST_INTERVAL", "how often the ECB feed is fetched", &c.ECB.IngestInterval, false},
+		{"admin-api-key", "ADMIN_API_KEY", "bootstrap admin api key", &c.Auth.AdminAPIKey, true},
+		{"admin-api-key-file", "ADMIN_API_KEY_FILE", "file to read the bootstrap admin api key from", &c.Auth.AdminAPIKeyFile, false},
+		{"health-max-missed-publications", "HEALTH_MAX_MISSED_PUBLICATIONS", "missing ECB publications before /status fails", &c.Health.MaxMissedPublications, false},
+		{"health-max-ingestion-age", "HEALTH_MAX_INGESTION_AGE", "time since the last successful ingestion before /status fails", &c.Health.MaxIngestionAge, false},
+		{"require-approval", "REQUIRE_APPROVAL", "hold ingested rates and overrides until approved at /admin/approvals", &c.Approval.Required, false},
+		{"quality-rules", "QUALITY_RULES", "data quality rules applied to ingested rates, comma-separated, all or none", &c.Quality.Rules, false},
+		{"quality-max-jump", "QUALITY_MAX_JUMP", "standard deviations a day-over-day change may deviate from the mean", &c.Quality.MaxJump, false},
+		{"quality-jump-window", "QUALITY_JUMP_WINDOW", "previous day-over-day changes the jump rule compares with", &c.Quality.JumpWindow, false},
+		{"log-level", "LOG_LEVEL", "debug, info, warn or error", &c.Log.Level, false},
+		{"tracing-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "URL of an OTLP/HTTP collector, e.g. http://localhost:4318", &c.Tracing.Endpoint, false},
+		{"tracing-insecure", "OTEL_EXPORTER_OTLP_INSECURE", "export traces over plain HTTP", &c.Tracing.Insecure, false},
+	}
+}
+
+// Loader registers the configuration flags on a flag set, so that commands
+// can add their own, and loads the configuration once it is parsed.
+type Loader struct {
+	path  *string
+	flags map[string]string
+}
+
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{flags: map[string]string{}}
+	l.path = fs.String("config", "", "YAML config file (env "+FileEnv+")")
+
+	for _, o := range Default().options() {
+		fs.Var(&flagValue{o: o, values: l.flags}, o.flag, fmt.Sprintf("%s (env %s)", o.usage, o.env))
+	}
+	return l
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the YAML file given by -config or RAKUTEN_CONFIG, the
+// environment and the parsed flags. Secrets are then read from their
+// files and the result is validated.
+func (l *Loader) Load(getenv func(string) string) (*Config, error) {
+	c := Default()
+	options := c.options()
+
+	path := *l.path
+	if path == "" {
+		path = getenv(FileEnv)
+	}
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, o := range options {
+		if v := getenv(o.env); v != "" {
+			if err := set(o.value, v); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", o.env)
+			}
+		}
+	}
+
+	for _, o := range options {
+		if v, ok := l.flags[o.flag]; ok {
+			if err := set(o.value, v); err != nil {
+				return nil, errors.Wrapf(err, "invalid -%s", o.flag)
+			}
+		}
+	}
+
+	if err := c.readSecrets(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load parses args, which may only hold configuration flags, and loads the
+// configuration as Loader.Load does.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet("rakuten", flag.ContinueOnError)
+	l := NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, errors.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return l.Load(getenv)
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open config file")
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return errors.Wrapf(err, "failed to parse config file %s", path)
+	}
+	return nil
+}
+
+func (c *Config) readSecrets() error {
+	secrets := []struct {
+		path  string
+		value *string
+	}{
+		{c.DB.PasswordFile, &c.DB.Password},
+		{c.Auth.AdminAPIKeyFile, &c.Auth.AdminAPIKey},
+	}
+
+	for _, s := range secrets {
+		if s.path == "" {
+			continue
+		}
+		b, err := os.ReadFile(s.path)
+		if err != nil {
+			return errors.Wrap(err, "failed to read secret")
+		}
+		*s.value = strings.TrimRight(string(b), "\r\n")
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.HTTP.Addr)
+	check(err == nil, "http.addr %q must be host:port", c.HTTP.Addr)
+	check(c.HTTP.ReadTimeout >= 0, "http.read_timeout must not be negative")
+	check(c.HTTP.WriteTimeout >= 0, "http.write_timeout must not be negative")
+	check(c.HTTP.IdleTimeout >= 0, "http.idle_timeout must not be negative")
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+
+	switch c.DB.Driver {
+	case DriverPostgres:
+		check(c.DB.Host != "", "db.host is required")
+		check(c.DB.Port > 0 && c.DB.Port < 65536, "db.port %d is out of range", c.DB.Port)
+		check(c.DB.User != "", "db.user is required")
+		check(c.DB.Name != "", "db.name is required")
+		check(c.DB.ConnectTimeout > 0, "db.connect_timeout must be positive")
+		switch c.DB.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "db.sslmode %q is not a postgres sslmode", c.DB.SSLMode)
+		}
+	case DriverSQLite:
+		check(c.DB.Path != "", "db.path is required")
+	default:
+		check(false, "db.driver %q must be %s or %s", c.DB.Driver, DriverPostgres, DriverSQLite)
+	}
+
+	u, err := url.Parse(c.ECB.URL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "ecb.url %q must be an http(s) URL", c.ECB.URL)
+	check(c.ECB.IngestInterval >= time.Minute, "ecb.ingest_interval must be at least 1m")
+
+	check(c.Health.MaxMissedPublications >= 0, "health.max_missed_publications must not be negative")
+	check(c.Health.MaxIngestionAge > 0, "health.max_ingestion_age must be positive")
+
+	_, err = c.Quality.rules()
+	check(err == nil, "quality.rules: %v", err)
+	check(c.Quality.MaxJump > 0, "quality.max_jump must be positive")
+	check(c.Quality.JumpWindow >= 2, "quality.jump_window must be at least 2")
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level %q must be debug, info, warn or error", c.Log.Level)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DSN returns the lib/pq connection string for c.
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(c.Host), c.Port, quote(c.User), quote(c.Password), quote(c.Name), quote(c.SSLMode))
+}
+
+// quote quotes a libpq keyword value.
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+	return "'" + s + "'"
+}
+
+// Checks returns the data quality checks to apply to ingested rates, or nil
+// when Rules is "none".
+func (c QualityConfig) Checks() *rakuten.QualityChecks {
+	rules, err := c.rules()
+	if err != nil || len(rules) == 0 {
+		return nil
+	}
+	checks := rakuten.NewQualityChecks(rules...)
+	checks.MaxJump, checks.JumpWindow = c.MaxJump, c.JumpWindow
+	return checks
+}
+
+func (c QualityConfig) rules() ([]string, error) {
+	switch strings.TrimSpace(c.Rules) {
+	case "all":
+		return rakuten.QualityRules, nil
+	case "none", "":
+		return nil, nil
+	}
+
+	known := map[string]bool{}
+	for _, rule := range rakuten.QualityRules {
+		known[rule] = true
+	}
+	var rules []string
+	for _, rule := range strings.Split(c.Rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if !known[rule] {
+			return nil, errors.Errorf("unknown rule %q, must be one of %s", rule, strings.Join(rakuten.QualityRules, ", "))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Redacted returns a copy of c with its secrets replaced, safe to print.
+func (c Config) Redacted() Config {
+	if c.DB.Password != "" {
+		c.DB.Password = redacted
+	}
+	if c.Auth.AdminAPIKey != "" {
+		c.Auth.AdminAPIKey = redacted
+	}
+	return c
+}
+
+// Print writes c as YAML with its secrets redacted.
+func Print(w io.Writer, c *Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func set(value interface{}, s string) error {
+	switch v := value.(type) {
+	case *string:
+		*v = s
+	case *int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*v = n
+	case *float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*v = f
+	case *bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*v = d
+	default:
+		panic(fmt.Sprintf("unsupported option type %T", value))
+	}
+	return nil
+}
+
+// flagValue records a flag for Load to apply after the file and the
+// environment, so that flags take precedence over both.
+type flagValue struct {
+	o      option
+	values map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f.o.value == nil {
+		return ""
+	}
+	switch v := f.o.value.(type) {
+	case *string:
+		if f.o.secret {
+			return ""
+		}
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *float64:
+		return strconv.FormatFloat(*v, 'g', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *time.Duration:
+		return v.String()
+	}
+	return ""
+}
+
+func (f *flagValue) Set(s string) error {
+	f.values[f.o.flag] = s
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	_, ok := f.o.value.(*bool)
+	return ok
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "rakuten.yaml", `
+http:
+  addr: ":5000"
+  write_timeout: 2m
+db:
+  host: file-host
+  port: 5432
+log:
+  level: warn
+`)
+
+	c, err := Load([]string{"-config", path, "-db-host", "flag-host"}, env(map[string]string{
+		"DB_HOST":   "env-host",
+		"DB_PORT":   "6543",
+		"LOG_LEVEL": "debug",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.HTTP.Addr != ":5000" || c.HTTP.WriteTimeout != 2*time.Minute {
+		t.Errorf("file settings not applied: %+v", c.HTTP)
+	}
+	if c.HTTP.ReadTimeout != Default().HTTP.ReadTimeout {
+		t.Errorf("got read timeout %s, want the default", c.HTTP.ReadTimeout)
+	}
+	if c.DB.Port != 6543 || c.Log.Level != "debug" {
+		t.Errorf("environment does not override the file: port %d, level %s", c.DB.Port, c.Log.Level)
+	}
+	if c.DB.Host != "flag-host" {
+		t.Errorf("got host %s, want the flag to win", c.DB.Host)
+	}
+}
+
+func TestLoad_FileFromEnv(t *testing.T) {
+	path := writeFile(t, "rakuten.yaml", "ecb:\n  ingest_interval: 30m\n")
+
+	c, err := Load(nil, env(map[string]string{FileEnv: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ECB.IngestInterval != 30*time.Minute {
+		t.Errorf("got ingest interval %s, want 30m", c.ECB.IngestInterval)
+	}
+}
+
+func TestLoad_UnknownFileKey(t *testing.T) {
+	path := writeFile(t, "rakuten.yaml", "db:\n  hots: typo\n")
+
+	if _, err := Load([]string{"-config", path}, env(nil)); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	password := writeFile(t, "db_password", "s3cret\n")
+	adminKey := writeFile(t, "admin_api_key", "admin-key")
+
+	c, err := Load([]string{"-admin-api-key-file", adminKey}, env(map[string]string{
+		"DB_PASSWORD":      "ignored",
+		"DB_PASSWORD_FILE": password,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DB.Password != "s3cret" || c.Auth.AdminAPIKey != "admin-key" {
+		t.Errorf("got password %q and admin key %q", c.DB.Password, c.Auth.AdminAPIKey)
+	}
+
+	if _, err := Load(nil, env(map[string]string{"DB_PASSWORD_FILE": filepath.Join(t.TempDir(), "missing")})); err == nil {
+		t.Error("expected an error for a missing secret file")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{name: "bad duration", env: map[string]string{"HTTP_READ_TIMEOUT": "10"}, want: "HTTP_READ_TIMEOUT"},
+		{name: "bad port flag", args: []string{"-db-port", "x"}, want: "-db-port"},
+		{name: "port out of range", env: map[string]string{"DB_PORT": "70000"}, want: "db.port"},
+		{name: "addr", args: []string{"-http-addr", "4000"}, want: "http.addr"},
+		{name: "ecb url", env: map[string]string{"ECB_URL": "ftp://example.com"}, want: "ecb.url"},
+		{name: "ingest interval", args: []string{"-ingest-interval", "1s"}, want: "ecb.ingest_interval"},
+		{name: "sslmode", env: map[string]string{"DB_SSLMODE": "on"}, want: "db.sslmode"},
+		{name: "driver", env: map[string]string{"DB_DRIVER": "mysql"}, want: "db.driver"},
+		{name: "sqlite path", args: []string{"-db-driver", "sqlite", "-db-path", ""}, want: "db.path"},
+		{name: "quality rule", env: map[string]string{"QUALITY_RULES": "jump,spike"}, want: "quality.rules"},
+		{name: "max jump", args: []string{"-quality-max-jump", "0"}, want: "quality.max_jump"},
+		{name: "bad max jump", env: map[string]string{"QUALITY_MAX_JUMP": "ten"}, want: "QUALITY_MAX_JUMP"},
+		{name: "argument", args: []string{"serve"}, want: "unexpected argument"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, env(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one mentioning %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_SQLite(t *testing.T) {
+	c, err := Load([]string{"-db-driver", "sqlite"}, env(map[string]string{"DB_HOST": "", "DB_SSLMODE": "on"}))
+	if err != nil {
+		t.Fatalf("postgres settings should be ignored for sqlite: %v", err)
+	}
+	if c.DB.Path != "rakuten.db" {
+		t.Errorf("got path %s, want the default", c.DB.Path)
+	}
+}
+
+func TestQualityConfig_Checks(t *testing.T) {
+	c, err := Load([]string{"-quality-max-jump", "4.5"}, env(map[string]string{"QUALITY_RULES": "jump, duplicate"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := c.Quality.Checks()
+	if checks == nil || len(checks.Rules) != 2 || !checks.Rules[rakuten.RuleJump] || checks.MaxJump != 4.5 || checks.JumpWindow != rakuten.DefaultJumpWindow {
+		t.Fatalf("unexpected checks %+v", checks)
+	}
+
+	if checks := Default().Quality.Checks(); checks == nil || len(checks.Rules) != len(rakuten.QualityRules) {
+		t.Fatalf("expected every rule by default, got %+v", checks)
+	}
+	if checks := (QualityConfig{Rules: "none"}).Checks(); checks != nil {
+		t.Fatalf("expected no checks, got %+v", checks)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	c := Default()
+	c.Auth.AdminAPIKey = "admin-key"
+
+	var buf bytes.Buffer
+	if err := Print(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "admin-key") || strings.Contains(out, "password: rakuten") {
+		t.Errorf("secrets not redacted:\n%s", out)
+	}
+	if !strings.Contains(out, "read_timeout: 10s") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if c.Auth.AdminAPIKey != "admin-key" {
+		t.Error("Print modified the config")
+	}
+}
+
+func TestDBConfig_DSN(t *testing.T) {
+	c := Default().DB
+	c.Password = `it's a \ pass`
+
+	want := `host='localhost' port=5555 user='rakuten' password='it\'s a \\ pass' dbname='rakuten' sslmode='disable'`
+	if got := c.DSN(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped. Dropped subscribers see their channel closed and are
+// expected to reconnect and resume from the last event they received.
+const subscriberBuffer = 16
+
+// RatesPublished is sent once for every publication date that ingestion
+// stores for the first time.
+type RatesPublished struct {
+	Base  string
+	Date  time.Time
+	Rates map[string]string
+}
+
+// Bus is an in-process fan-out of RatesPublished events. The zero value is
+// not usable, create one with NewBus.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+type Subscription struct {
+	C <-chan RatesPublished
+
+	c chan RatesPublished
+}
+
+// Subscribe registers a new subscriber. Callers must Unsubscribe once they
+// stop reading from the returned subscription. Subscriptions made after
+// Close start out closed.
+func (b *Bus) Subscribe() *Subscription {
+	c := make(chan RatesPublished, subscriberBuffer)
+	s := &Subscription{C: c, c: c}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(c)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+func (b *Bus) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
+
+// Publish delivers e to every subscriber without blocking.
+func (b *Bus) Publish(e RatesPublished) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		select {
+		case s.c <- e:
+		default:
+			delete(b.subscribers, s)
+			close(s.c)
+		}
+	}
+}
+
+// Close closes every subscription, e.g. so that streams end on shutdown and
+// their clients reconnect to another instance. Later events are discarded.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
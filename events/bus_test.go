@@ -0,0 +1,67 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBus_Publish(t *testing.T) {
+	b := NewBus()
+
+	s1 := b.Subscribe()
+	s2 := b.Subscribe()
+	defer b.Unsubscribe(s1)
+
+	b.Unsubscribe(s2)
+	if _, ok := <-s2.C; ok {
+		t.Fatal("expected closed channel after unsubscribe")
+	}
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	b.Publish(RatesPublished{Base: "EUR", Date: date})
+
+	e := <-s1.C
+	if !e.Date.Equal(date) {
+		t.Fatal("unexpected event")
+	}
+}
+
+func TestBus_SlowSubscriberIsDropped(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(RatesPublished{Base: "EUR"})
+	}
+
+	count := 0
+	for range s.C {
+		count++
+	}
+	if count != subscriberBuffer {
+		t.Fatalf("unexpected event count %d", count)
+	}
+
+	// unsubscribing a dropped subscriber must not panic
+	b.Unsubscribe(s)
+}
+
+func TestBus_Close(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe()
+
+	b.Close()
+	if _, ok := <-s.C; ok {
+		t.Fatal("expected closed channel after close")
+	}
+
+	// publishing and unsubscribing after close must not panic
+	b.Publish(RatesPublished{Base: "EUR"})
+	b.Unsubscribe(s)
+
+	late := b.Subscribe()
+	if _, ok := <-late.C; ok {
+		t.Fatal("expected subscription after close to be closed")
+	}
+	b.Unsubscribe(late)
+}
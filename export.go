@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+// export writes the stored rates between -start and -end as CSV or JSON.
+func export(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "output format, csv or json")
+	from := fs.String("start", "", "first date to export, YYYY-MM-DD (default no limit)")
+	to := fs.String("end", "", "last date to export, YYYY-MM-DD (default no limit)")
+	symbols := fs.String("symbols", "", "comma separated currencies to export (default all)")
+	output := fs.String("output", "", "file to write to (default stdout)")
+	cfg, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	write, ok := exportFormats[*format]
+	if !ok {
+		return errors.Errorf("unknown format %q, must be csv or json", *format)
+	}
+
+	req := &rakuten.GetCurrencyRateRangeRequest{}
+	if *from != "" {
+		if req.StartDate, err = time.Parse("2006-01-02", *from); err != nil {
+			return errors.New("invalid -start, must be YYYY-MM-DD")
+		}
+	}
+	if *to != "" {
+		if req.EndDate, err = time.Parse("2006-01-02", *to); err != nil {
+			return errors.New("invalid -end, must be YYYY-MM-DD")
+		}
+	}
+	if *symbols != "" {
+		req.Quotes = strings.Split(strings.ToUpper(*symbols), ",")
+	}
+
+	app, err := newApp(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	h := rakuten.NewHandler(app.store)
+	h.Overrides = app.store
+	if cfg.Approval.Required {
+		h.Approvals = app.store
+	}
+	h.Logger = app.logger
+
+	rates, err := h.GetCurrencyRateRange(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to read currency rates")
+	}
+
+	if *output == "" {
+		return write(os.Stdout, rates)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(f, rates); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var exportFormats = map[string]func(w io.Writer, rates []rakuten.CurrencyRatesResponse) error{
+	"csv":  writeCSV,
+	"json": writeJSON,
+}
+
+// writeCSV writes one date,base,quote,rate row per rate, ordered by date and
+// quote.
+func writeCSV(w io.Writer, rates []rakuten.CurrencyRatesResponse) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"date", "base", "quote", "rate"}); err != nil {
+		return err
+	}
+	for _, r := range rates {
+		date := r.Date.Format("2006-01-02")
+		for _, quote := range sortedQuotes(r.Rates) {
+			if err := cw.Write([]string{date, r.Base, quote, r.Rates[quote]}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type exportedRates struct {
+	Date  string            `json:"date"`
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+// writeJSON writes an array with one object per date, oldest first.
+func writeJSON(w io.Writer, rates []rakuten.CurrencyRatesResponse) error {
+	out := make([]exportedRates, 0, len(rates))
+	for _, r := range rates {
+		out = append(out, exportedRates{Date: r.Date.Format("2006-01-02"), Base: r.Base, Rates: r.Rates})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func sortedQuotes(rates map[string]string) []string {
+	quotes := make([]string, 0, len(rates))
+	for quote := range rates {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+	return quotes
+}
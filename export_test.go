@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+func TestWriteCSV(t *testing.T) {
+	rates := []rakuten.CurrencyRatesResponse{
+		{Base: "EUR", Date: time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC), Rates: map[string]string{"USD": "1.0599", "JPY": "139.28"}},
+		{Base: "EUR", Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), Rates: map[string]string{"USD": "1.0639"}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, rates); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "date,base,quote,rate\n" +
+		"2023-01-04,EUR,JPY,139.28\n" +
+		"2023-01-04,EUR,USD,1.0599\n" +
+		"2023-01-05,EUR,USD,1.0639\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected csv\n%s", buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Fatalf("expected an empty array, got %s", buf.String())
+	}
+
+	buf.Reset()
+	rates := []rakuten.CurrencyRatesResponse{
+		{Base: "EUR", Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), Rates: map[string]string{"USD": "1.0639"}},
+	}
+	if err := writeJSON(&buf, rates); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"date": "2023-01-05"`)) {
+		t.Fatalf("expected the date in the output, got %s", buf.String())
+	}
+}
@@ -0,0 +1,163 @@
+package gql
+
+import (
+	"time"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxDepth is deep enough for every query the schema allows
+	// (e.g. ratesRange { rates { currency } }) plus some slack.
+	DefaultMaxDepth = 5
+
+	// DefaultMaxComplexity bounds the amount of work a single request can
+	// ask for. Root fields each hit the database and are weighted by
+	// rootFieldCost, every other field costs 1. The fields below
+	// ratesRange are counted once per rangePeriod days it spans.
+	DefaultMaxComplexity = 100
+
+	rootFieldCost = 10
+	rangePeriod   = 31
+)
+
+// firstPublication is the date of the first ECB reference rates, where
+// ranges with a start that cannot be told before running start.
+var firstPublication = time.Date(1999, 1, 4, 0, 0, 0, 0, time.UTC)
+
+// checkLimits rejects documents that nest deeper than maxDepth or whose
+// estimated cost exceeds maxComplexity, with variables the values of the
+// request's variables, which fall back to the defaults the operation
+// declares. A zero limit disables that check.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]interface{}, maxDepth, maxComplexity int) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operations []*ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				operations = append(operations, d)
+			}
+		}
+	}
+
+	for _, op := range operations {
+		defaults := map[string]ast.Value{}
+		for _, def := range op.VariableDefinitions {
+			if def.DefaultValue != nil {
+				defaults[def.Variable.Name.Value] = def.DefaultValue
+			}
+		}
+		w := &walker{fragments: fragments, variables: variables, defaults: defaults, visiting: map[string]bool{}}
+		depth, cost := w.selectionSet(op.SelectionSet, 1)
+
+		if maxDepth > 0 && depth > maxDepth {
+			return errors.Errorf("query depth %d exceeds the maximum of %d", depth, maxDepth)
+		}
+		if maxComplexity > 0 && cost > maxComplexity {
+			return errors.Errorf("query complexity %d exceeds the maximum of %d", cost, maxComplexity)
+		}
+	}
+
+	return nil
+}
+
+type walker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	defaults  map[string]ast.Value
+	visiting  map[string]bool
+}
+
+// selectionSet returns the maximum field depth below set and its total
+// cost. level is the depth of the fields directly inside set.
+func (w *walker) selectionSet(set *ast.SelectionSet, level int) (int, int) {
+	if set == nil {
+		return level - 1, 0
+	}
+
+	maxDepth, cost := level-1, 0
+	for _, selection := range set.Selections {
+		var depth, c int
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			depth, c = w.selectionSet(s.SelectionSet, level+1)
+			if depth < level {
+				depth = level
+			}
+			if level == 1 {
+				if s.Name.Value == "ratesRange" {
+					c *= w.periods(s.Arguments)
+				}
+				c += rootFieldCost
+			} else {
+				c++
+			}
+		case *ast.InlineFragment:
+			depth, c = w.selectionSet(s.SelectionSet, level)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok || w.visiting[name] {
+				// unknown and cyclic fragments are reported by validation
+				continue
+			}
+			w.visiting[name] = true
+			depth, c = w.selectionSet(fragment.SelectionSet, level)
+			delete(w.visiting, name)
+		}
+
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		cost += c
+	}
+
+	return maxDepth, cost
+}
+
+// periods returns how many rangePeriod days the start and end arguments
+// span, at least 1. A bound that cannot be told before running, missing,
+// invalid or given by an unset variable, is taken as the widest: the first
+// publication or today. Invalid dates are left to the resolver to report.
+func (w *walker) periods(args []*ast.Argument) int {
+	start, end := firstPublication, time.Now().UTC()
+	for _, arg := range args {
+		date, err := time.Parse("2006-01-02", w.stringValue(arg.Value))
+		if err != nil {
+			continue
+		}
+		switch arg.Name.Value {
+		case "start":
+			start = date
+		case "end":
+			end = date
+		}
+	}
+	if end.Before(start) {
+		return 1
+	}
+	return int(end.Sub(start).Hours()/24)/rangePeriod + 1
+}
+
+// stringValue returns the string value is, given literally or by a
+// variable of the request or its default, or "" if it is none of these.
+func (w *walker) stringValue(value ast.Value) string {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.Variable:
+		if s, ok := w.variables[v.Name.Value].(string); ok {
+			return s
+		}
+		if def, ok := w.defaults[v.Name.Value].(*ast.StringValue); ok {
+			return def.Value
+		}
+	}
+	return ""
+}
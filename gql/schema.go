@@ -0,0 +1,344 @@
+package gql
+
+import (
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+const dateLayout = "2006-01-02"
+
+var rateType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Rate",
+	Fields: graphql.Fields{
+		"currency":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"rate":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"overridden": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "Whether the rate is a manual override."},
+	},
+})
+
+var rateSetType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RateSet",
+	Fields: graphql.Fields{
+		"base":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"date":  &graphql.Field{Type: graphql.String},
+		"rates": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rateType)))},
+	},
+})
+
+var analyzedRateType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AnalyzedRate",
+	Fields: graphql.Fields{
+		"currency":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"min":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"max":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"avg":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"overridden": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "Whether a manual override contributed to the analysis."},
+	},
+})
+
+var analysisType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Analysis",
+	Fields: graphql.Fields{
+		"base":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"rates": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(analyzedRateType)))},
+	},
+})
+
+var conversionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Conversion",
+	Fields: graphql.Fields{
+		"from":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"to":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"amount": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"date":   &graphql.Field{Type: graphql.String},
+		"rate":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"result": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var symbolsArg = &graphql.ArgumentConfig{
+	Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+	Description: "Restrict the result to these quote currencies.",
+}
+
+var asOfArg = &graphql.ArgumentConfig{
+	Type:        graphql.String,
+	Description: "Return the rates as they were stored at this RFC 3339 timestamp.",
+}
+
+// NewSchema builds the GraphQL schema served on /graphql. Every resolver
+// goes through h so GraphQL and REST clients see the same data.
+func NewSchema(h *rakuten.Handler) (graphql.Schema, error) {
+	r := &resolver{h: h}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"rates": &graphql.Field{
+				Type:        rateSetType,
+				Description: "Rates published on date, or the latest publication when date is omitted.",
+				Args: graphql.FieldConfigArgument{
+					"date":    &graphql.ArgumentConfig{Type: graphql.String},
+					"symbols": symbolsArg,
+					"asOf":    asOfArg,
+				},
+				Resolve: r.rates,
+			},
+			"ratesRange": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(rateSetType))),
+				Description: "Rates for every publication between start and end, inclusive.",
+				Args: graphql.FieldConfigArgument{
+					"start":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"end":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"symbols": symbolsArg,
+					"asOf":    asOfArg,
+				},
+				Resolve: r.ratesRange,
+			},
+			"analysis": &graphql.Field{
+				Type:        analysisType,
+				Description: "Min, max and average rate per currency, optionally within a date range.",
+				Args: graphql.FieldConfigArgument{
+					"start":   &graphql.ArgumentConfig{Type: graphql.String},
+					"end":     &graphql.ArgumentConfig{Type: graphql.String},
+					"symbols": symbolsArg,
+					"asOf":    asOfArg,
+				},
+				Resolve: r.analysis,
+			},
+			"convert": &graphql.Field{
+				Type:        conversionType,
+				Description: "Convert amount between two currencies using the rates of date, or the latest rates.",
+				Args: graphql.FieldConfigArgument{
+					"from":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"to":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"amount": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"date":   &graphql.ArgumentConfig{Type: graphql.String},
+					"asOf":   asOfArg,
+				},
+				Resolve: r.convert,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+type resolver struct {
+	h *rakuten.Handler
+}
+
+func (r *resolver) rates(p graphql.ResolveParams) (interface{}, error) {
+	req := &rakuten.GetCurrencyRateRequest{Quotes: stringList(p.Args["symbols"])}
+
+	date, err := dateArg(p.Args, "date")
+	if err != nil {
+		return nil, err
+	}
+	if date.IsZero() {
+		req.GetLatestDate = true
+	} else {
+		req.Date = date
+	}
+	if req.AsOf, err = asOfValue(p.Args); err != nil {
+		return nil, err
+	}
+
+	rates, err := r.h.GetCurrencyRate(p.Context, req)
+	if err != nil {
+		return nil, err
+	}
+	return rateSet(*rates), nil
+}
+
+func (r *resolver) ratesRange(p graphql.ResolveParams) (interface{}, error) {
+	start, err := dateArg(p.Args, "start")
+	if err != nil {
+		return nil, err
+	}
+	end, err := dateArg(p.Args, "end")
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.New("end must not be before start")
+	}
+	asOf, err := asOfValue(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := r.h.GetCurrencyRateRange(p.Context, &rakuten.GetCurrencyRateRangeRequest{
+		StartDate: start,
+		EndDate:   end,
+		Quotes:    stringList(p.Args["symbols"]),
+		AsOf:      asOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]map[string]interface{}, 0, len(responses))
+	for _, response := range responses {
+		sets = append(sets, rateSet(response))
+	}
+	return sets, nil
+}
+
+func (r *resolver) analysis(p graphql.ResolveParams) (interface{}, error) {
+	start, err := dateArg(p.Args, "start")
+	if err != nil {
+		return nil, err
+	}
+	end, err := dateArg(p.Args, "end")
+	if err != nil {
+		return nil, err
+	}
+	asOf, err := asOfValue(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzed, err := r.h.GetAnalyzedCurrencyRate(p.Context, &rakuten.GetAnalyzedCurrencyRateRequest{
+		StartDate: start,
+		EndDate:   end,
+		Quotes:    stringList(p.Args["symbols"]),
+		AsOf:      asOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if analyzed == nil {
+		return nil, nil
+	}
+
+	rates := make([]map[string]interface{}, 0, len(analyzed.RatesAnalyzed))
+	for _, currency := range sortedKeys(analyzed.RatesAnalyzed) {
+		rate := analyzed.RatesAnalyzed[currency]
+		rates = append(rates, map[string]interface{}{
+			"currency":   currency,
+			"min":        rate.Min,
+			"max":        rate.Max,
+			"avg":        rate.Avg,
+			"overridden": rate.Overridden,
+		})
+	}
+
+	return map[string]interface{}{"base": analyzed.Base, "rates": rates}, nil
+}
+
+func (r *resolver) convert(p graphql.ResolveParams) (interface{}, error) {
+	date, err := dateArg(p.Args, "date")
+	if err != nil {
+		return nil, err
+	}
+	asOf, err := asOfValue(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	conversion, err := r.h.ConvertCurrency(p.Context, &rakuten.ConvertCurrencyRequest{
+		From:          p.Args["from"].(string),
+		To:            p.Args["to"].(string),
+		Amount:        p.Args["amount"].(string),
+		GetLatestDate: date.IsZero(),
+		Date:          date,
+		AsOf:          asOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"from":   conversion.From,
+		"to":     conversion.To,
+		"amount": conversion.Amount,
+		"date":   formatDate(conversion.Date),
+		"rate":   conversion.Rate,
+		"result": conversion.Result,
+	}, nil
+}
+
+func rateSet(response rakuten.CurrencyRatesResponse) map[string]interface{} {
+	overridden := map[string]bool{}
+	for _, currency := range response.Overridden {
+		overridden[currency] = true
+	}
+
+	rates := make([]map[string]interface{}, 0, len(response.Rates))
+	for _, currency := range sortedKeys(response.Rates) {
+		rates = append(rates, map[string]interface{}{
+			"currency":   currency,
+			"rate":       response.Rates[currency],
+			"overridden": overridden[currency],
+		})
+	}
+
+	return map[string]interface{}{
+		"base":  response.Base,
+		"date":  formatDate(response.Date),
+		"rates": rates,
+	}
+}
+
+func dateArg(args map[string]interface{}, name string) (time.Time, error) {
+	value, ok := args[name].(string)
+	if !ok || value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid %s, must be YYYY-MM-DD", name)
+	}
+	return t, nil
+}
+
+func asOfValue(args map[string]interface{}) (time.Time, error) {
+	value, ok := args["asOf"].(string)
+	if !ok || value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid asOf, must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
+
+func formatDate(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(dateLayout)
+}
+
+func stringList(value interface{}) []string {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
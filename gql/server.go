@@ -0,0 +1,133 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+// maxBodySize caps the size of a POSTed query document.
+const maxBodySize = 64 << 10
+
+// Server serves GraphQL requests over HTTP, both as GET ?query= and as a
+// POSTed JSON body of the form {"query", "variables", "operationName"}.
+type Server struct {
+	Schema        graphql.Schema
+	MaxDepth      int
+	MaxComplexity int
+}
+
+func NewServer(h *rakuten.Handler) (*Server, error) {
+	schema, err := NewSchema(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		Schema:        schema,
+		MaxDepth:      DefaultMaxDepth,
+		MaxComplexity: DefaultMaxComplexity,
+	}, nil
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	req := request{}
+
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeErrors(w, http.StatusBadRequest, "invalid variables")
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			writeErrors(w, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeErrors(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeErrors(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if req.Query == "" {
+		writeErrors(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	result, status := s.execute(r.Context(), req)
+
+	response, err := json.Marshal(result)
+	if err != nil {
+		writeErrors(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// execute parses, validates and checks the query against the configured
+// limits before running it, so rejected queries never reach the database.
+func (s *Server) execute(ctx context.Context, req request) (*graphql.Result, int) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, http.StatusBadRequest
+	}
+
+	validation := graphql.ValidateDocument(&s.Schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}, http.StatusBadRequest
+	}
+
+	if err := checkLimits(doc, req.OperationName, req.Variables, s.MaxDepth, s.MaxComplexity); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, http.StatusBadRequest
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.Schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	return result, http.StatusOK
+}
+
+func writeErrors(w http.ResponseWriter, status int, message string) {
+	response, err := json.Marshal(graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(message)}})
+	if err != nil {
+		// shouldn't happen
+		panic(err)
+	}
+
+	w.WriteHeader(status)
+	w.Write(response)
+}
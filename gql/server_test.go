@@ -0,0 +1,137 @@
+package gql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestServer_Rates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	testData := []storage.Rate{
+		{
+			Base:  "EUR",
+			Quote: "USD",
+			Rate:  "1.25",
+			Date:  date,
+		},
+	}
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, gAny).Return(testData, nil)
+
+	s, err := NewServer(rakuten.NewHandler(mockStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"query": "{ rates(date: \"2023-01-05\", symbols: [\"USD\"]) { base date rates { currency rate } } }"}`
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			Rates struct {
+				Base  string `json:"base"`
+				Date  string `json:"date"`
+				Rates []struct {
+					Currency string `json:"currency"`
+					Rate     string `json:"rate"`
+				} `json:"rates"`
+			} `json:"rates"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Data.Rates.Date != "2023-01-05" {
+		t.Fatal("unexpected date")
+	}
+	if len(response.Data.Rates.Rates) != 1 || response.Data.Rates.Rates[0].Rate != "1.25" {
+		t.Fatal("unexpected rates")
+	}
+}
+
+func TestServer_Limits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// no store expectations: rejected queries must not reach storage
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+
+	s, err := NewServer(rakuten.NewHandler(mockStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.MaxDepth = 2
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphql?query={rates{rates{currency}}}", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "depth") {
+		t.Fatalf("expected depth error, got %d: %s", w.Code, w.Body.String())
+	}
+
+	s.MaxDepth = DefaultMaxDepth
+	query := "{" + strings.Repeat(`a: rates{base} b: rates{base} c: rates{base} d: rates{base} `, 3) + "}"
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "`+query+`"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "complexity") {
+		t.Fatalf("expected complexity error, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// a single range query over years, given literally or as variables
+	query = `{ratesRange(start: \"2019-01-01\", end: \"2023-01-01\"){date rates{currency rate}}}`
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "`+query+`"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "complexity") {
+		t.Fatalf("expected complexity error for a multi-year range, got %d: %s", w.Code, w.Body.String())
+	}
+	query = `query($start: String!, $end: String!){ratesRange(start: $start, end: $end){date rates{currency rate}}}`
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(
+		`{"query": "`+query+`", "variables": {"start": "2019-01-01", "end": "2023-01-01"}}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "complexity") {
+		t.Fatalf("expected complexity error for a multi-year range in variables, got %d: %s", w.Code, w.Body.String())
+	}
+	// or as the defaults of variables
+	query = `query($s: String = \"1999-01-04\", $e: String = \"2030-01-01\"){ratesRange(start: $s, end: $e){date rates{currency rate}}}`
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "`+query+`"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "complexity") {
+		t.Fatalf("expected complexity error for a multi-year range in variable defaults, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWalker_Periods(t *testing.T) {
+	w := &walker{variables: map[string]interface{}{"start": 20230101}}
+	arg := func(name string, value ast.Value) *ast.Argument {
+		return &ast.Argument{Name: &ast.Name{Value: name}, Value: value}
+	}
+	end := arg("end", &ast.StringValue{Value: "2023-01-31"})
+
+	if got := w.periods([]*ast.Argument{arg("start", &ast.StringValue{Value: "2023-01-01"}), end}); got != 1 {
+		t.Fatalf("got %d periods for a month, want 1", got)
+	}
+	// a start that is not a date may be as early as the first publication
+	want := int(time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC).Sub(firstPublication).Hours()/24)/rangePeriod + 1
+	if got := w.periods([]*ast.Argument{arg("start", &ast.Variable{Name: &ast.Name{Value: "start"}}), end}); got != want {
+		t.Fatalf("got %d periods for an unknown start, want %d", got, want)
+	}
+}
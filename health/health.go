@@ -0,0 +1,212 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	// DefaultMaxMissedPublications tolerates the gap between the ECB
+	// publishing and the next hourly ingestion.
+	DefaultMaxMissedPublications = 1
+	DefaultMaxIngestionAge       = 3 * time.Hour
+
+	checkTimeout = 2 * time.Second
+)
+
+const (
+	StatusOK       = "ok"
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not_ready"
+	StatusStale    = "stale"
+	StatusFailing  = "ingestion_failing"
+)
+
+// Checker serves the health endpoints. Ingestion outcomes are reported to
+// it with RecordIngestion.
+type Checker struct {
+	Store storage.HealthStore
+	H     *rakuten.Handler
+
+	// MaxMissedPublications is how many expected ECB publications may be
+	// missing before /status fails.
+	MaxMissedPublications int
+	// MaxIngestionAge is how long ago the last successful ingestion may be
+	// before /status fails.
+	MaxIngestionAge time.Duration
+
+	mu        sync.Mutex
+	ingestion Ingestion
+	now       func() time.Time
+}
+
+type Ingestion struct {
+	AttemptedAt *time.Time `json:"attempted_at"`
+	SucceededAt *time.Time `json:"succeeded_at"`
+	Result      string     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Stored      int        `json:"stored"`
+}
+
+const (
+	IngestionSuccess = "success"
+	IngestionError   = "error"
+)
+
+func NewChecker(store storage.HealthStore, h *rakuten.Handler) *Checker {
+	return &Checker{
+		Store:                 store,
+		H:                     h,
+		MaxMissedPublications: DefaultMaxMissedPublications,
+		MaxIngestionAge:       DefaultMaxIngestionAge,
+		now:                   time.Now,
+	}
+}
+
+// RecordIngestion records the outcome of an ingestion run that stored
+// stored rows.
+func (c *Checker) RecordIngestion(stored int, err error) {
+	now := c.now().UTC()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ingestion.AttemptedAt = &now
+	c.ingestion.Stored = stored
+	if err != nil {
+		c.ingestion.Result = IngestionError
+		c.ingestion.Error = err.Error()
+		return
+	}
+	c.ingestion.Result = IngestionSuccess
+	c.ingestion.Error = ""
+	c.ingestion.SucceededAt = &now
+}
+
+// Healthz reports that the process is alive. It checks nothing else, so
+// that an orchestrator does not restart the service for a database outage.
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+type ReadyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Readyz reports whether requests can be served: the database is
+// reachable, the schema exists and at least one publication is stored.
+// When the last ingestion failed the stored rates are still served, so it
+// answers 200 with a "degraded" status.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := ReadyResponse{Status: StatusReady, Checks: map[string]string{}}
+	check := func(name string, err error) bool {
+		if err != nil {
+			resp.Status = StatusNotReady
+			resp.Checks[name] = err.Error()
+			return false
+		}
+		resp.Checks[name] = StatusOK
+		return true
+	}
+
+	if check("database", c.Store.Ping(ctx)) && check("schema", c.Store.CheckSchema(ctx)) {
+		latest, err := c.H.LatestPublishedDate(ctx)
+		if err == nil && latest.IsZero() {
+			err = rakuten.ErrRateNotFound
+		}
+		check("publications", err)
+	}
+
+	c.mu.Lock()
+	ingestion := c.ingestion
+	c.mu.Unlock()
+
+	if ingestion.Result == IngestionError {
+		resp.Checks["ingestion"] = ingestion.Error
+		if resp.Status == StatusReady {
+			resp.Status = StatusDegraded
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status == StatusNotReady {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+type StatusResponse struct {
+	Status                string    `json:"status"`
+	LatestPublication     string    `json:"latest_publication,omitempty"`
+	ExpectedPublication   string    `json:"expected_publication"`
+	MissedPublications    int       `json:"missed_publications"`
+	MaxMissedPublications int       `json:"max_missed_publications"`
+	LastIngestion         Ingestion `json:"last_ingestion"`
+	Error                 string    `json:"error,omitempty"`
+}
+
+// Status reports the freshness of the stored rates against the ECB
+// business-day calendar and the last ingestion. It answers 503 when more
+// publications are missing than MaxMissedPublications, or when no
+// ingestion succeeded within MaxIngestionAge.
+func (c *Checker) Status(w http.ResponseWriter, r *http.Request) {
+	now := c.now()
+
+	c.mu.Lock()
+	ingestion := c.ingestion
+	c.mu.Unlock()
+
+	resp := StatusResponse{
+		Status:                StatusOK,
+		ExpectedPublication:   rakuten.ExpectedPublicationDate(now).Format("2006-01-02"),
+		MaxMissedPublications: c.MaxMissedPublications,
+		LastIngestion:         ingestion,
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	latest, err := c.H.LatestPublishedDate(ctx)
+	if err != nil {
+		resp.Status = StatusNotReady
+		resp.Error = err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	if !latest.IsZero() {
+		resp.LatestPublication = latest.Format("2006-01-02")
+		resp.MissedPublications = rakuten.MissedPublications(latest, now)
+	}
+
+	switch {
+	case latest.IsZero() || resp.MissedPublications > c.MaxMissedPublications:
+		resp.Status = StatusStale
+	case ingestion.SucceededAt == nil || now.Sub(*ingestion.SucceededAt) > c.MaxIngestionAge:
+		resp.Status = StatusFailing
+	}
+
+	status := http.StatusOK
+	if resp.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func newChecker(t *testing.T, latest []storage.Rate, now time.Time) (*Checker, *mock_storage.MockHealthStore) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	rakutenStore := mock_storage.NewMockRakutenStore(ctrl)
+	rakutenStore.EXPECT().GetCurrencyRates(gAny, storage.CurrencyFilter{GetLatestDate: true}).Return(latest, nil).AnyTimes()
+
+	healthStore := mock_storage.NewMockHealthStore(ctrl)
+
+	c := NewChecker(healthStore, rakuten.NewHandler(rakutenStore))
+	c.now = func() time.Time { return now }
+	return c, healthStore
+}
+
+func serve(t *testing.T, handler http.HandlerFunc, v interface{}) int {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code
+}
+
+func TestChecker_Healthz(t *testing.T) {
+	c, _ := newChecker(t, nil, time.Now())
+
+	var resp map[string]string
+	if code := serve(t, c.Healthz, &resp); code != http.StatusOK || resp["status"] != StatusOK {
+		t.Errorf("unexpected response %d %v", code, resp)
+	}
+}
+
+func TestChecker_Readyz(t *testing.T) {
+	gAny := gomock.Any()
+	stored := []storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1.1", Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		name      string
+		latest    []storage.Rate
+		pingErr   error
+		schemaErr error
+		ingestErr error
+		wantCode  int
+		wantCheck string
+	}{
+		{name: "ready", latest: stored, wantCode: http.StatusOK},
+		{name: "feed down", latest: stored, ingestErr: errors.New("no route to host"), wantCode: http.StatusOK, wantCheck: "ingestion"},
+		{name: "feed down without data", ingestErr: errors.New("no route to host"), wantCode: http.StatusServiceUnavailable, wantCheck: "publications"},
+		{name: "database down", pingErr: errors.New("connection refused"), wantCode: http.StatusServiceUnavailable, wantCheck: "database"},
+		{name: "schema missing", schemaErr: errors.New("missing tables: currency_rate"), wantCode: http.StatusServiceUnavailable, wantCheck: "schema"},
+		{name: "no publications", wantCode: http.StatusServiceUnavailable, wantCheck: "publications"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, healthStore := newChecker(t, tt.latest, time.Now())
+			healthStore.EXPECT().Ping(gAny).Return(tt.pingErr)
+			healthStore.EXPECT().CheckSchema(gAny).Return(tt.schemaErr).MaxTimes(1)
+			if tt.ingestErr != nil {
+				c.RecordIngestion(0, tt.ingestErr)
+			}
+
+			var resp ReadyResponse
+			code := serve(t, c.Readyz, &resp)
+			if code != tt.wantCode {
+				t.Errorf("got status %d, want %d", code, tt.wantCode)
+			}
+			if tt.wantCheck != "" && (resp.Status == StatusReady || resp.Checks[tt.wantCheck] == StatusOK) {
+				t.Errorf("expected %s check to fail: %+v", tt.wantCheck, resp)
+			}
+			if tt.wantCode == http.StatusOK && tt.ingestErr != nil && resp.Status != StatusDegraded {
+				t.Errorf("got status %s, want degraded", resp.Status)
+			}
+		})
+	}
+}
+
+func TestChecker_Status(t *testing.T) {
+	// Wednesday after Easter, after the publication
+	now := time.Date(2023, 4, 12, 16, 0, 0, 0, time.UTC)
+	rate := func(date string) []storage.Rate {
+		d, _ := time.Parse("2006-01-02", date)
+		return []storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1.1", Date: d}}
+	}
+
+	tests := []struct {
+		name       string
+		latest     []storage.Rate
+		ingestedAt time.Time
+		ingestErr  error
+		wantCode   int
+		wantStatus string
+		wantMissed int
+	}{
+		{name: "fresh", latest: rate("2023-04-12"), ingestedAt: now, wantCode: http.StatusOK, wantStatus: StatusOK},
+		{name: "one behind", latest: rate("2023-04-11"), ingestedAt: now, wantCode: http.StatusOK, wantStatus: StatusOK, wantMissed: 1},
+		{name: "stale over holidays", latest: rate("2023-04-06"), ingestedAt: now, wantCode: http.StatusServiceUnavailable, wantStatus: StatusStale, wantMissed: 2},
+		{name: "empty", ingestedAt: now, wantCode: http.StatusServiceUnavailable, wantStatus: StatusStale},
+		{name: "ingestion failing", latest: rate("2023-04-12"), ingestedAt: now, ingestErr: errors.New("timeout"), wantCode: http.StatusServiceUnavailable, wantStatus: StatusFailing},
+		{name: "ingestion too old", latest: rate("2023-04-12"), ingestedAt: now.Add(-4 * time.Hour), wantCode: http.StatusServiceUnavailable, wantStatus: StatusFailing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newChecker(t, tt.latest, tt.ingestedAt)
+			c.RecordIngestion(3, tt.ingestErr)
+			c.now = func() time.Time { return now }
+
+			var resp StatusResponse
+			code := serve(t, c.Status, &resp)
+			if code != tt.wantCode || resp.Status != tt.wantStatus || resp.MissedPublications != tt.wantMissed {
+				t.Errorf("got %d %s missed %d, want %d %s missed %d", code, resp.Status, resp.MissedPublications, tt.wantCode, tt.wantStatus, tt.wantMissed)
+			}
+			if resp.ExpectedPublication != "2023-04-12" {
+				t.Errorf("got expected publication %s", resp.ExpectedPublication)
+			}
+			if tt.ingestErr != nil && (resp.LastIngestion.Result != IngestionError || resp.LastIngestion.Error != tt.ingestErr.Error()) {
+				t.Errorf("unexpected last ingestion %+v", resp.LastIngestion)
+			}
+		})
+	}
+}
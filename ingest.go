@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+// ingest stores the publications of the ECB feed, or of a saved copy, that
+// are newer than the latest stored one.
+func ingest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	source := fs.String("source", "", "URL or XML file to read rates from (default ecb.url)")
+	cfg, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if *source == "" {
+		*source = cfg.ECB.URL
+	}
+
+	app, err := newApp(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	h := rakuten.NewHandler(app.store)
+	if cfg.Approval.Required {
+		h.Approvals = app.store
+	}
+	h.Quality = cfg.Quality.Checks()
+	h.Quarantine = app.store
+	h.Logger = app.logger
+
+	run := newRun("ingest", *source)
+	feed, err := loadFeed(ctx, *source)
+	var result rakuten.IngestResult
+	if err == nil {
+		result, err = h.IngestCurrencyRates(ctx, feed.Rates)
+		err = errors.Wrap(err, "failed to store currency rates")
+	}
+	recordRun(app.store, app.logger, run, feed, result, err)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("ingested currency rates",
+		zap.String("source", *source),
+		zap.Int("inserted", result.Inserted),
+		zap.Int("updated", result.Updated),
+		zap.Int("rejected", result.Rejected),
+	)
+	return nil
+}
+
+// backfill stores the publications between -from and -to that are missing,
+// e.g. to load the full history into a new database.
+func backfill(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	source := fs.String("source", rakuten.ECBHistoryURL, "URL or XML file to read rates from")
+	from := fs.String("from", "", "first date to backfill, YYYY-MM-DD (required)")
+	to := fs.String("to", "", "last date to backfill, YYYY-MM-DD (default no limit)")
+	cfg, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *from == "" {
+		return errors.New("-from is required")
+	}
+	start, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return errors.New("invalid -from, must be YYYY-MM-DD")
+	}
+	var end time.Time
+	if *to != "" {
+		end, err = time.Parse("2006-01-02", *to)
+		if err != nil {
+			return errors.New("invalid -to, must be YYYY-MM-DD")
+		}
+		if end.Before(start) {
+			return errors.New("-to must not be before -from")
+		}
+	}
+
+	app, err := newApp(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	h := rakuten.NewHandler(app.store)
+	if cfg.Approval.Required {
+		h.Approvals = app.store
+	}
+	h.Quality = cfg.Quality.Checks()
+	h.Quarantine = app.store
+	h.Logger = app.logger
+
+	run := newRun("backfill", *source)
+	feed, err := loadFeed(ctx, *source)
+	var result rakuten.IngestResult
+	if err == nil {
+		result, err = h.BackfillCurrencyRates(ctx, feed.Rates, start, end)
+		err = errors.Wrap(err, "failed to backfill currency rates")
+	}
+	recordRun(app.store, app.logger, run, feed, result, err)
+	return err
+}
+
+// loadFeed fetches source if it is an http(s) URL and reads it as a file
+// otherwise. The feed is returned with what was read even when err is not
+// nil, so that failed runs are audited with it.
+func loadFeed(ctx context.Context, source string) (*rakuten.Feed, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		feed, err := rakuten.FetchFeed(ctx, source)
+		return feed, errors.Wrap(err, "failed to fetch currency rates")
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return rakuten.ReadFeed(f)
+}
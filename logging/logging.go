@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns a logger writing JSON lines to stderr at level, one of
+// "debug", "info", "warn" or "error". An empty level means "info".
+func New(level string) (*zap.Logger, error) {
+	lvl := zapcore.InfoLevel
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+	cfg.Sampling = nil
+	cfg.EncoderConfig.TimeKey = "time"
+	cfg.EncoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	return cfg.Build()
+}
+
+type requestIDKey struct{}
+
+// NewContext returns a copy of ctx carrying the request ID id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set by the RequestID
+// middleware, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// With returns logger annotated with the request and trace IDs of ctx. A
+// nil logger stands for the global one, which discards everything unless
+// replaced with zap.ReplaceGlobals.
+func With(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if logger == nil {
+		logger = zap.L()
+	}
+
+	var fields []zap.Field
+	if id, ok := RequestIDFromContext(ctx); ok {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+	}
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{name: "generated", header: "", reuse: false},
+		{name: "reused", header: "lb-1234.abcd", reuse: true},
+		{name: "invalid characters", header: "id with spaces", reuse: false},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1), reuse: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext, _ = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/rates/latest", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if id == "" {
+				t.Fatal("response has no request id")
+			}
+			if id != fromContext {
+				t.Errorf("got request id %q in context, %q in response", fromContext, id)
+			}
+			if tt.reuse != (id == tt.header) {
+				t.Errorf("got request id %q for header %q", id, tt.header)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	handler := RequestID(AccessLog(zap.New(core), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/rates/2023-01-01", nil)
+	req.RemoteAddr = "203.0.113.7:52100"
+	req.Header.Set(RequestIDHeader, "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if logs.Len() != 1 {
+		t.Fatalf("got %d log lines, want 1", logs.Len())
+	}
+	fields := logs.All()[0].ContextMap()
+
+	want := map[string]interface{}{
+		"request_id": "abc-123",
+		"method":     http.MethodGet,
+		"path":       "/rates/2023-01-01",
+		"status":     int64(http.StatusNotFound),
+		"bytes":      int64(len(`{"message":"not found"}`)),
+		"client":     "203.0.113.7",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("got %s %v, want %v", k, fields[k], v)
+		}
+	}
+	if _, ok := fields["latency"]; !ok {
+		t.Error("access log has no latency")
+	}
+}
+
+func TestWith(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	With(NewContext(context.Background(), "abc-123"), zap.New(core)).Error("failed")
+	With(context.Background(), zap.New(core)).Error("failed")
+
+	entries := logs.All()
+	if got := entries[0].ContextMap()["request_id"]; got != "abc-123" {
+		t.Errorf("got request id %v, want abc-123", got)
+	}
+	if _, ok := entries[1].ContextMap()["request_id"]; ok {
+		t.Error("got request id without one in context")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+
+	logger, err := New("warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logger.Core().Enabled(zapcore.InfoLevel) {
+		t.Error("info is enabled at warn level")
+	}
+}
@@ -2,98 +2,149 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
 
-	"github.com/syahnur197/rakuten/rakuten"
-	"github.com/syahnur197/rakuten/router"
+	"github.com/syahnur197/rakuten/config"
+	"github.com/syahnur197/rakuten/logging"
 	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/sqlite"
 )
 
-const (
-	dbUser = "rakuten"
-	dbPass = "rakuten"
-	dbName = "rakuten"
-)
-
-var (
-	dbPort = "5555"
-	dbHost = "localhost"
-)
+const usageText = `Usage: rakuten [command] [flags]
+
+Commands:
+  serve     serve the API and ingest the ECB feed periodically (default)
+  ingest    store new publications from the ECB feed or an XML file
+  backfill  store missing historical publications
+  migrate   create or check the database schema
+  export    write stored rates as CSV or JSON
+  config    print the effective configuration
+
+Run "rakuten <command> -h" for the flags of a command.
+`
+
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"serve":    serve,
+	"ingest":   ingest,
+	"backfill": backfill,
+	"migrate":  migrate,
+	"export":   export,
+	"config":   printConfig,
+}
 
 func main() {
-	if os.Getenv("DB_PORT") != "" {
-		dbPort = os.Getenv("DB_PORT")
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	if os.Getenv("DB_HOST") != "" {
-		dbHost = os.Getenv("DB_HOST")
-	}
-
-	// setting up db
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPass, dbName)
 
-	db, err := sqlx.Open("postgres", dsn)
-	if err != nil {
-		log.Fatal(err)
+	if name == "help" {
+		fmt.Print(usageText)
+		return
 	}
-	defer db.Close()
 
-	err = db.Ping()
-	if err != nil {
-		log.Fatal(err)
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usageText)
+		os.Exit(2)
 	}
 
-	s := storage.NewStorage(db)
-
-	h := rakuten.NewHandler(s)
+	// stopped on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, args)
+	stop()
 
-	// setup database schema
-	log.Println("initialise schema")
-	err = s.CreateCurrencyRatesTable()
+	if err == flag.ErrHelp {
+		return
+	}
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "rakuten %s: %v\n", name, err)
+		os.Exit(1)
 	}
+}
 
-	// fetch currency ratesList
-	log.Println("fetching currency rates")
-	ratesList, err := rakuten.FetchCurrencyRates()
-	if err != nil {
-		log.Fatal(err)
+// parseFlags parses args into fs, which holds the command's own flags, and
+// the configuration flags, and loads the configuration.
+func parseFlags(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
+	return loader.Load(os.Getenv)
+}
 
-	log.Println("storing currency rates")
-	for _, rate := range ratesList.Rates {
-		rate.Base = "EUR"
+// app holds what every command working on the database needs.
+type app struct {
+	cfg    *config.Config
+	logger *zap.Logger
+	db     *sqlx.DB
+	store  storage.Backend
+}
+
+// newApp sets up logging and connects to the database of cfg.DB.Driver.
+// Callers must Close the returned app.
+func newApp(ctx context.Context, cfg *config.Config) (*app, error) {
+	logger, err := logging.New(cfg.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	zap.ReplaceGlobals(logger)
 
-		storageRate, err := rakuten.ConvertToStoreRate(rate)
+	a := &app{cfg: cfg, logger: logger}
+	switch cfg.DB.Driver {
+	case config.DriverSQLite:
+		a.db, err = sqlite.Open(cfg.DB.Path)
 		if err != nil {
-			log.Fatal(err)
+			break
 		}
-
-		_, err = s.CreateCurrencyRate(context.Background(), storageRate)
+		s := sqlite.NewStore(a.db)
+		s.Logger = logger
+		a.store = s
+	default:
+		connectCtx, cancel := context.WithTimeout(ctx, cfg.DB.ConnectTimeout)
+		defer cancel()
+
+		a.db, err = storage.Connect(connectCtx, cfg.DB.DSN(), logger)
 		if err != nil {
-			log.Fatal(err)
+			break
 		}
+		s := storage.NewStorage(a.db)
+		s.Logger = logger
+		a.store = s
+	}
+	if err != nil {
+		logger.Sync()
+		return nil, err
 	}
 
-	// setting up mux
-	log.Println("setting up mux")
-	mux := http.NewServeMux()
+	return a, nil
+}
 
-	r := router.NewRouter(h)
+func (a *app) Close() {
+	a.db.Close()
+	a.logger.Sync()
+}
 
-	mux.HandleFunc("/ping", r.Ping)
-	mux.HandleFunc("/rates/analyze", r.GetAnalyzedCurrencyRate)
-	mux.HandleFunc("/rates/", r.GetCurrencyRate)
+// printConfig implements "config print".
+func printConfig(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New(`usage: rakuten config print [flags]`)
+	}
 
-	log.Println("listening to port :4000")
-	err = http.ListenAndServe(":4000", mux)
-	log.Fatal(err)
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	return config.Print(os.Stdout, cfg)
 }
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/syahnur197/rakuten/response"
+	"github.com/syahnur197/rakuten/storage/cache"
+)
+
+const namespace = "rakuten"
+
+const (
+	IngestionSuccess    = "success"
+	IngestionFetchError = "fetch_error"
+	IngestionStoreError = "store_error"
+)
+
+// Metrics holds every collector of the service on its own registry.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	ingestionRuns   *prometheus.CounterVec
+	ingestedRows    prometheus.Counter
+	latestDate      prometheus.Gauge
+
+	mu                sync.Mutex
+	latestPublication time.Time
+	now               func() time.Time
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		now:      time.Now,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_query_duration_seconds",
+			Help:      "RakutenStore call latency by method and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "outcome"}),
+		ingestionRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingestion_runs_total",
+			Help:      "Ingestion runs by outcome.",
+		}, []string{"outcome"}),
+		ingestedRows: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ingested_rows_total",
+			Help:      "Currency rate rows stored by ingestion.",
+		}),
+		latestDate: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_publication_timestamp_seconds",
+			Help:      "Unix time of the newest stored publication date.",
+		}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.ingestionRuns,
+		m.ingestedRows,
+		m.latestDate,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_publication_age_seconds",
+			Help:      "Seconds since the newest stored publication date, alert on this to catch stale rates.",
+		}, m.latestPublicationAge),
+	)
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCache exports the hit, miss and eviction counts of c.
+func (m *Metrics) RegisterCache(c *cache.Store) {
+	stat := func(name, help string, value func(cache.Stats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(c.Stats()) })
+	}
+
+	m.Registry.MustRegister(
+		stat("cache_hits_total", "Storage cache hits.", func(s cache.Stats) float64 { return float64(s.Hits) }),
+		stat("cache_misses_total", "Storage cache misses.", func(s cache.Stats) float64 { return float64(s.Misses) }),
+		stat("cache_evictions_total", "Storage cache evictions.", func(s cache.Stats) float64 { return float64(s.Evictions) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_entries",
+			Help:      "Storage cache entries.",
+		}, func() float64 { return float64(c.Stats().Entries) }),
+	)
+}
+
+// ObserveIngestion records the outcome of one ingestion run, the rows it
+// stored and the newest publication date in storage after it.
+func (m *Metrics) ObserveIngestion(outcome string, rows int, latest time.Time) {
+	m.ingestionRuns.WithLabelValues(outcome).Inc()
+	m.ingestedRows.Add(float64(rows))
+	m.SetLatestPublication(latest)
+}
+
+func (m *Metrics) SetLatestPublication(latest time.Time) {
+	if latest.IsZero() {
+		return
+	}
+	m.mu.Lock()
+	m.latestPublication = latest
+	m.mu.Unlock()
+	m.latestDate.Set(float64(latest.Unix()))
+}
+
+func (m *Metrics) latestPublicationAge() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.latestPublication.IsZero() {
+		return 0
+	}
+	return m.now().Sub(m.latestPublication).Seconds()
+}
+
+// Instrument counts and times requests to next under route, a route name
+// such as "/rates/{date}" rather than the raw path.
+func (m *Metrics) Instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := m.now()
+		rec := response.NewRecorder(w)
+
+		next(rec, r)
+
+		status := strconv.Itoa(rec.Status())
+		m.requests.WithLabelValues(route, r.Method, status).Inc()
+		m.requestDuration.WithLabelValues(route, r.Method, status).Observe(m.now().Sub(start).Seconds())
+	}
+}
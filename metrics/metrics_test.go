@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/memory"
+	"github.com/syahnur197/rakuten/storage/storagetest"
+)
+
+func TestMetrics_Instrument(t *testing.T) {
+	m := New()
+
+	handler := m.Instrument("/rates/{date}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rates/2023-01-05", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rates/2023-01-06", nil))
+
+	if n := testutil.ToFloat64(m.requests.WithLabelValues("/rates/{date}", http.MethodGet, "404")); n != 2 {
+		t.Fatalf("unexpected request count %v", n)
+	}
+}
+
+func TestMetrics_LatestPublicationAge(t *testing.T) {
+	m := New()
+
+	latest := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return latest.Add(36 * time.Hour) }
+	m.ObserveIngestion(IngestionSuccess, 31, latest)
+
+	expected := `
+# HELP rakuten_latest_publication_age_seconds Seconds since the newest stored publication date, alert on this to catch stale rates.
+# TYPE rakuten_latest_publication_age_seconds gauge
+rakuten_latest_publication_age_seconds 129600
+`
+	if err := testutil.GatherAndCompare(m.Registry, strings.NewReader(expected), "rakuten_latest_publication_age_seconds"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := testutil.ToFloat64(m.ingestedRows); n != 31 {
+		t.Fatalf("unexpected ingested rows %v", n)
+	}
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.RakutenStore {
+		return New().InstrumentStore(memory.New())
+	})
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+var (
+	_ storage.RakutenStore = (*Store)(nil)
+)
+
+// Store times every call to the wrapped RakutenStore.
+type Store struct {
+	s storage.RakutenStore
+	m *Metrics
+}
+
+func (m *Metrics) InstrumentStore(s storage.RakutenStore) *Store {
+	return &Store{s: s, m: m}
+}
+
+func (s *Store) observe(method string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	s.m.queryDuration.WithLabelValues(method, outcome).Observe(s.m.now().Sub(start).Seconds())
+}
+
+func (s *Store) CreateCurrencyRatesTable() error {
+	start := s.m.now()
+	err := s.s.CreateCurrencyRatesTable()
+	s.observe("CreateCurrencyRatesTable", start, err)
+	return err
+}
+
+func (s *Store) CreateCurrencyRate(ctx context.Context, rate storage.Rate) (string, error) {
+	start := s.m.now()
+	id, err := s.s.CreateCurrencyRate(ctx, rate)
+	s.observe("CreateCurrencyRate", start, err)
+	return id, err
+}
+
+func (s *Store) CreateCurrencyRates(ctx context.Context, rates []storage.Rate) error {
+	start := s.m.now()
+	err := s.s.CreateCurrencyRates(ctx, rates)
+	s.observe("CreateCurrencyRates", start, err)
+	return err
+}
+
+func (s *Store) GetCurrencyRates(ctx context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+	start := s.m.now()
+	rates, err := s.s.GetCurrencyRates(ctx, filter)
+	s.observe("GetCurrencyRates", start, err)
+	return rates, err
+}
+
+func (s *Store) GetAnalyzedCurrencyRates(ctx context.Context, filter storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
+	start := s.m.now()
+	rates, err := s.s.GetAnalyzedCurrencyRates(ctx, filter)
+	s.observe("GetAnalyzedCurrencyRates", start, err)
+	return rates, err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// migrate implements "migrate up", which creates the schema, and "migrate
+// status", which fails unless every table exists.
+func migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "status") {
+		return errors.New("usage: rakuten migrate up|status [flags]")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	cfg, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: rakuten migrate up|status [flags]")
+	}
+
+	app, err := newApp(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	if args[0] == "status" {
+		if err := app.store.CheckSchema(ctx); err != nil {
+			return err
+		}
+		fmt.Println("schema is up to date")
+		return nil
+	}
+
+	if err := app.store.Migrate(); err != nil {
+		return err
+	}
+	app.logger.Info("schema migrated")
+	return nil
+}
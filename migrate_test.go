@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	flags := []string{"-db-driver", "sqlite", "-db-path", filepath.Join(t.TempDir(), "rakuten.db")}
+
+	if err := migrate(ctx, append([]string{"status"}, flags...)); err == nil {
+		t.Fatal("expected status to fail before the schema exists")
+	}
+	if err := migrate(ctx, append([]string{"up"}, flags...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(ctx, append([]string{"status"}, flags...)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		append(flags, "up"),
+		{"down"},
+		{"up", "status"},
+	} {
+		if err := migrate(ctx, args); err == nil {
+			t.Errorf("%v: expected a usage error", args)
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package rakuten
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/events"
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/tracing"
+)
+
+var (
+	ErrSelfApproval = errors.New("changes must be decided by someone other than their author")
+	ErrDecided      = errors.New("approval already decided")
+)
+
+// ingestionMaker is the maker of the approvals of ingested rates.
+const ingestionMaker = "ingestion"
+
+// latestWindow is how many days GetCurrencyRate looks back at a time for
+// the latest approved publication.
+const latestWindow = 31
+
+// Approval is a change to the published rates and the decision on it.
+type Approval struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Date        string     `json:"date"`
+	OverrideID  string     `json:"override_id,omitempty"`
+	RequestedBy string     `json:"requested_by"`
+	RequestedAt time.Time  `json:"requested_at"`
+	Status      string     `json:"status"`
+	DecidedBy   string     `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+}
+
+type GetApprovalsRequest struct {
+	Kind      string
+	Status    string
+	StartDate time.Time
+	EndDate   time.Time
+	Limit     int
+}
+
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+	// Approve, or reject when false, DecidedBy and DecidedByKey are set by
+	// the caller, the latter two from the name and ID of the authenticated
+	// key.
+	Approve      bool   `json:"-"`
+	DecidedBy    string `json:"-"`
+	DecidedByKey string `json:"-"`
+}
+
+func (h *Handler) GetApprovals(ctx context.Context, req *GetApprovalsRequest) (_ []Approval, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetApprovals")
+	defer func() { tracing.End(span, err) }()
+
+	approvals, err := h.Approvals.GetApprovals(ctx, storage.ApprovalFilter{
+		Kind:      req.Kind,
+		Status:    req.Status,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]Approval, 0, len(approvals))
+	for _, approval := range approvals {
+		response = append(response, toApproval(approval))
+	}
+	return response, nil
+}
+
+// GetApproval returns storage.ErrNotFound for unknown ids.
+func (h *Handler) GetApproval(ctx context.Context, id string) (_ *Approval, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetApproval")
+	defer func() { tracing.End(span, err) }()
+
+	approval, err := h.Approvals.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	response := toApproval(approval)
+	return &response, nil
+}
+
+// DecideApproval approves or rejects a pending change. The maker of the
+// change may not decide it. Approving a publication that becomes the
+// latest approved one sends a RatesPublished event on h.Events, unless an
+// earlier change to its date was approved already. Approving the deletion
+// of an override deletes it.
+func (h *Handler) DecideApproval(ctx context.Context, id string, req *DecisionRequest) (_ *Approval, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.DecideApproval")
+	defer func() { tracing.End(span, err) }()
+
+	approval, err := h.Approvals.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.Status != storage.ApprovalPending {
+		return nil, ErrDecided
+	}
+	if selfApproval(approval, req) {
+		return nil, ErrSelfApproval
+	}
+
+	// a publication is new unless a change to its date was approved before
+	published := false
+	if req.Approve && approval.Kind == storage.ApprovalPublication && h.Events != nil {
+		previous, err := h.Approvals.GetApprovals(ctx, storage.ApprovalFilter{
+			Kind:      storage.ApprovalPublication,
+			Status:    storage.ApprovalApproved,
+			StartDate: approval.Date,
+			EndDate:   approval.Date,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get approvals")
+		}
+		published = len(previous) == 0
+	}
+
+	approval.Status = storage.ApprovalRejected
+	if req.Approve {
+		approval.Status = storage.ApprovalApproved
+	}
+	approval.DecidedBy = req.DecidedBy
+	approval.DecidedByKey = req.DecidedByKey
+	approval.Comment = req.Comment
+	if err := h.Approvals.DecideApproval(ctx, approval); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// decided by someone else meanwhile
+			return nil, ErrDecided
+		}
+		return nil, err
+	}
+
+	logging.With(ctx, h.Logger).Info(approval.Status+" change",
+		zap.String("id", approval.ID),
+		zap.String("kind", approval.Kind),
+		zap.String("date", approval.Date.Format("2006-01-02")),
+		zap.String("requested_by", approval.RequestedBy),
+		zap.String("decided_by", approval.DecidedBy),
+		zap.String("comment", approval.Comment),
+	)
+
+	if req.Approve && approval.Kind == storage.ApprovalOverrideDeletion {
+		err := h.Overrides.DeleteRateOverride(ctx, approval.OverrideID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, errors.Wrap(err, "failed to delete approved rate override")
+		}
+		logging.With(ctx, h.Logger).Info("deleted rate override", zap.String("id", approval.OverrideID), zap.String("author", approval.RequestedBy))
+	}
+	if published {
+		if err := h.publishApproved(ctx, approval.Date); err != nil {
+			return nil, err
+		}
+	}
+	return h.GetApproval(ctx, id)
+}
+
+// publishApproved sends a RatesPublished event for date if it is the latest
+// approved publication.
+func (h *Handler) publishApproved(ctx context.Context, date time.Time) error {
+	rates, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{GetLatestDate: true})
+	if err != nil {
+		return errors.Wrap(err, "failed to get approved currency rates")
+	}
+	if len(rates.Rates) == 0 || !sameDay(rates.Date, date) {
+		return nil
+	}
+	h.Events.Publish(events.RatesPublished{Base: rates.Base, Date: date, Rates: rates.Rates})
+	return nil
+}
+
+// selfApproval reports whether req would have a change decided by its
+// maker. Makers and checkers are told apart by key ID; changes made before
+// key IDs were recorded, and ingested ones, fall back to the maker's name;
+// issued keys cannot be named after the ingestion maker.
+func selfApproval(approval storage.Approval, req *DecisionRequest) bool {
+	if req.DecidedBy == "" || req.DecidedByKey == "" {
+		return true
+	}
+	if approval.RequestedByKey != "" {
+		return req.DecidedByKey == approval.RequestedByKey
+	}
+	return req.DecidedBy == approval.RequestedBy
+}
+
+// requestApprovals records a pending publication approval for each of
+// dates, to be done before their rates are written. It returns nil when
+// approval is not required.
+func (h *Handler) requestApprovals(ctx context.Context, dates []time.Time) ([]string, error) {
+	if h.Approvals == nil {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(dates))
+	for _, date := range dates {
+		id, err := h.Approvals.CreateApproval(ctx, storage.Approval{
+			Kind:        storage.ApprovalPublication,
+			Date:        date,
+			RequestedBy: ingestionMaker,
+		})
+		if err != nil {
+			h.withdrawApprovals(ctx, ids, err)
+			return nil, errors.Wrap(err, "failed to request approval")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// withdrawApprovals rejects the approvals requested for rates that failed
+// to be written, so that they are not left pending.
+func (h *Handler) withdrawApprovals(ctx context.Context, ids []string, cause error) {
+	for _, id := range ids {
+		err := h.Approvals.DecideApproval(ctx, storage.Approval{
+			ID:        id,
+			Status:    storage.ApprovalRejected,
+			DecidedBy: ingestionMaker,
+			Comment:   "not stored: " + cause.Error(),
+		})
+		if err != nil {
+			logging.With(ctx, h.Logger).Error("failed to withdraw approval", zap.String("id", id), zap.Error(err))
+		}
+	}
+}
+
+// requestOverrideApproval records a pending approval of the current
+// version of an override, once written by the key authorKey.
+func (h *Handler) requestOverrideApproval(ctx context.Context, override *Override, authorKey string) error {
+	if h.Approvals == nil {
+		return nil
+	}
+
+	date, err := time.Parse("2006-01-02", override.Date)
+	if err != nil {
+		return errors.Wrap(err, "invalid override date")
+	}
+	_, err = h.Approvals.CreateApproval(ctx, storage.Approval{
+		Kind:           storage.ApprovalOverride,
+		Date:           date,
+		OverrideID:     override.ID,
+		RequestedBy:    override.Author,
+		RequestedByKey: authorKey,
+	})
+	return errors.Wrap(err, "failed to request approval")
+}
+
+// approvedRates returns rates, as stored at asOf or now when it is zero,
+// restricted to the approved publications. The rates of a date are shown as
+// they were stored before its first change that is not approved, so that
+// a pending or rejected correction leaves the approved rates in place and a
+// pending new date is hidden. Dates stored before approval was required
+// have no approvals and are shown as stored. Rates are ordered by date and
+// quote.
+func (h *Handler) approvedRates(ctx context.Context, rates []storage.Rate, quotes []string, asOf time.Time) ([]storage.Rate, error) {
+	if h.Approvals == nil || len(rates) == 0 {
+		return rates, nil
+	}
+
+	start, end := rates[0].Date, rates[0].Date
+	for _, rate := range rates {
+		if rate.Date.Before(start) {
+			start = rate.Date
+		}
+		if rate.Date.After(end) {
+			end = rate.Date
+		}
+	}
+	approvals, err := h.Approvals.GetApprovals(ctx, storage.ApprovalFilter{
+		Kind:      storage.ApprovalPublication,
+		StartDate: start,
+		EndDate:   end,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get approvals")
+	}
+	snapshots := unapprovedSince(approvals, asOf)
+
+	var approved []storage.Rate
+	var pinned []time.Time
+	seen := map[string]bool{}
+	for _, rate := range rates {
+		day := rate.Date.Format("2006-01-02")
+		if _, ok := snapshots[day]; !ok {
+			approved = append(approved, rate)
+		} else if !seen[day] {
+			seen[day] = true
+			pinned = append(pinned, rate.Date)
+		}
+	}
+
+	for _, date := range pinned {
+		// the change was written after it was requested, a millisecond
+		// earlier excludes it at the precision of every store
+		previous, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{
+			Date:   date,
+			Quotes: quotes,
+			AsOf:   snapshots[date.Format("2006-01-02")].Add(-time.Millisecond),
+		})
+		if err != nil {
+			return nil, err
+		}
+		approved = append(approved, previous...)
+	}
+
+	sortRates(approved)
+	return approved, nil
+}
+
+// unapprovedSince returns, for each day of approvals, the moment its first
+// change that was not approved at asOf was requested, leaving out the days
+// whose changes were all approved. approvals are ordered by request.
+func unapprovedSince(approvals []storage.Approval, asOf time.Time) map[string]time.Time {
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+
+	since := map[string]time.Time{}
+	for _, approval := range approvals {
+		if approval.RequestedAt.After(asOf) {
+			continue
+		}
+		day := approval.Date.Format("2006-01-02")
+		if approvedAt(approval, asOf) {
+			delete(since, day)
+		} else if _, ok := since[day]; !ok {
+			since[day] = approval.RequestedAt
+		}
+	}
+	return since
+}
+
+func approvedAt(approval storage.Approval, asOf time.Time) bool {
+	return approval.Status == storage.ApprovalApproved && approval.DecidedAt != nil && !approval.DecidedAt.After(asOf)
+}
+
+// latestApprovedRates returns the rates within filter of the latest
+// publication that has approved rates.
+func (h *Handler) latestApprovedRates(ctx context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+	latest, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true, AsOf: filter.AsOf})
+	if err != nil || len(latest) == 0 {
+		return nil, err
+	}
+
+	end := latest[0].Date
+	for {
+		start := end.AddDate(0, 0, -latestWindow)
+		rates, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{
+			StartDate: start,
+			EndDate:   end,
+			Quotes:    filter.Quotes,
+			AsOf:      filter.AsOf,
+		})
+		if err != nil || len(rates) == 0 {
+			return nil, err
+		}
+
+		rates, err = h.approvedRates(ctx, rates, filter.Quotes, filter.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		if len(rates) > 0 {
+			date := rates[len(rates)-1].Date
+			i := sort.Search(len(rates), func(i int) bool { return !rates[i].Date.Before(date) })
+			return rates[i:], nil
+		}
+		end = start.AddDate(0, 0, -1)
+	}
+}
+
+// getOverrides returns the overrides within filter, restricted, when
+// approval is required, to the approved versions: an override whose
+// current version awaits a decision is replaced by its approved version,
+// if any. Overrides made before approval was required have no approvals
+// and are shown as stored.
+func (h *Handler) getOverrides(ctx context.Context, filter storage.OverrideFilter) ([]storage.RateOverride, error) {
+	if h.Overrides == nil {
+		return nil, nil
+	}
+
+	overrides, err := h.Overrides.GetRateOverrides(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get rate overrides")
+	}
+	if h.Approvals == nil || len(overrides) == 0 {
+		return overrides, nil
+	}
+
+	approvals, err := h.Approvals.GetApprovals(ctx, storage.ApprovalFilter{
+		Kind:      storage.ApprovalOverride,
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get approvals")
+	}
+	asOf := filter.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	// the latest approval of each override, requested after the version
+	// it approves was written
+	approved := map[string]time.Time{}
+	requested := map[string]bool{}
+	for _, approval := range approvals {
+		if !approval.RequestedAt.After(asOf) {
+			requested[approval.OverrideID] = true
+		}
+		if approvedAt(approval, asOf) {
+			approved[approval.OverrideID] = approval.RequestedAt
+		}
+	}
+
+	var visible []storage.RateOverride
+	pinned := map[time.Time]map[string]bool{}
+	for _, override := range overrides {
+		requestedAt, ok := approved[override.ID]
+		switch {
+		case !requested[override.ID]:
+			visible = append(visible, override)
+		case !ok:
+		case !requestedAt.Before(override.RecordedAt):
+			visible = append(visible, override)
+		default:
+			if pinned[requestedAt] == nil {
+				pinned[requestedAt] = map[string]bool{}
+			}
+			pinned[requestedAt][override.ID] = true
+		}
+	}
+
+	for requestedAt, ids := range pinned {
+		previousFilter := filter
+		previousFilter.AsOf = requestedAt
+		previous, err := h.Overrides.GetRateOverrides(ctx, previousFilter)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get rate overrides")
+		}
+		for _, override := range previous {
+			if ids[override.ID] {
+				visible = append(visible, override)
+			}
+		}
+	}
+	return visible, nil
+}
+
+func toApproval(approval storage.Approval) Approval {
+	return Approval{
+		ID:          approval.ID,
+		Kind:        approval.Kind,
+		Date:        approval.Date.Format("2006-01-02"),
+		OverrideID:  approval.OverrideID,
+		RequestedBy: approval.RequestedBy,
+		RequestedAt: approval.RequestedAt,
+		Status:      approval.Status,
+		DecidedBy:   approval.DecidedBy,
+		DecidedAt:   approval.DecidedAt,
+		Comment:     approval.Comment,
+	}
+}
+
+// sameDay compares dates by day since stored dates may not be in UTC.
+func sameDay(a, b time.Time) bool {
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
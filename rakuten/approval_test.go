@@ -0,0 +1,189 @@
+package rakuten
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/syahnur197/rakuten/events"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/sqlite"
+)
+
+func TestHandler_Approvals(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := sqlite.NewStore(db)
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	// the store keeps milliseconds, keep the changes apart
+	tick := func() { time.Sleep(10 * time.Millisecond) }
+
+	h := NewHandler(s)
+	h.Overrides = s
+	ingest := func(date, rate string) {
+		t.Helper()
+		if _, err := h.IngestCurrencyRates(ctx, Rates{Rates: RateList{{Quote: "USD", Rate: rate, Date: date}}}); err != nil {
+			t.Fatal(err)
+		}
+		tick()
+	}
+	usd := func(req *GetCurrencyRateRequest) string {
+		t.Helper()
+		rates, err := h.GetCurrencyRate(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rates.Date.Format("2006-01-02") + " " + rates.Rates["USD"]
+	}
+	pending := func() storage.Approval {
+		t.Helper()
+		approvals, err := s.GetApprovals(ctx, storage.ApprovalFilter{Status: storage.ApprovalPending})
+		if err != nil || len(approvals) != 1 {
+			t.Fatalf("expected one pending approval, got %+v: %v", approvals, err)
+		}
+		return approvals[0]
+	}
+	decide := func(id string, approve bool) {
+		t.Helper()
+		if _, err := h.DecideApproval(ctx, id, &DecisionRequest{Approve: approve, DecidedBy: "lead", DecidedByKey: "key-lead"}); err != nil {
+			t.Fatal(err)
+		}
+		tick()
+	}
+
+	// stored before approval was required
+	ingest("2023-01-04", "1.05")
+	if _, err := h.CreateOverride(ctx, &OverrideRequest{Quote: "GBP", Date: "2023-01-04", Rate: "0.9", Reason: "missing", Author: "ops", AuthorKey: "key-ops"}); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+
+	h.Approvals = s
+	if rates, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{Date: day(4)}); err != nil || rates.Rates["GBP"] != "0.9" {
+		t.Fatalf("expected the override made before approval was required, got %+v: %v", rates, err)
+	}
+	h.Events = events.NewBus()
+	sub := h.Events.Subscribe()
+	defer h.Events.Unsubscribe(sub)
+
+	ingest("2023-01-05", "1.1")
+	if got := usd(&GetCurrencyRateRequest{GetLatestDate: true}); got != "2023-01-04 1.05" {
+		t.Fatalf("expected the latest approved rates while the 5th is pending, got %s", got)
+	}
+	if rates, err := h.GetCurrencyRateRange(ctx, &GetCurrencyRateRangeRequest{}); err != nil || len(rates) != 1 {
+		t.Fatalf("expected the pending date hidden from ranges, got %+v: %v", rates, err)
+	}
+
+	publication := pending()
+	if publication.Kind != storage.ApprovalPublication || !publication.Date.Equal(day(5)) || publication.RequestedBy != "ingestion" {
+		t.Fatalf("unexpected approval %+v", publication)
+	}
+	_, err = h.DecideApproval(ctx, publication.ID, &DecisionRequest{Approve: true, DecidedBy: "ingestion", DecidedByKey: "key-ingestion"})
+	if !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("got %v, want ErrSelfApproval", err)
+	}
+	beforeApproval := time.Now()
+	tick()
+	decide(publication.ID, true)
+
+	if got := usd(&GetCurrencyRateRequest{GetLatestDate: true}); got != "2023-01-05 1.1" {
+		t.Fatalf("expected the approved publication, got %s", got)
+	}
+	select {
+	case e := <-sub.C:
+		if !e.Date.Equal(day(5)) || e.Rates["USD"] != "1.1" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	default:
+		t.Fatal("expected an event for the approved publication")
+	}
+	if got := usd(&GetCurrencyRateRequest{GetLatestDate: true, AsOf: beforeApproval}); got != "2023-01-04 1.05" {
+		t.Fatalf("expected the rates before the approval as of then, got %s", got)
+	}
+	_, err = h.DecideApproval(ctx, publication.ID, &DecisionRequest{Approve: false, DecidedBy: "other", DecidedByKey: "key-other"})
+	if !errors.Is(err, ErrDecided) {
+		t.Fatalf("got %v, want ErrDecided", err)
+	}
+
+	// a rejected correction leaves the approved rate in place
+	ingest("2023-01-05", "1.2")
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.1" {
+		t.Fatalf("expected the approved rate while the correction is pending, got %s", got)
+	}
+	analyzed, err := h.GetAnalyzedCurrencyRate(ctx, &GetAnalyzedCurrencyRateRequest{})
+	if err != nil || analyzed.RatesAnalyzed["USD"].Max != "1.1" {
+		t.Fatalf("expected the analysis of the approved rates, got %+v: %v", analyzed, err)
+	}
+	decide(pending().ID, false)
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.1" {
+		t.Fatalf("expected the approved rate once the correction is rejected, got %s", got)
+	}
+
+	// an override applies once approved, and its approved version while
+	// an update is pending
+	override, err := h.CreateOverride(ctx, &OverrideRequest{Quote: "USD", Date: "2023-01-05", Rate: "1.3", Reason: "feed error", Author: "ops", AuthorKey: "key-ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.1" {
+		t.Fatalf("expected the pending override not applied, got %s", got)
+	}
+	approval := pending()
+	if approval.Kind != storage.ApprovalOverride || approval.OverrideID != override.ID || approval.RequestedBy != "ops" {
+		t.Fatalf("unexpected approval %+v", approval)
+	}
+	decide(approval.ID, true)
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.3" {
+		t.Fatalf("expected the approved override, got %s", got)
+	}
+
+	if _, err := h.UpdateOverride(ctx, override.ID, &OverrideRequest{Rate: "1.4", Reason: "typo", Author: "ops", AuthorKey: "key-ops"}); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.3" {
+		t.Fatalf("expected the approved version of the override, got %s", got)
+	}
+	decide(pending().ID, true)
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.4" {
+		t.Fatalf("expected the approved update, got %s", got)
+	}
+
+	// a deletion applies once approved too
+	deletion, err := h.DeleteOverride(ctx, override.ID, "ops", "key-ops")
+	if err != nil || deletion == nil || deletion.Kind != storage.ApprovalOverrideDeletion || deletion.OverrideID != override.ID {
+		t.Fatalf("expected a pending deletion, got %+v: %v", deletion, err)
+	}
+	tick()
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.4" {
+		t.Fatalf("expected the override to apply while its deletion is pending, got %s", got)
+	}
+	// makers are told apart by key, not by name
+	if _, err := h.DecideApproval(ctx, deletion.ID, &DecisionRequest{Approve: true, DecidedBy: "lead", DecidedByKey: "key-ops"}); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("got %v, want ErrSelfApproval", err)
+	}
+	beforeDeletion := time.Now()
+	tick()
+	decide(deletion.ID, true)
+	if got := usd(&GetCurrencyRateRequest{Date: day(5)}); got != "2023-01-05 1.1" {
+		t.Fatalf("expected the ingested rate once the deletion is approved, got %s", got)
+	}
+	// the ingested rate was recorded long before it applied again
+	if rates, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{Date: day(5)}); err != nil || !rates.LastModified.After(beforeDeletion) {
+		t.Fatalf("expected the rates last modified by the deletion, got %+v: %v", rates, err)
+	}
+	if got := usd(&GetCurrencyRateRequest{Date: day(5), AsOf: beforeDeletion}); got != "2023-01-05 1.4" {
+		t.Fatalf("expected the override as of before the deletion was approved, got %s", got)
+	}
+}
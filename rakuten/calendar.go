@@ -0,0 +1,93 @@
+package rakuten
+
+import (
+	"time"
+	_ "time/tzdata" // the container image has no zoneinfo
+)
+
+// publicationHour is when, in ecbLocation, the ECB publishes the reference
+// rates of the day.
+const publicationHour = 16
+
+var ecbLocation = func() *time.Location {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		// shouldn't happen, the zone database is embedded
+		panic(err)
+	}
+	return loc
+}()
+
+// IsBusinessDay reports whether the ECB publishes reference rates on date,
+// i.e. whether it is a TARGET business day. TARGET is closed on weekends,
+// New Year's Day, Good Friday, Easter Monday, 1 May and 25 and 26 December.
+func IsBusinessDay(date time.Time) bool {
+	y, m, d := date.Date()
+
+	switch date.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+
+	switch {
+	case m == time.January && d == 1,
+		m == time.May && d == 1,
+		m == time.December && (d == 25 || d == 26):
+		return false
+	}
+
+	easter := easterSunday(y)
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return !day.Equal(easter.AddDate(0, 0, -2)) && !day.Equal(easter.AddDate(0, 0, 1))
+}
+
+// ExpectedPublicationDate returns the latest business day whose rates are
+// published at now. Rates appear at 16:00 CET, so before that on a
+// business day the previous one is expected. The date is at midnight UTC,
+// like stored publication dates.
+func ExpectedPublicationDate(now time.Time) time.Time {
+	local := now.In(ecbLocation)
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	if local.Hour() < publicationHour {
+		date = date.AddDate(0, 0, -1)
+	}
+
+	for !IsBusinessDay(date) {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+// MissedPublications returns the number of business days after latest, up
+// to and including ExpectedPublicationDate(now).
+func MissedPublications(latest, now time.Time) int {
+	expected := ExpectedPublicationDate(now)
+
+	missed := 0
+	for date := latest.AddDate(0, 0, 1); !date.After(expected); date = date.AddDate(0, 0, 1) {
+		if IsBusinessDay(date) {
+			missed++
+		}
+	}
+	return missed
+}
+
+// easterSunday returns the date of Easter Sunday in year of the Gregorian
+// calendar, using the anonymous Gregorian algorithm.
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,80 @@
+package rakuten
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	tests := map[string]bool{
+		"2023-01-02": true,  // Monday
+		"2023-01-07": false, // Saturday
+		"2023-01-08": false, // Sunday
+		"2023-01-01": false, // New Year's Day
+		"2023-04-07": false, // Good Friday
+		"2023-04-10": false, // Easter Monday
+		"2024-03-29": false, // Good Friday
+		"2024-04-01": false, // Easter Monday
+		"2023-04-06": true,
+		"2023-05-01": false,
+		"2023-12-25": false,
+		"2023-12-26": false,
+		"2023-12-27": true,
+	}
+
+	for day, want := range tests {
+		if got := IsBusinessDay(date(day)); got != want {
+			t.Errorf("IsBusinessDay(%s) = %v, want %v", day, got, want)
+		}
+	}
+}
+
+func TestExpectedPublicationDate(t *testing.T) {
+	tests := []struct {
+		now  string
+		want string
+	}{
+		// 16:00 CET is 15:00 UTC in winter and 14:00 UTC in summer
+		{now: "2023-01-05T14:59:00Z", want: "2023-01-04"},
+		{now: "2023-01-05T15:00:00Z", want: "2023-01-05"},
+		{now: "2023-06-05T14:00:00Z", want: "2023-06-05"},
+		{now: "2023-01-09T10:00:00Z", want: "2023-01-06"}, // Monday morning
+		{now: "2023-04-11T10:00:00Z", want: "2023-04-06"}, // after Easter
+		{now: "2023-01-07T18:00:00Z", want: "2023-01-06"}, // Saturday
+	}
+
+	for _, tt := range tests {
+		now, err := time.Parse(time.RFC3339, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ExpectedPublicationDate(now); !got.Equal(date(tt.want)) {
+			t.Errorf("ExpectedPublicationDate(%s) = %s, want %s", tt.now, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestMissedPublications(t *testing.T) {
+	now := time.Date(2023, 4, 12, 16, 0, 0, 0, time.UTC) // Wednesday after Easter
+
+	tests := map[string]int{
+		"2023-04-12": 0,
+		"2023-04-11": 1,
+		"2023-04-06": 2, // Good Friday and Easter Monday are skipped
+		"2023-04-03": 5,
+	}
+
+	for latest, want := range tests {
+		if got := MissedPublications(date(latest), now); got != want {
+			t.Errorf("MissedPublications(%s) = %d, want %d", latest, got, want)
+		}
+	}
+}
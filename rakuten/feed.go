@@ -0,0 +1,511 @@
+package rakuten
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// Namespaces and sender of the ECB reference rates documents.
+const (
+	gesmesNamespace    = "http://www.gesmes.org/xml/2002-08-01"
+	eurofxrefNamespace = "http://www.ecb.int/vocabulary/2002-08-01/eurofxref"
+	ecbSender          = "European Central Bank"
+)
+
+// ErrInvalidFeed is wrapped by every FeedError.
+var ErrInvalidFeed = errors.New("invalid ECB reference rates document")
+
+// FeedError locates a problem in an ECB reference rates document.
+type FeedError struct {
+	// Line is 1-based.
+	Line int
+	// Element is the path of the offending element, e.g.
+	// /gesmes:Envelope/Cube/Cube[@time='2023-01-05'], empty when the
+	// document has no root element yet.
+	Element string
+	Msg     string
+}
+
+func (e *FeedError) Error() string {
+	if e.Element == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Element, e.Msg)
+}
+
+func (e *FeedError) Unwrap() error { return ErrInvalidFeed }
+
+var (
+	currencyFormat = regexp.MustCompile(`^[A-Z]{3}$`)
+	// rates may be negative or zero, the data quality rules reject them
+	rateFormat = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+)
+
+// feedParser reads an ECB reference rates document token by token,
+// checking it against the layout the ECB publishes:
+//
+//	<gesmes:Envelope>
+//		<gesmes:subject>Reference rates</gesmes:subject>
+//		<gesmes:Sender><gesmes:name>European Central Bank</gesmes:name></gesmes:Sender>
+//		<Cube>
+//			<Cube time="2023-01-05"><Cube currency="USD" rate="1.0599"/>...</Cube>
+//			...
+//		</Cube>
+//	</gesmes:Envelope>
+type feedParser struct {
+	d     *xml.Decoder
+	lines *lineReader
+	// path holds the open elements, as they appear in FeedError.Element
+	path []string
+	// offset of the token last returned by next
+	offset int64
+
+	rates RateList
+}
+
+func parseFeed(r io.Reader) (RateList, error) {
+	lines := &lineReader{r: bufio.NewReader(r)}
+	p := &feedParser{d: xml.NewDecoder(lines), lines: lines}
+	p.d.Strict = true
+	if err := p.document(); err != nil {
+		return nil, err
+	}
+	return p.rates, nil
+}
+
+func (p *feedParser) document() error {
+	start, ok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return p.errorf("document has no root element")
+	}
+	if start.Name.Space != gesmesNamespace || start.Name.Local != "Envelope" {
+		return p.errorf("root element is %s, want gesmes:Envelope", name(start.Name))
+	}
+	if err := p.envelope(start); err != nil {
+		return err
+	}
+
+	// only comments and white space may follow the root element
+	for {
+		p.offset = p.d.InputOffset()
+		tok, err := p.d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return p.syntaxError(err)
+		}
+		switch tok := tok.(type) {
+		case xml.Comment:
+		case xml.CharData:
+			if len(strings.TrimSpace(string(tok))) > 0 {
+				return p.errorf("unexpected text after the root element")
+			}
+		default:
+			return p.errorf("unexpected content after the root element")
+		}
+	}
+}
+
+func (p *feedParser) envelope(start xml.StartElement) error {
+	p.push(start.Name, "")
+	if err := p.attrs(start); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for {
+		child, ok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		key := name(child.Name)
+		if seen[key] {
+			return p.errorf("%s is repeated", key)
+		}
+		seen[key] = true
+
+		switch {
+		case child.Name.Space == gesmesNamespace && child.Name.Local == "subject":
+			p.push(child.Name, "")
+			subject, err := p.text(child)
+			if err != nil {
+				return err
+			}
+			if subject == "" {
+				return p.errorf("subject is empty")
+			}
+			p.pop()
+		case child.Name.Space == gesmesNamespace && child.Name.Local == "Sender":
+			if err := p.sender(child); err != nil {
+				return err
+			}
+		case child.Name.Space == eurofxrefNamespace && child.Name.Local == "Cube":
+			if err := p.cube(child); err != nil {
+				return err
+			}
+		default:
+			return p.unexpected(child.Name)
+		}
+	}
+
+	for _, required := range []string{"gesmes:subject", "gesmes:Sender", "Cube"} {
+		if !seen[required] {
+			return p.errorf("%s is missing", required)
+		}
+	}
+	p.pop()
+	return nil
+}
+
+func (p *feedParser) sender(start xml.StartElement) error {
+	p.push(start.Name, "")
+	if err := p.attrs(start); err != nil {
+		return err
+	}
+
+	var sender string
+	var offset int64
+	seen := false
+	for {
+		child, ok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if child.Name.Space != gesmesNamespace || child.Name.Local != "name" {
+			return p.unexpected(child.Name)
+		}
+		if seen {
+			return p.errorf("gesmes:name is repeated")
+		}
+		seen = true
+		offset = p.offset
+
+		p.push(child.Name, "")
+		if sender, err = p.text(child); err != nil {
+			return err
+		}
+		p.pop()
+	}
+
+	if !seen {
+		return p.errorf("gesmes:name is missing")
+	}
+	if sender != ecbSender {
+		p.offset = offset
+		return p.errorf("sender is %q, want %q", sender, ecbSender)
+	}
+	p.pop()
+	return nil
+}
+
+// cube reads the Cube holding a Cube per publication date.
+func (p *feedParser) cube(start xml.StartElement) error {
+	p.push(start.Name, "")
+	if err := p.attrs(start); err != nil {
+		return err
+	}
+
+	for {
+		child, ok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if child.Name.Space != eurofxrefNamespace || child.Name.Local != "Cube" {
+			return p.unexpected(child.Name)
+		}
+		if err := p.day(child); err != nil {
+			return err
+		}
+	}
+
+	p.pop()
+	return nil
+}
+
+// day reads the Cube of the rates published on a date.
+func (p *feedParser) day(start xml.StartElement) error {
+	p.push(start.Name, attrValue(start, "time", "time"))
+	if err := p.attrs(start, "time"); err != nil {
+		return err
+	}
+	date := attrValue(start, "time", "")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return p.errorf("time %q is not a date, must be YYYY-MM-DD", date)
+	}
+
+	rates := 0
+	for {
+		child, ok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if child.Name.Space != eurofxrefNamespace || child.Name.Local != "Cube" {
+			return p.unexpected(child.Name)
+		}
+		if err := p.rate(child, date); err != nil {
+			return err
+		}
+		rates++
+	}
+
+	if rates == 0 {
+		return p.errorf("publication has no rates")
+	}
+	p.pop()
+	return nil
+}
+
+// rate reads the Cube of a currency's rate on date.
+func (p *feedParser) rate(start xml.StartElement, date string) error {
+	p.push(start.Name, attrValue(start, "currency", "currency"))
+	if err := p.attrs(start, "currency", "rate"); err != nil {
+		return err
+	}
+	currency, rate := attrValue(start, "currency", ""), attrValue(start, "rate", "")
+	if !currencyFormat.MatchString(currency) {
+		return p.errorf("currency %q is not a three letter code", currency)
+	}
+	if !rateFormat.MatchString(rate) {
+		return p.errorf("rate %q is not a decimal number", rate)
+	}
+	if _, err := storage.ParseDecimal(rate); err != nil {
+		return p.errorf("rate %q is too large to store", rate)
+	}
+
+	if child, ok, err := p.next(); err != nil {
+		return err
+	} else if ok {
+		return p.unexpected(child.Name)
+	}
+
+	p.rates = append(p.rates, Rate{Quote: currency, Rate: rate, Date: date})
+	p.pop()
+	return nil
+}
+
+// text returns the trimmed text of the element start, which must not have
+// attributes or children.
+func (p *feedParser) text(start xml.StartElement) (string, error) {
+	if err := p.attrs(start); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for {
+		p.offset = p.d.InputOffset()
+		tok, err := p.d.Token()
+		if err != nil {
+			return "", p.syntaxError(err)
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.Comment:
+		case xml.EndElement:
+			return strings.TrimSpace(text.String()), nil
+		case xml.StartElement:
+			return "", p.unexpected(tok.Name)
+		default:
+			return "", p.errorf("unexpected content")
+		}
+	}
+}
+
+// next returns the next child element of the current one, or false once
+// it ends. Only comments and white space may separate elements.
+func (p *feedParser) next() (xml.StartElement, bool, error) {
+	for {
+		p.offset = p.d.InputOffset()
+		tok, err := p.d.Token()
+		if err == io.EOF {
+			if len(p.path) == 0 {
+				return xml.StartElement{}, false, nil
+			}
+			return xml.StartElement{}, false, p.errorf("document ends before the element is closed")
+		}
+		if err != nil {
+			return xml.StartElement{}, false, p.syntaxError(err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return tok, true, nil
+		case xml.EndElement:
+			return xml.StartElement{}, false, nil
+		case xml.CharData:
+			if text := strings.TrimSpace(string(tok)); text != "" {
+				// locate the text rather than the white space before it
+				p.offset += int64(strings.Index(string(tok), text))
+				return xml.StartElement{}, false, p.errorf("unexpected text %q", truncate(text))
+			}
+		case xml.Comment:
+		case xml.ProcInst:
+			// only the XML declaration, before the root element
+			if len(p.path) > 0 || tok.Target != "xml" {
+				return xml.StartElement{}, false, p.errorf("unexpected processing instruction %s", tok.Target)
+			}
+		case xml.Directive:
+			return xml.StartElement{}, false, p.errorf("unexpected directive, document type declarations are not allowed")
+		}
+	}
+}
+
+// attrs checks that start has exactly the attributes named, besides
+// namespace declarations, each given once.
+func (p *feedParser) attrs(start xml.StartElement, names ...string) error {
+	seen := map[string]bool{}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		key := name(attr.Name)
+		known := attr.Name.Space == ""
+		if known {
+			known = false
+			for _, name := range names {
+				known = known || name == attr.Name.Local
+			}
+		}
+		if !known {
+			return p.errorf("unexpected attribute %s", key)
+		}
+		if seen[key] {
+			return p.errorf("attribute %s is repeated", key)
+		}
+		seen[key] = true
+	}
+	for _, name := range names {
+		if !seen[name] {
+			return p.errorf("attribute %s is missing", name)
+		}
+	}
+	return nil
+}
+
+func (p *feedParser) unexpected(n xml.Name) error {
+	if n.Space == "" {
+		return p.errorf("unexpected element %s without a namespace", n.Local)
+	}
+	return p.errorf("unexpected element %s", name(n))
+}
+
+func (p *feedParser) push(n xml.Name, key string) {
+	p.path = append(p.path, name(n)+key)
+}
+
+func (p *feedParser) pop() {
+	p.path = p.path[:len(p.path)-1]
+}
+
+// errorf reports a problem with the token last read.
+func (p *feedParser) errorf(format string, args ...interface{}) error {
+	return &FeedError{Line: p.lines.line(p.offset), Element: p.element(), Msg: fmt.Sprintf(format, args...)}
+}
+
+// syntaxError locates an error of the decoder, unless reading failed.
+func (p *feedParser) syntaxError(err error) error {
+	if p.lines.err != nil && err == p.lines.err {
+		return err
+	}
+	var syntax *xml.SyntaxError
+	if errors.As(err, &syntax) {
+		return &FeedError{Line: syntax.Line, Element: p.element(), Msg: syntax.Msg}
+	}
+	// e.g. an unsupported encoding
+	return &FeedError{Line: p.lines.line(p.d.InputOffset()), Element: p.element(), Msg: strings.TrimPrefix(err.Error(), "xml: ")}
+}
+
+func (p *feedParser) element() string {
+	if len(p.path) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(p.path, "/")
+}
+
+// name writes n with the prefixes of the ECB documents.
+func name(n xml.Name) string {
+	switch n.Space {
+	case gesmesNamespace:
+		return "gesmes:" + n.Local
+	case eurofxrefNamespace, "":
+		return n.Local
+	}
+	return "{" + n.Space + "}" + n.Local
+}
+
+// attrValue returns the value of the unqualified attribute local of start,
+// formatted as an XPath predicate when key is not empty.
+func attrValue(start xml.StartElement, local, key string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			if key != "" {
+				return fmt.Sprintf("[@%s='%s']", key, truncate(attr.Value))
+			}
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func truncate(s string) string {
+	if len(s) > 32 {
+		return s[:32] + "..."
+	}
+	return s
+}
+
+// lineReader records where the lines of what it reads start, to turn
+// decoder offsets into line numbers.
+type lineReader struct {
+	r      *bufio.Reader
+	offset int64
+	// newlines holds the offset of every newline read
+	newlines []int64
+	// err is the last error reading, other than io.EOF
+	err error
+}
+
+func (l *lineReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	if err != nil && err != io.EOF {
+		l.err = err
+	}
+	for i, c := range b[:n] {
+		if c == '\n' {
+			l.newlines = append(l.newlines, l.offset+int64(i))
+		}
+	}
+	l.offset += int64(n)
+	return n, err
+}
+
+func (l *lineReader) line(offset int64) int {
+	return sort.Search(len(l.newlines), func(i int) bool { return l.newlines[i] >= offset }) + 1
+}
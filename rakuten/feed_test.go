@@ -0,0 +1,140 @@
+package rakuten
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+func TestParseCurrencyRates(t *testing.T) {
+	f, err := os.Open("testdata/eurofxref.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rates, err := ParseCurrencyRates(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates.Rates) != 6 {
+		t.Fatalf("expected 6 rates, got %+v", rates.Rates)
+	}
+	if got := rates.Rates[1]; got != (Rate{Quote: "JPY", Rate: "141.63", Date: "2023-01-05"}) {
+		t.Fatalf("unexpected rate %+v", got)
+	}
+	if got := rates.Rates[5]; got != (Rate{Quote: "GBP", Rate: "0.87953", Date: "2023-01-04"}) {
+		t.Fatalf("unexpected rate %+v", got)
+	}
+}
+
+func TestParseCurrencyRates_Malformed(t *testing.T) {
+	tests := []struct {
+		file string
+		line int
+		want string
+	}{
+		{"bad_currency.xml", 10, `Cube[@currency='yen']: currency "yen" is not a three letter code`},
+		{"bad_date.xml", 13, `time "04/01/2023" is not a date`},
+		{"bad_rate.xml", 10, `Cube[@time='2023-01-05']/Cube[@currency='JPY']: rate "141,63" is not a decimal number`},
+		{"doctype.xml", 2, "document type declarations are not allowed"},
+		{"duplicate_attribute.xml", 9, "attribute rate is repeated"},
+		{"empty.xml", 1, "document has no root element"},
+		{"empty_publication.xml", 9, "Cube[@time='2023-01-06']: publication has no rates"},
+		{"exponent_rate.xml", 16, `rate "8.7953e-1" is not a decimal number`},
+		{"extra_attribute.xml", 9, "unexpected attribute base"},
+		{"latin1.xml", 1, `encoding "ISO-8859-1"`},
+		{"missing_rate.xml", 14, "Cube[@currency='USD']: attribute rate is missing"},
+		{"missing_sender.xml", 16, "/gesmes:Envelope: gesmes:Sender is missing"},
+		{"missing_subject.xml", 18, "gesmes:subject is missing"},
+		{"missing_time.xml", 13, "/gesmes:Envelope/Cube/Cube: attribute time is missing"},
+		{"nested_rate.xml", 11, "Cube[@currency='GBP']: unexpected element Cube"},
+		{"overflowing_rate.xml", 15, `Cube[@currency='JPY']: rate "14062000000.5" is too large to store`},
+		{"no_namespace.xml", 7, "unexpected element Cube without a namespace"},
+		{"not_xml.xml", 1, "unexpected text"},
+		{"text_in_cube.xml", 10, `unexpected text "JPY 141.63"`},
+		{"trailing_content.xml", 20, "unexpected content after the root element"},
+		{"truncated.xml", 15, "unexpected EOF"},
+		{"unclosed.xml", 18, "unexpected EOF"},
+		{"undefined_entity.xml", 9, "invalid character entity &lol;"},
+		{"wrong_namespace.xml", 7, "unexpected element {http://example.com/rates}Cube"},
+		{"wrong_root.xml", 2, "root element is gesmes:Document, want gesmes:Envelope"},
+		{"wrong_sender.xml", 5, `sender is "Bank of Nowhere"`},
+	}
+
+	files, err := filepath.Glob("testdata/malformed/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(tests) {
+		t.Fatalf("%d malformed feeds, %d expected errors", len(files), len(tests))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc, err := os.ReadFile(filepath.Join("testdata/malformed", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rates, err := ParseCurrencyRates(bytes.NewReader(doc))
+			var feedErr *FeedError
+			if !errors.As(err, &feedErr) || !errors.Is(err, ErrInvalidFeed) {
+				t.Fatalf("got %v, want a FeedError", err)
+			}
+			if feedErr.Line != tt.line || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want line %d: ...%s", err, tt.line, tt.want)
+			}
+			if len(rates.Rates) != 0 {
+				t.Errorf("expected no rates from a malformed feed, got %+v", rates.Rates)
+			}
+		})
+	}
+}
+
+func FuzzParseCurrencyRates(f *testing.F) {
+	seeds, err := filepath.Glob("testdata/malformed/*.xml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range append(seeds, "testdata/eurofxref.xml") {
+		doc, err := os.ReadFile(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(doc)
+	}
+
+	rateFormat := regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	f.Fuzz(func(t *testing.T, doc []byte) {
+		rates, err := ParseCurrencyRates(bytes.NewReader(doc))
+		if err != nil {
+			var feedErr *FeedError
+			if !errors.As(err, &feedErr) {
+				t.Fatalf("got %v, want a FeedError", err)
+			}
+			if feedErr.Line < 1 || feedErr.Line > bytes.Count(doc, []byte("\n"))+1 {
+				t.Fatalf("line %d is outside the document: %v", feedErr.Line, err)
+			}
+			return
+		}
+
+		for _, rate := range rates.Rates {
+			if _, err := ConvertToStoreRate(rate); err != nil {
+				t.Fatalf("parsed a rate that cannot be stored: %v", err)
+			}
+			if _, err := storage.ParseDecimal(rate.Rate); err != nil {
+				t.Fatalf("parsed a rate that cannot be stored: %v", err)
+			}
+			if len(rate.Quote) != 3 || !rateFormat.MatchString(rate.Rate) {
+				t.Fatalf("parsed an invalid rate %+v", rate)
+			}
+		}
+	})
+}
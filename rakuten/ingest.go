@@ -0,0 +1,283 @@
+package rakuten
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/events"
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/tracing"
+)
+
+// IngestResult counts the rates an ingestion wrote and rejected.
+type IngestResult struct {
+	// Inserted rates are of publication dates not stored before.
+	Inserted int
+	// Updated rates are new versions of stored rates the feed corrects.
+	Updated int
+	// Rejected rates were quarantined by Handler.Quality.
+	Rejected int
+}
+
+// Written is the number of rates stored, inserted or updated.
+func (r IngestResult) Written() int {
+	return r.Inserted + r.Updated
+}
+
+// IngestCurrencyRates stores every publication in ratesList that is newer
+// than the latest stored publication date, along with the rates of stored
+// publications that ratesList corrects, in a single batch, so that a
+// failure stores none of them. Corrections become new versions of the
+// stored rates and are counted as updated. A RatesPublished event is then
+// sent on h.Events for each new date, oldest first, unless approval is
+// required: every date written then awaits approval, and the event is sent
+// by DecideApproval instead.
+func (h *Handler) IngestCurrencyRates(ctx context.Context, ratesList Rates) (_ IngestResult, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.IngestCurrencyRates")
+	defer func() { tracing.End(span, err) }()
+
+	all, allDates, err := groupByDate(ratesList, func(time.Time) bool { return true })
+	if err != nil || len(allDates) == 0 {
+		return IngestResult{}, err
+	}
+
+	existing, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{StartDate: h.historyStart(allDates[0])})
+	if err != nil {
+		return IngestResult{}, errors.Wrap(err, "failed to get stored currency rates")
+	}
+	var latest time.Time
+	stored := map[string]string{}
+	for _, rate := range existing {
+		if rate.Date.After(latest) {
+			latest = rate.Date
+		}
+		stored[rateKey(rate.Date, rate.Quote)] = rate.Rate
+	}
+
+	var rates []storage.Rate
+	for _, date := range allDates {
+		if date.After(latest) {
+			rates = append(rates, all[date]...)
+			continue
+		}
+		for _, rate := range all[date] {
+			value, ok := stored[rateKey(date, rate.Quote)]
+			if !ok {
+				continue
+			}
+			if normalized, err := storage.NormalizeDecimal(rate.Rate); err != nil || normalized != value {
+				rates = append(rates, rate)
+			}
+		}
+	}
+
+	rates, rejected, err := h.screen(ctx, rates, existing)
+	if err != nil {
+		return IngestResult{}, err
+	}
+	if err := h.storeForApproval(ctx, rates, datesOf(rates)); err != nil {
+		return IngestResult{Rejected: rejected}, err
+	}
+
+	byDate := map[time.Time][]storage.Rate{}
+	var dates []time.Time
+	result := IngestResult{Rejected: rejected}
+	for _, rate := range rates {
+		if !rate.Date.After(latest) {
+			result.Updated++
+			continue
+		}
+		result.Inserted++
+		if len(byDate[rate.Date]) == 0 {
+			dates = append(dates, rate.Date)
+		}
+		byDate[rate.Date] = append(byDate[rate.Date], rate)
+	}
+	if result.Updated > 0 {
+		logging.With(ctx, h.Logger).Info("revised currency rates", zap.Int("rates", result.Updated))
+	}
+
+	for _, date := range dates {
+		published := events.RatesPublished{Base: "EUR", Date: date, Rates: map[string]string{}}
+		for _, rate := range byDate[date] {
+			published.Rates[rate.Quote] = rate.Rate
+		}
+
+		logging.With(ctx, h.Logger).Info("stored publication",
+			zap.String("date", date.Format("2006-01-02")),
+			zap.Int("rates", len(published.Rates)),
+		)
+
+		if h.Events != nil && h.Approvals == nil {
+			h.Events.Publish(published)
+		}
+	}
+
+	return result, nil
+}
+
+// BackfillCurrencyRates stores the publications in ratesList dated from
+// start to end inclusive, either of which may be zero for no bound, that
+// have no stored rates yet in a single batch. Every rate written is
+// inserted, stored rates are never updated. Unlike IngestCurrencyRates it
+// fills dates before the latest stored one, and it publishes no events
+// since the rates are not new. The dates written await approval when it
+// is required.
+func (h *Handler) BackfillCurrencyRates(ctx context.Context, ratesList Rates, start, end time.Time) (_ IngestResult, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.BackfillCurrencyRates")
+	defer func() { tracing.End(span, err) }()
+
+	existing, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{StartDate: h.historyStart(start), EndDate: end})
+	if err != nil {
+		return IngestResult{}, errors.Wrap(err, "failed to get stored currency rates")
+	}
+	// keyed by day, stored dates may not be in UTC
+	stored := map[string]bool{}
+	for _, rate := range existing {
+		stored[rate.Date.Format("2006-01-02")] = true
+	}
+
+	byDate, dates, err := groupByDate(ratesList, func(date time.Time) bool {
+		return !stored[date.Format("2006-01-02")] && !date.Before(start) && (end.IsZero() || !date.After(end))
+	})
+	if err != nil {
+		return IngestResult{}, err
+	}
+
+	rates, rejected, err := h.screen(ctx, flatten(byDate, dates), existing)
+	if err != nil {
+		return IngestResult{}, err
+	}
+	dates = datesOf(rates)
+	if err := h.storeForApproval(ctx, rates, dates); err != nil {
+		return IngestResult{Rejected: rejected}, err
+	}
+
+	logging.With(ctx, h.Logger).Info("backfilled currency rates",
+		zap.Int("dates", len(dates)),
+		zap.Int("rates", len(rates)),
+	)
+	return IngestResult{Inserted: len(rates), Rejected: rejected}, nil
+}
+
+// historyStart returns where the stored rates read to ingest rates dated
+// from start begin: early enough for h.Quality to compare the first of them
+// with JumpWindow previous changes, weekends and holidays included.
+func (h *Handler) historyStart(start time.Time) time.Time {
+	if h.Quality == nil || start.IsZero() {
+		return start
+	}
+	return start.AddDate(0, 0, -2*(h.Quality.JumpWindow+1))
+}
+
+// screen applies h.Quality, when set, to rates about to be stored, with
+// history the stored rates they are compared with. It quarantines the
+// rates that fail and returns the others along with how many failed.
+func (h *Handler) screen(ctx context.Context, rates, history []storage.Rate) ([]storage.Rate, int, error) {
+	if h.Quality == nil {
+		return rates, 0, nil
+	}
+
+	accepted, quarantined := h.Quality.screen(rates, history, time.Now())
+	if len(quarantined) == 0 {
+		return accepted, 0, nil
+	}
+	if h.Quarantine != nil {
+		if err := h.Quarantine.QuarantineRates(ctx, quarantined); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to quarantine currency rates")
+		}
+	}
+
+	rejected := len(rates) - len(accepted)
+	logging.With(ctx, h.Logger).Warn("quarantined currency rates",
+		zap.Int("rejected", rejected),
+		zap.Int("reports", len(quarantined)-rejected),
+	)
+	return accepted, rejected, nil
+}
+
+// datesOf returns the dates of rates in order of first appearance.
+func datesOf(rates []storage.Rate) []time.Time {
+	var dates []time.Time
+	seen := map[time.Time]bool{}
+	for _, rate := range rates {
+		if !seen[rate.Date] {
+			seen[rate.Date] = true
+			dates = append(dates, rate.Date)
+		}
+	}
+	return dates
+}
+
+// storeForApproval writes rates, requesting the approval of each of dates
+// first when approval is required.
+func (h *Handler) storeForApproval(ctx context.Context, rates []storage.Rate, dates []time.Time) error {
+	ids, err := h.requestApprovals(ctx, dates)
+	if err != nil {
+		return err
+	}
+	if err := h.Storage.CreateCurrencyRates(ctx, rates); err != nil {
+		h.withdrawApprovals(ctx, ids, err)
+		return err
+	}
+	return nil
+}
+
+// groupByDate converts ratesList to storage rates, keeps those whose date
+// satisfies keep and groups them by date, oldest first.
+func groupByDate(ratesList Rates, keep func(time.Time) bool) (map[time.Time][]storage.Rate, []time.Time, error) {
+	byDate := map[time.Time][]storage.Rate{}
+	for _, rate := range ratesList.Rates {
+		if rate.Base == "" {
+			rate.Base = "EUR"
+		}
+
+		storageRate, err := ConvertToStoreRate(rate)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !keep(storageRate.Date) {
+			continue
+		}
+		byDate[storageRate.Date] = append(byDate[storageRate.Date], storageRate)
+	}
+
+	dates := make([]time.Time, 0, len(byDate))
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	return byDate, dates, nil
+}
+
+// flatten returns the rates of every date in dates, in that order.
+func flatten(byDate map[time.Time][]storage.Rate, dates []time.Time) []storage.Rate {
+	var rates []storage.Rate
+	for _, date := range dates {
+		rates = append(rates, byDate[date]...)
+	}
+	return rates
+}
+
+// LatestPublishedDate returns the newest stored publication date, or the
+// zero time when nothing is stored.
+func (h *Handler) LatestPublishedDate(ctx context.Context) (_ time.Time, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.LatestPublishedDate")
+	defer func() { tracing.End(span, err) }()
+
+	rates, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get latest published date")
+	}
+	if len(rates) == 0 {
+		return time.Time{}, nil
+	}
+	return rates[0].Date, nil
+}
@@ -0,0 +1,280 @@
+package rakuten
+
+import (
+	"context"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/tracing"
+)
+
+var ErrInvalidOverride = errors.New("invalid rate override")
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Override is a manual correction of the EUR rate of Quote on Date.
+type Override struct {
+	ID         string    `json:"id"`
+	Base       string    `json:"base"`
+	Quote      string    `json:"quote"`
+	Date       string    `json:"date"`
+	Rate       string    `json:"rate"`
+	Reason     string    `json:"reason"`
+	Author     string    `json:"author"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+type OverrideRequest struct {
+	Quote  string `json:"quote"`
+	Date   string `json:"date"`
+	Rate   string `json:"rate"`
+	Reason string `json:"reason"`
+	// Author and AuthorKey, the name and ID of the authenticated key, are
+	// set by the caller.
+	Author    string `json:"-"`
+	AuthorKey string `json:"-"`
+}
+
+type GetOverridesRequest struct {
+	StartDate time.Time
+	EndDate   time.Time
+	AsOf      time.Time
+}
+
+// CreateOverride validates and stores an override, replacing any current
+// override of the same quote and date.
+func (h *Handler) CreateOverride(ctx context.Context, req *OverrideRequest) (_ *Override, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.CreateOverride")
+	defer func() { tracing.End(span, err) }()
+
+	override := storage.RateOverride{
+		Base:   "EUR",
+		Quote:  strings.ToUpper(req.Quote),
+		Rate:   req.Rate,
+		Reason: req.Reason,
+		Author: req.Author,
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidOverride, "date must be YYYY-MM-DD")
+	}
+	override.Date = date
+
+	if !currencyCode.MatchString(override.Quote) || override.Quote == override.Base {
+		return nil, errors.Wrap(ErrInvalidOverride, "quote must be a three letter currency code other than EUR")
+	}
+	if err := validateOverride(override); err != nil {
+		return nil, err
+	}
+
+	id, err := h.Overrides.CreateRateOverride(ctx, override)
+	if err != nil {
+		return nil, err
+	}
+	h.logOverride(ctx, "created rate override", id, override)
+	return h.overrideChanged(ctx, id, req.AuthorKey)
+}
+
+func (h *Handler) GetOverrides(ctx context.Context, req *GetOverridesRequest) (_ []Override, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetOverrides")
+	defer func() { tracing.End(span, err) }()
+
+	overrides, err := h.Overrides.GetRateOverrides(ctx, storage.OverrideFilter{
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		AsOf:      req.AsOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]Override, 0, len(overrides))
+	for _, override := range overrides {
+		response = append(response, toOverride(override))
+	}
+	return response, nil
+}
+
+// GetOverride returns storage.ErrNotFound for unknown and deleted ids.
+func (h *Handler) GetOverride(ctx context.Context, id string) (_ *Override, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetOverride")
+	defer func() { tracing.End(span, err) }()
+
+	override, err := h.Overrides.GetRateOverride(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	response := toOverride(override)
+	return &response, nil
+}
+
+// UpdateOverride replaces the rate and reason of an override. Its quote and
+// date cannot change, req.Quote and req.Date are ignored.
+func (h *Handler) UpdateOverride(ctx context.Context, id string, req *OverrideRequest) (_ *Override, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.UpdateOverride")
+	defer func() { tracing.End(span, err) }()
+
+	override := storage.RateOverride{ID: id, Rate: req.Rate, Reason: req.Reason, Author: req.Author}
+	if err := validateOverride(override); err != nil {
+		return nil, err
+	}
+
+	if err := h.Overrides.UpdateRateOverride(ctx, override); err != nil {
+		return nil, err
+	}
+	h.logOverride(ctx, "updated rate override", id, override)
+	return h.overrideChanged(ctx, id, req.AuthorKey)
+}
+
+// overrideChanged returns the override with the given id once written,
+// after requesting the approval of its new version by the key authorKey.
+func (h *Handler) overrideChanged(ctx context.Context, id, authorKey string) (*Override, error) {
+	override, err := h.GetOverride(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.requestOverrideApproval(ctx, override, authorKey); err != nil {
+		return nil, err
+	}
+	return override, nil
+}
+
+// DeleteOverride deletes an override, or, when approval is required,
+// requests the approval of its deletion and returns it. The override keeps
+// applying until the deletion is approved. author and authorKey are the
+// name and ID of the authenticated key.
+func (h *Handler) DeleteOverride(ctx context.Context, id, author, authorKey string) (_ *Approval, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.DeleteOverride")
+	defer func() { tracing.End(span, err) }()
+
+	if h.Approvals == nil {
+		if err := h.Overrides.DeleteRateOverride(ctx, id); err != nil {
+			return nil, err
+		}
+		logging.With(ctx, h.Logger).Info("deleted rate override", zap.String("id", id), zap.String("author", author))
+		return nil, nil
+	}
+
+	override, err := h.Overrides.GetRateOverride(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	approvalID, err := h.Approvals.CreateApproval(ctx, storage.Approval{
+		Kind:           storage.ApprovalOverrideDeletion,
+		Date:           override.Date,
+		OverrideID:     id,
+		RequestedBy:    author,
+		RequestedByKey: authorKey,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request approval")
+	}
+	logging.With(ctx, h.Logger).Info("requested rate override deletion", zap.String("id", id), zap.String("author", author))
+	return h.GetApproval(ctx, approvalID)
+}
+
+func (h *Handler) logOverride(ctx context.Context, msg, id string, override storage.RateOverride) {
+	logging.With(ctx, h.Logger).Info(msg,
+		zap.String("id", id),
+		zap.String("rate", override.Rate),
+		zap.String("reason", override.Reason),
+		zap.String("author", override.Author),
+	)
+}
+
+func validateOverride(override storage.RateOverride) error {
+	rate, ok := new(big.Rat).SetString(override.Rate)
+	if !ok || rate.Sign() <= 0 {
+		return errors.Wrap(ErrInvalidOverride, "rate must be a positive number")
+	}
+	if strings.TrimSpace(override.Reason) == "" {
+		return errors.Wrap(ErrInvalidOverride, "reason is required")
+	}
+	if override.Author == "" {
+		return errors.Wrap(ErrInvalidOverride, "author is required")
+	}
+	return nil
+}
+
+func toOverride(override storage.RateOverride) Override {
+	return Override{
+		ID:         override.ID,
+		Base:       override.Base,
+		Quote:      override.Quote,
+		Date:       override.Date.Format("2006-01-02"),
+		Rate:       override.Rate,
+		Reason:     override.Reason,
+		Author:     override.Author,
+		RecordedAt: override.RecordedAt,
+	}
+}
+
+// applyOverrides returns rates with the overrides within filter applied
+// by mergeOverrides.
+func (h *Handler) applyOverrides(ctx context.Context, rates []storage.Rate, filter storage.OverrideFilter) ([]storage.Rate, map[string]bool, error) {
+	if h.Overrides == nil {
+		return rates, nil, nil
+	}
+
+	overrides, err := h.getOverrides(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, overridden := mergeOverrides(rates, overrides)
+	return merged, overridden, nil
+}
+
+// mergeOverrides returns rates with overrides in place of the stored rates
+// they replace, ordered by date and quote, and the keys, as made by
+// rateKey, of the rates that were overridden.
+func mergeOverrides(rates []storage.Rate, overrides []storage.RateOverride) ([]storage.Rate, map[string]bool) {
+	if len(overrides) == 0 {
+		return rates, nil
+	}
+
+	merged := append([]storage.Rate(nil), rates...)
+	index := map[string]int{}
+	for i, rate := range merged {
+		index[rateKey(rate.Date, rate.Quote)] = i
+	}
+
+	overridden := map[string]bool{}
+	for _, override := range overrides {
+		key := rateKey(override.Date, override.Quote)
+		rate := storage.Rate{Base: override.Base, Quote: override.Quote, Rate: override.Rate, Date: override.Date, RecordedAt: override.RecordedAt}
+		if i, ok := index[key]; ok {
+			merged[i] = rate
+		} else {
+			merged = append(merged, rate)
+		}
+		overridden[key] = true
+	}
+
+	sortRates(merged)
+	return merged, overridden
+}
+
+// sortRates orders rates by date and quote.
+func sortRates(rates []storage.Rate) {
+	sort.SliceStable(rates, func(i, j int) bool {
+		if !rates[i].Date.Equal(rates[j].Date) {
+			return rates[i].Date.Before(rates[j].Date)
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+}
+
+// rateKey identifies the rate of quote on date, by day since stored dates
+// may not be in UTC.
+func rateKey(date time.Time, quote string) string {
+	return date.Format("2006-01-02") + " " + quote
+}
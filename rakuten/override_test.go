@@ -0,0 +1,110 @@
+package rakuten
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/memory"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestHandler_Overrides(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+	ctx := context.Background()
+
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	h := NewHandler(memory.New())
+	if _, err := h.IngestCurrencyRates(ctx, Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.05", Date: "2023-01-04"},
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+		{Quote: "JPY", Rate: "140", Date: "2023-01-05"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a correction of the 5th and a rate for the 3rd, which the feed missed
+	overrides := []storage.RateOverride{
+		{Base: "EUR", Quote: "USD", Rate: "1.3", Date: day(3)},
+		{Base: "EUR", Quote: "USD", Rate: "1.2", Date: day(5)},
+	}
+	mockOverrides := mock_storage.NewMockOverrideStore(ctrl)
+	mockOverrides.EXPECT().GetRateOverrides(gAny, gAny).DoAndReturn(func(_ context.Context, filter storage.OverrideFilter) ([]storage.RateOverride, error) {
+		var matched []storage.RateOverride
+		for _, override := range overrides {
+			if (filter.StartDate.IsZero() || !override.Date.Before(filter.StartDate)) && (filter.EndDate.IsZero() || !override.Date.After(filter.EndDate)) {
+				matched = append(matched, override)
+			}
+		}
+		return matched, nil
+	}).AnyTimes()
+	// an override deleted after the rates were ingested
+	deleted := time.Now().Add(time.Minute)
+	mockOverrides.EXPECT().GetRateOverridesChangedAt(gAny, gAny).Return(deleted, nil).AnyTimes()
+	h.Overrides = mockOverrides
+
+	latest, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{GetLatestDate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Rates["USD"] != "1.2" || latest.Rates["JPY"] != "140" || fmt.Sprint(latest.Overridden) != "[USD]" {
+		t.Fatalf("unexpected latest rates %+v", latest)
+	}
+	if !latest.LastModified.Equal(deleted) {
+		t.Fatalf("expected the rates last modified by the override change, got %s", latest.LastModified)
+	}
+
+	missing, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{Date: day(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !missing.Date.Equal(day(3)) || missing.Rates["USD"] != "1.3" {
+		t.Fatalf("expected the override of a missing day, got %+v", missing)
+	}
+
+	responses, err := h.GetCurrencyRateRange(ctx, &GetCurrencyRateRangeRequest{StartDate: day(3), EndDate: day(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 3 || !responses[0].Date.Equal(day(3)) || len(responses[1].Overridden) != 0 {
+		t.Fatalf("unexpected range %+v", responses)
+	}
+
+	analyzed, err := h.GetAnalyzedCurrencyRate(ctx, &GetAnalyzedCurrencyRateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := analyzed.RatesAnalyzed["USD"]; got.Min != "1.05" || got.Max != "1.3" || !got.Overridden {
+		t.Fatalf("unexpected USD analysis %+v", got)
+	}
+	if got := analyzed.RatesAnalyzed["JPY"]; got.Overridden {
+		t.Fatalf("unexpected JPY analysis %+v", got)
+	}
+}
+
+func TestHandler_CreateOverride_Invalid(t *testing.T) {
+	h := NewHandler(memory.New())
+	h.Overrides = mock_storage.NewMockOverrideStore(gomock.NewController(t))
+
+	tests := []OverrideRequest{
+		{Quote: "US", Date: "2023-01-05", Rate: "1.1", Reason: "fix", Author: "ops"},
+		{Quote: "EUR", Date: "2023-01-05", Rate: "1.1", Reason: "fix", Author: "ops"},
+		{Quote: "USD", Date: "05/01/2023", Rate: "1.1", Reason: "fix", Author: "ops"},
+		{Quote: "USD", Date: "2023-01-05", Rate: "-1", Reason: "fix", Author: "ops"},
+		{Quote: "USD", Date: "2023-01-05", Rate: "1.1", Reason: " ", Author: "ops"},
+		{Quote: "USD", Date: "2023-01-05", Rate: "1.1", Reason: "fix"},
+	}
+	for _, req := range tests {
+		req := req
+		if _, err := h.CreateOverride(context.Background(), &req); !errors.Is(err, ErrInvalidOverride) {
+			t.Errorf("%+v: got %v, want ErrInvalidOverride", req, err)
+		}
+	}
+}
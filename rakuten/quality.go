@@ -0,0 +1,304 @@
+package rakuten
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// Data quality rules, see QualityChecks.
+const (
+	// RuleNonPositive rejects rates that are not positive numbers.
+	RuleNonPositive = "non_positive"
+	// RuleUnknownCurrency rejects currencies that are not ISO 4217 codes.
+	RuleUnknownCurrency = "unknown_currency"
+	// RuleJump rejects day-over-day changes more than MaxJump standard
+	// deviations away from the mean of the previous changes.
+	RuleJump = "jump"
+	// RuleMissingCurrency reports the currencies of the previous
+	// publication that a new one lacks. Nothing is rejected.
+	RuleMissingCurrency = "missing_currency"
+	// RuleDuplicate rejects the rates a feed gives more than once for the
+	// same currency and date: all of them when they differ, the repeats
+	// otherwise.
+	RuleDuplicate = "duplicate"
+	// RuleFutureDate rejects rates dated after today in Frankfurt.
+	RuleFutureDate = "future_date"
+)
+
+// QualityRules lists every data quality rule.
+var QualityRules = []string{RuleNonPositive, RuleUnknownCurrency, RuleJump, RuleMissingCurrency, RuleDuplicate, RuleFutureDate}
+
+const (
+	DefaultMaxJump    = 10
+	DefaultJumpWindow = 60
+
+	// minJumpChanges is how many previous changes RuleJump needs to judge
+	// a rate.
+	minJumpChanges = 10
+)
+
+// QualityChecks are the data quality rules ingestion applies to the rates
+// it is about to store. Rates that fail are quarantined instead.
+type QualityChecks struct {
+	// Rules enabled, by name.
+	Rules map[string]bool
+	// MaxJump is how many standard deviations of the previous JumpWindow
+	// day-over-day changes a change may deviate from their mean.
+	MaxJump    float64
+	JumpWindow int
+}
+
+// NewQualityChecks enables rules with the default jump settings.
+func NewQualityChecks(rules ...string) *QualityChecks {
+	c := &QualityChecks{Rules: map[string]bool{}, MaxJump: DefaultMaxJump, JumpWindow: DefaultJumpWindow}
+	for _, rule := range rules {
+		c.Rules[rule] = true
+	}
+	return c
+}
+
+// point is the rate of a currency on a day, as YYYY-MM-DD since stored
+// dates may not be in UTC.
+type point struct {
+	day   string
+	value float64
+}
+
+// screen splits rates into those to store and those to quarantine, along
+// with the reports of RuleMissingCurrency. history holds the stored rates
+// the new ones are compared with. Rates are judged in date order, each
+// against the accepted rates before it, and returned ordered by date and
+// quote.
+func (c *QualityChecks) screen(rates, history []storage.Rate, now time.Time) ([]storage.Rate, []storage.QuarantinedRate) {
+	var accepted []storage.Rate
+	var quarantined []storage.QuarantinedRate
+	reject := func(rate storage.Rate, rule, detail string) {
+		quarantined = append(quarantined, storage.QuarantinedRate{
+			Base:   rate.Base,
+			Quote:  rate.Quote,
+			Rate:   rate.Rate,
+			Date:   rate.Date,
+			Rule:   rule,
+			Detail: detail,
+		})
+	}
+
+	sorted := append([]storage.Rate(nil), rates...)
+	sortRates(sorted)
+
+	series := map[string][]point{}
+	published := map[string]map[string]bool{}
+	publish := func(rate storage.Rate) {
+		day := rate.Date.Format("2006-01-02")
+		if published[day] == nil {
+			published[day] = map[string]bool{}
+		}
+		published[day][rate.Quote] = true
+	}
+	for _, rate := range history {
+		if value, ok := positive(rate.Rate); ok {
+			series[rate.Quote] = addPoint(series[rate.Quote], point{rate.Date.Format("2006-01-02"), value})
+		}
+		publish(rate)
+	}
+	var newDays []string
+	for _, rate := range sorted {
+		day := rate.Date.Format("2006-01-02")
+		if published[day] == nil && (len(newDays) == 0 || newDays[len(newDays)-1] != day) {
+			newDays = append(newDays, day)
+		}
+	}
+
+	values := map[string][]string{}
+	for _, rate := range sorted {
+		key := rateKey(rate.Date, rate.Quote)
+		values[key] = append(values[key], rate.Rate)
+	}
+	seen := map[string]bool{}
+	today := now.In(ecbLocation).Format("2006-01-02")
+
+	for _, rate := range sorted {
+		publish(rate)
+		key := rateKey(rate.Date, rate.Quote)
+		repeated := seen[key]
+		seen[key] = true
+
+		if c.Rules[RuleDuplicate] && len(values[key]) > 1 {
+			if !allEqual(values[key]) {
+				reject(rate, RuleDuplicate, "the feed has conflicting rates for this currency and date")
+				continue
+			}
+			if repeated {
+				reject(rate, RuleDuplicate, "repeated in the feed")
+				continue
+			}
+		}
+		if c.Rules[RuleFutureDate] && rate.Date.Format("2006-01-02") > today {
+			reject(rate, RuleFutureDate, "dated after "+today)
+			continue
+		}
+		if c.Rules[RuleUnknownCurrency] && (!isoCurrencies[rate.Quote] || !isoCurrencies[rate.Base]) {
+			reject(rate, RuleUnknownCurrency, "not an ISO 4217 currency")
+			continue
+		}
+		value, ok := positive(rate.Rate)
+		if c.Rules[RuleNonPositive] && !ok {
+			reject(rate, RuleNonPositive, "rate must be a positive number")
+			continue
+		}
+		if c.Rules[RuleJump] && ok {
+			if detail := c.jump(series[rate.Quote], rate.Date.Format("2006-01-02"), value); detail != "" {
+				reject(rate, RuleJump, detail)
+				continue
+			}
+		}
+
+		accepted = append(accepted, rate)
+		if ok {
+			series[rate.Quote] = addPoint(series[rate.Quote], point{rate.Date.Format("2006-01-02"), value})
+		}
+	}
+
+	if c.Rules[RuleMissingCurrency] {
+		quarantined = append(quarantined, missingCurrencies(published, newDays)...)
+	}
+	return accepted, quarantined
+}
+
+// jump describes how the change to value on day deviates from the previous
+// changes in series, or returns "" if it is within MaxJump standard
+// deviations or there are too few changes to tell.
+func (c *QualityChecks) jump(series []point, day string, value float64) string {
+	i := sort.Search(len(series), func(i int) bool { return series[i].day >= day })
+	previous := series[:i]
+	if len(previous) > c.JumpWindow+1 {
+		previous = previous[len(previous)-c.JumpWindow-1:]
+	}
+	if len(previous)-1 < minJumpChanges {
+		return ""
+	}
+
+	changes := make([]float64, 0, len(previous)-1)
+	var mean float64
+	for j := 1; j < len(previous); j++ {
+		change := previous[j].value/previous[j-1].value - 1
+		changes = append(changes, change)
+		mean += change
+	}
+	mean /= float64(len(changes))
+	var variance float64
+	for _, change := range changes {
+		variance += (change - mean) * (change - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(changes)))
+
+	last := previous[len(previous)-1]
+	change := value/last.value - 1
+	deviation := math.Abs(change - mean)
+	if deviation <= c.MaxJump*stddev {
+		return ""
+	}
+	if stddev == 0 {
+		return fmt.Sprintf("%+.2f%% since %s, after %d unchanged rates", change*100, last.day, len(previous))
+	}
+	return fmt.Sprintf("%+.2f%% since %s, %.1f standard deviations from the mean change", change*100, last.day, deviation/stddev)
+}
+
+// missingCurrencies reports, for each of newDays, the currencies published
+// on the previous day in published but not on that day.
+func missingCurrencies(published map[string]map[string]bool, newDays []string) []storage.QuarantinedRate {
+	days := make([]string, 0, len(published))
+	for day := range published {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	var missing []storage.QuarantinedRate
+	for _, day := range newDays {
+		i := sort.SearchStrings(days, day)
+		if i == 0 {
+			continue
+		}
+		previous := days[i-1]
+
+		var quotes []string
+		for quote := range published[previous] {
+			if !published[day][quote] {
+				quotes = append(quotes, quote)
+			}
+		}
+		sort.Strings(quotes)
+
+		date, _ := time.Parse("2006-01-02", day)
+		for _, quote := range quotes {
+			missing = append(missing, storage.QuarantinedRate{
+				Base:   "EUR",
+				Quote:  quote,
+				Date:   date,
+				Rule:   RuleMissingCurrency,
+				Detail: "published on " + previous + " but missing",
+			})
+		}
+	}
+	return missing
+}
+
+// addPoint inserts p into series, ordered by day, replacing the point of
+// the same day.
+func addPoint(series []point, p point) []point {
+	i := sort.Search(len(series), func(i int) bool { return series[i].day >= p.day })
+	if i < len(series) && series[i].day == p.day {
+		series[i] = p
+		return series
+	}
+	series = append(series, point{})
+	copy(series[i+1:], series[i:])
+	series[i] = p
+	return series
+}
+
+func positive(rate string) (float64, bool) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return 0, false
+	}
+	value, _ := r.Float64()
+	return value, true
+}
+
+func allEqual(values []string) bool {
+	for _, value := range values[1:] {
+		if value != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isoCurrencies are the ISO 4217 currency codes, including those the ECB
+// published before they were withdrawn.
+var isoCurrencies = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD
+		BND BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY
+		COP COU CRC CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP
+		GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR
+		ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD
+		LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN
+		NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD
+		RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN SVC SYP
+		SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW
+		UZS VED VES VND VUV WST XAF XCD XOF XPF YER ZAR ZMW ZWL
+		CYP EEK HRK LTL LVL MTL ROL SIT SKK TRL
+	`) {
+		codes[code] = true
+	}
+	return codes
+}()
@@ -0,0 +1,150 @@
+package rakuten
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/memory"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestQualityChecks_Screen(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	rate := func(quote, value string, date time.Time) storage.Rate {
+		return storage.Rate{Base: "EUR", Quote: quote, Rate: value, Date: date}
+	}
+
+	var history []storage.Rate
+	for d := 1; d <= 20; d++ {
+		usd := "1.100"
+		if d%2 == 0 {
+			usd = "1.101"
+		}
+		history = append(history, rate("USD", usd, day(d)), rate("GBP", "0.88", day(d)), rate("JPY", "140", day(d)))
+	}
+
+	c := NewQualityChecks(QualityRules...)
+	accepted, quarantined := c.screen([]storage.Rate{
+		rate("USD", "1.5", day(21)),
+		rate("XYZ", "1", day(21)),
+		rate("CHF", "-1", day(21)),
+		rate("CAD", "1.45", day(21)),
+		rate("CAD", "1.45", day(21)),
+		rate("SEK", "11", day(21)),
+		rate("SEK", "11.5", day(21)),
+		// a revision within the usual changes
+		rate("USD", "1.1005", day(20)),
+	}, history, day(22))
+
+	if len(accepted) != 2 || accepted[0].Quote != "USD" || !accepted[0].Date.Equal(day(20)) || accepted[1].Quote != "CAD" {
+		t.Fatalf("unexpected accepted rates %+v", accepted)
+	}
+
+	var got []string
+	for _, q := range quarantined {
+		got = append(got, q.Quote+" "+q.Rule)
+	}
+	sort.Strings(got)
+	want := []string{
+		"CAD duplicate",
+		"CHF non_positive",
+		"GBP missing_currency",
+		"JPY missing_currency",
+		"SEK duplicate",
+		"SEK duplicate",
+		"USD jump",
+		"XYZ unknown_currency",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("got quarantined %v, want %v", got, want)
+	}
+
+	// JPY has never moved, so any change is a jump
+	_, quarantined = c.screen([]storage.Rate{rate("JPY", "141", day(21))}, history, day(22))
+	if len(quarantined) != 3 || quarantined[0].Rule != RuleJump || !strings.Contains(quarantined[0].Detail, "unchanged") {
+		t.Fatalf("unexpected quarantined rates %+v", quarantined)
+	}
+
+	// a feed dated ahead of Frankfurt, and too little history to judge jumps
+	accepted, quarantined = c.screen([]storage.Rate{
+		rate("USD", "1.1", day(5)),
+		rate("USD", "9", day(6)),
+	}, nil, time.Date(2023, 1, 5, 22, 0, 0, 0, time.UTC))
+	if len(accepted) != 1 || len(quarantined) != 1 || quarantined[0].Rule != RuleFutureDate {
+		t.Fatalf("expected only the 6th quarantined, got %+v %+v", accepted, quarantined)
+	}
+
+	// disabled rules let everything through
+	accepted, quarantined = NewQualityChecks().screen([]storage.Rate{rate("XYZ", "0", day(21))}, history, day(22))
+	if len(accepted) != 1 || len(quarantined) != 0 {
+		t.Fatalf("expected no checks, got %+v %+v", accepted, quarantined)
+	}
+}
+
+func TestHandler_IngestCurrencyRates_Quarantine(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+	ctx := context.Background()
+
+	quarantine := mock_storage.NewMockQuarantineStore(ctrl)
+	quarantine.EXPECT().QuarantineRates(gAny, gAny).DoAndReturn(func(_ context.Context, rates []storage.QuarantinedRate) error {
+		if len(rates) != 1 || rates[0].Quote != "CHF" || rates[0].Rate != "0" || rates[0].Rule != RuleNonPositive {
+			t.Errorf("unexpected quarantined rates %+v", rates)
+		}
+		return nil
+	})
+
+	h := NewHandler(memory.New())
+	h.Quality = NewQualityChecks(QualityRules...)
+	h.Quarantine = quarantine
+
+	result, err := h.IngestCurrencyRates(ctx, Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+		{Quote: "CHF", Rate: "0", Date: "2023-01-05"},
+	}})
+	if err != nil || result != (IngestResult{Inserted: 1, Rejected: 1}) {
+		t.Fatalf("expected one rate stored and one rejected, got %+v: %v", result, err)
+	}
+
+	rates, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{GetLatestDate: true})
+	if err != nil || len(rates.Rates) != 1 || rates.Rates["USD"] != "1.1" {
+		t.Fatalf("expected only the accepted rate, got %+v: %v", rates, err)
+	}
+}
+
+func TestHandler_IngestCurrencyRates_JumpHistory(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	store := memory.New()
+	var history []storage.Rate
+	for d := 1; d <= 20; d++ {
+		usd := "1.100"
+		if d%2 == 0 {
+			usd = "1.101"
+		}
+		history = append(history, storage.Rate{Base: "EUR", Quote: "USD", Rate: usd, Date: day(d)})
+	}
+	if err := store.CreateCurrencyRates(ctx, history); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(store)
+	h.Quality = NewQualityChecks(RuleJump)
+
+	// a single day is judged against the stored days before it
+	result, err := h.IngestCurrencyRates(ctx, Rates{Rates: RateList{{Quote: "USD", Rate: "1.5", Date: "2023-01-21"}}})
+	if err != nil || result != (IngestResult{Rejected: 1}) {
+		t.Fatalf("expected the jump quarantined, got %+v: %v", result, err)
+	}
+	result, err = h.BackfillCurrencyRates(ctx, Rates{Rates: RateList{{Quote: "USD", Rate: "1.5", Date: "2023-01-21"}}}, day(21), day(21))
+	if err != nil || result != (IngestResult{Rejected: 1}) {
+		t.Fatalf("expected the backfilled jump quarantined, got %+v: %v", result, err)
+	}
+}
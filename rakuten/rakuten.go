@@ -2,17 +2,47 @@ package rakuten
 
 import (
 	"context"
-	"encoding/xml"
 	"github.com/pkg/errors"
-	"io"
+	"math/big"
 	"net/http"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/events"
 	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/tracing"
 )
 
 type Handler struct {
 	Storage storage.RakutenStore
+
+	// Events, when set, receives a RatesPublished event for every new
+	// publication date stored by IngestCurrencyRates, or approved by
+	// DecideApproval when approval is required.
+	Events *events.Bus
+
+	// Overrides, when set, are applied over the stored rates by every
+	// query, and flagged in the responses.
+	Overrides storage.OverrideStore
+
+	// Approvals, when set, requires every ingested publication and
+	// override to be approved before queries return it, see
+	// DecideApproval.
+	Approvals storage.ApprovalStore
+
+	// Quality, when set, screens the rates ingestion is about to store,
+	// and Quarantine, when set, keeps those that fail for review.
+	Quality    *QualityChecks
+	Quarantine storage.QuarantineStore
+
+	// Logger records ingested publications. It defaults to the global
+	// logger.
+	Logger *zap.Logger
 }
 
 func NewHandler(s storage.RakutenStore) *Handler {
@@ -21,13 +51,21 @@ func NewHandler(s storage.RakutenStore) *Handler {
 	}
 }
 
+var ErrRateNotFound = errors.New("currency rate not found")
+
 type GetCurrencyRateRequest struct {
 	GetLatestDate bool
 	Date          time.Time
+	Quotes        []string
+	// AsOf, when set, returns the rates as they were stored at that moment.
+	AsOf time.Time
 }
 
-func (h *Handler) GetCurrencyRate(ctx context.Context, req *GetCurrencyRateRequest) (*CurrencyRatesResponse, error) {
-	filter := storage.CurrencyFilter{}
+func (h *Handler) GetCurrencyRate(ctx context.Context, req *GetCurrencyRateRequest) (_ *CurrencyRatesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetCurrencyRate")
+	defer func() { tracing.End(span, err) }()
+
+	filter := storage.CurrencyFilter{Quotes: req.Quotes, AsOf: req.AsOf}
 
 	if req.GetLatestDate {
 		filter.GetLatestDate = true
@@ -35,21 +73,159 @@ func (h *Handler) GetCurrencyRate(ctx context.Context, req *GetCurrencyRateReque
 		filter.Date = req.Date
 	}
 
-	rates, err := h.Storage.GetCurrencyRates(ctx, filter)
+	var rates []storage.Rate
+	if filter.GetLatestDate && h.Approvals != nil {
+		rates, err = h.latestApprovedRates(ctx, filter)
+	} else {
+		rates, err = h.Storage.GetCurrencyRates(ctx, filter)
+		if err == nil {
+			rates, err = h.approvedRates(ctx, rates, req.Quotes, req.AsOf)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// /rates/latest stays the latest ingested publication, overrides only
+	// apply to its date
+	date := filter.Date
+	if filter.GetLatestDate && len(rates) > 0 {
+		date = rates[0].Date
+	}
+	var overridden map[string]bool
+	if !date.IsZero() {
+		rates, overridden, err = h.applyOverrides(ctx, rates, storage.OverrideFilter{
+			StartDate: date,
+			EndDate:   date,
+			Quotes:    req.Quotes,
+			AsOf:      req.AsOf,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	rateResponse := CurrencyRatesResponse{Base: "EUR", Rates: map[string]string{}}
 	for _, rate := range rates {
-		rateResponse.Rates[rate.Quote] = rate.Rate
+		rateResponse.Date = rate.Date
+		rateResponse.add(rate, overridden)
+	}
+	if !date.IsZero() {
+		rateResponse.LastModified, err = h.lastModified(ctx, rates, date, req.Quotes, req.AsOf)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &rateResponse, nil
 }
 
-func (h *Handler) GetAnalyzedCurrencyRate(ctx context.Context) (*AnalyzedRatesResponse, error) {
-	rates, err := h.Storage.GetAnalyzedCurrencyRates(ctx)
+// lastModified returns when rates, those of date as returned, last changed,
+// as of asOf when set. Besides when they were recorded, that is when an
+// override of date was last changed and an approval of date last decided,
+// since rates may revert to earlier versions.
+func (h *Handler) lastModified(ctx context.Context, rates []storage.Rate, date time.Time, quotes []string, asOf time.Time) (time.Time, error) {
+	var modified time.Time
+	latest := func(t time.Time) {
+		if t.After(modified) && (asOf.IsZero() || !t.After(asOf)) {
+			modified = t
+		}
+	}
+
+	for _, rate := range rates {
+		latest(rate.RecordedAt)
+	}
+	if h.Overrides != nil {
+		changed, err := h.Overrides.GetRateOverridesChangedAt(ctx, storage.OverrideFilter{
+			StartDate: date,
+			EndDate:   date,
+			Quotes:    quotes,
+			AsOf:      asOf,
+		})
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to get rate override changes")
+		}
+		latest(changed)
+	}
+	if h.Approvals != nil {
+		approvals, err := h.Approvals.GetApprovals(ctx, storage.ApprovalFilter{StartDate: date, EndDate: date})
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to get approvals")
+		}
+		for _, approval := range approvals {
+			if approval.DecidedAt != nil {
+				latest(*approval.DecidedAt)
+			}
+		}
+	}
+	return modified, nil
+}
+
+type GetCurrencyRateRangeRequest struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Quotes    []string
+	AsOf      time.Time
+}
+
+// GetCurrencyRateRange returns one CurrencyRatesResponse per published date
+// between StartDate and EndDate, oldest first.
+func (h *Handler) GetCurrencyRateRange(ctx context.Context, req *GetCurrencyRateRangeRequest) (_ []CurrencyRatesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetCurrencyRateRange")
+	defer func() { tracing.End(span, err) }()
+
+	rates, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Quotes:    req.Quotes,
+		AsOf:      req.AsOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rates, err = h.approvedRates(ctx, rates, req.Quotes, req.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, overridden, err := h.applyOverrides(ctx, rates, storage.OverrideFilter{
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Quotes:    req.Quotes,
+		AsOf:      req.AsOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []CurrencyRatesResponse
+	for _, rate := range rates {
+		if len(responses) == 0 || !responses[len(responses)-1].Date.Equal(rate.Date) {
+			responses = append(responses, CurrencyRatesResponse{Base: "EUR", Date: rate.Date, Rates: map[string]string{}})
+		}
+		responses[len(responses)-1].add(rate, overridden)
+	}
+
+	return responses, nil
+}
+
+type GetAnalyzedCurrencyRateRequest struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Quotes    []string
+	AsOf      time.Time
+}
+
+func (h *Handler) GetAnalyzedCurrencyRate(ctx context.Context, req *GetAnalyzedCurrencyRateRequest) (_ *AnalyzedRatesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.GetAnalyzedCurrencyRate")
+	defer func() { tracing.End(span, err) }()
+
+	rates, overridden, err := h.analyze(ctx, storage.AnalysisFilter{
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Quotes:    req.Quotes,
+		AsOf:      req.AsOf,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -60,47 +236,237 @@ func (h *Handler) GetAnalyzedCurrencyRate(ctx context.Context) (*AnalyzedRatesRe
 	rateResponse := AnalyzedRatesResponse{Base: "EUR", RatesAnalyzed: map[string]AnalyzedRate{}}
 	for _, rate := range rates {
 		rateResponse.RatesAnalyzed[rate.Quote] = AnalyzedRate{
-			Min: rate.Min,
-			Max: rate.Max,
-			Avg: rate.Avg,
+			Min:        rate.Min,
+			Max:        rate.Max,
+			Avg:        rate.Avg,
+			Overridden: overridden[rate.Quote],
 		}
 	}
 
 	return &rateResponse, nil
 }
 
-func FetchCurrencyRates() (Rates, error) {
-	url := "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
-	v := Rates{}
+// analyze returns the analysis within filter and the quotes whose rates
+// were overridden. Without overrides in the range, nor approval required,
+// the store analyzes the rates, otherwise they are analyzed here once
+// restricted to the approved ones and overridden.
+func (h *Handler) analyze(ctx context.Context, filter storage.AnalysisFilter) ([]storage.AnalyzedRate, map[string]bool, error) {
+	overrides, err := h.getOverrides(ctx, storage.OverrideFilter{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		Quotes:    filter.Quotes,
+		AsOf:      filter.AsOf,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(overrides) == 0 && h.Approvals == nil {
+		rates, err := h.Storage.GetAnalyzedCurrencyRates(ctx, filter)
+		return rates, nil, err
+	}
 
-	resp, err := http.Get(url)
+	rates, err := h.Storage.GetCurrencyRates(ctx, storage.CurrencyFilter{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		Quotes:    filter.Quotes,
+		AsOf:      filter.AsOf,
+	})
 	if err != nil {
-		return v, err
+		return nil, nil, err
 	}
+	rates, err = h.approvedRates(ctx, rates, filter.Quotes, filter.AsOf)
+	if err != nil {
+		return nil, nil, err
+	}
+	rates, _ = mergeOverrides(rates, overrides)
 
-	defer resp.Body.Close()
+	analyzed, err := storage.AnalyzeRates(rates)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to analyze currency rates")
+	}
+	overridden := map[string]bool{}
+	for _, override := range overrides {
+		overridden[override.Quote] = true
+	}
+	return analyzed, overridden, nil
+}
+
+type ConvertCurrencyRequest struct {
+	From          string
+	To            string
+	Amount        string
+	GetLatestDate bool
+	Date          time.Time
+	AsOf          time.Time
+}
+
+// ConvertCurrency converts Amount from one currency to another by crossing
+// both through the EUR rates published on the requested date.
+func (h *Handler) ConvertCurrency(ctx context.Context, req *ConvertCurrencyRequest) (_ *ConvertCurrencyResponse, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.Handler.ConvertCurrency")
+	defer func() { tracing.End(span, err) }()
+
+	amount, ok := new(big.Rat).SetString(req.Amount)
+	if !ok {
+		return nil, errors.Errorf("invalid amount %q", req.Amount)
+	}
+
+	rates, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{
+		GetLatestDate: req.GetLatestDate || req.Date.IsZero(),
+		Date:          req.Date,
+		Quotes:        []string{req.From, req.To},
+		AsOf:          req.AsOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := rates.CrossRate(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConvertCurrencyResponse{
+		From:   req.From,
+		To:     req.To,
+		Amount: req.Amount,
+		Date:   rates.Date,
+		Rate:   FormatDecimal(rate),
+		Result: FormatDecimal(new(big.Rat).Mul(amount, rate)),
+	}, nil
+}
+
+// CrossRate returns the price of one unit of base in quote, derived from
+// the rates of both currencies against rates.Base.
+func (rates *CurrencyRatesResponse) CrossRate(base, quote string) (*big.Rat, error) {
+	from, err := rates.rate(base)
+	if err != nil {
+		return nil, err
+	}
+	to, err := rates.rate(quote)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(to, from), nil
+}
+
+func (rates *CurrencyRatesResponse) rate(currency string) (*big.Rat, error) {
+	if currency == rates.Base {
+		return big.NewRat(1, 1), nil
+	}
+
+	value, ok := rates.Rates[currency]
+	if !ok {
+		return nil, errors.Wrap(ErrRateNotFound, currency)
+	}
+
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok || rate.Sign() <= 0 {
+		return nil, errors.Errorf("invalid stored rate %q for %s", value, currency)
+	}
+	return rate, nil
+}
+
+// FormatDecimal renders r with the same trailing zero trimming the storage
+// layer applies to rates.
+func FormatDecimal(r *big.Rat) string {
+	s := r.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
 
-	body, err := io.ReadAll(resp.Body)
+var tracer = otel.Tracer("github.com/syahnur197/rakuten/rakuten")
+
+const (
+	// DefaultECBURL is the ECB feed of the last 90 days of reference rates.
+	DefaultECBURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+	// ECBHistoryURL is the ECB feed of every reference rate since 4 January
+	// 1999.
+	ECBHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+// fetchTimeout bounds a feed download so that an unreachable ECB does not
+// stall ingestion.
+const fetchTimeout = time.Minute
+
+var httpClient = &http.Client{Transport: tracing.Transport(nil), Timeout: fetchTimeout}
+
+// FetchCurrencyRates downloads and parses the ECB reference rates feed at
+// url, e.g. DefaultECBURL.
+func FetchCurrencyRates(ctx context.Context, url string) (Rates, error) {
+	feed, err := FetchFeed(ctx, url)
 	if err != nil {
-		return v, err
+		return Rates{}, err
 	}
+	return feed.Rates, nil
+}
+
+// FetchFeed downloads and parses the ECB reference rates feed at url. The
+// returned feed records the response status even when err is not nil,
+// unless the request failed.
+func FetchFeed(ctx context.Context, url string) (_ *Feed, err error) {
+	ctx, span := tracer.Start(ctx, "rakuten.FetchCurrencyRates",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPURLKey.String(url)),
+	)
+	defer func() { tracing.End(span, err) }()
 
-	if err := xml.Unmarshal(body, &v); err != nil {
-		return v, err
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return &Feed{StatusCode: resp.StatusCode}, errors.Errorf("unexpected response status %d", resp.StatusCode)
 	}
 
-	return v, nil
+	feed, err := ReadFeed(resp.Body)
+	feed.StatusCode = resp.StatusCode
+	return feed, err
 }
 
 type CurrencyRatesResponse struct {
 	Base  string            `json:"base"`
+	Date  time.Time         `json:"-"`
 	Rates map[string]string `json:"rates"`
+	// LastModified is when the rates last changed. It is only set by
+	// GetCurrencyRate.
+	LastModified time.Time `json:"-"`
+	// Overridden lists the quotes whose rates are manual overrides.
+	Overridden []string `json:"overridden,omitempty"`
+}
+
+// add sets the rate of its quote, flagging it when its key is in
+// overridden.
+func (rates *CurrencyRatesResponse) add(rate storage.Rate, overridden map[string]bool) {
+	rates.Rates[rate.Quote] = rate.Rate
+	if overridden[rateKey(rate.Date, rate.Quote)] {
+		rates.Overridden = append(rates.Overridden, rate.Quote)
+	}
+}
+
+type ConvertCurrencyResponse struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Amount string    `json:"amount"`
+	Date   time.Time `json:"date"`
+	Rate   string    `json:"rate"`
+	Result string    `json:"result"`
 }
 
 type AnalyzedRate struct {
 	Min string `json:"min"`
 	Max string `json:"max"`
 	Avg string `json:"avg"`
+	// Overridden is set when an override contributed to the analysis.
+	Overridden bool `json:"overridden,omitempty"`
 }
 
 type AnalyzedRatesResponse struct {
@@ -2,9 +2,15 @@ package rakuten
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"github.com/golang/mock/gomock"
+	"github.com/syahnur197/rakuten/events"
 	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/memory"
 	"github.com/syahnur197/rakuten/storage/mock_storage"
+	"strings"
 	"testing"
 	"time"
 )
@@ -24,7 +30,7 @@ func TestHandler_GetAnalyzedCurrencyRate(t *testing.T) {
 	}
 
 	storeValid := func(m *mock_storage.MockRakutenStore) {
-		m.EXPECT().GetAnalyzedCurrencyRates(gAny).Return(testData, nil)
+		m.EXPECT().GetAnalyzedCurrencyRates(gAny, gAny).Return(testData, nil)
 	}
 
 	mockStore := mock_storage.NewMockRakutenStore(ctrl)
@@ -32,7 +38,7 @@ func TestHandler_GetAnalyzedCurrencyRate(t *testing.T) {
 
 	h := NewHandler(mockStore)
 
-	rates, err := h.GetAnalyzedCurrencyRate(context.Background())
+	rates, err := h.GetAnalyzedCurrencyRate(context.Background(), &GetAnalyzedCurrencyRateRequest{})
 	if err != nil {
 		t.Fatal("unexpected err")
 	}
@@ -89,3 +95,289 @@ func TestHandler_GetCurrencyRate(t *testing.T) {
 		t.Fatal("unexpected value")
 	}
 }
+
+func TestHandler_ConvertCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	testData := []storage.Rate{
+		{
+			Base:  "EUR",
+			Quote: "USD",
+			Rate:  "1.25",
+			Date:  date,
+		},
+		{
+			Base:  "EUR",
+			Quote: "JPY",
+			Rate:  "150",
+			Date:  date,
+		},
+	}
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, gAny).Return(testData, nil).Times(3)
+
+	h := NewHandler(mockStore)
+
+	conversion, err := h.ConvertCurrency(context.Background(), &ConvertCurrencyRequest{
+		From:   "USD",
+		To:     "JPY",
+		Amount: "10",
+	})
+	if err != nil {
+		t.Fatal("unexpected err")
+	}
+
+	if conversion.Rate != "120" {
+		t.Fatalf("unexpected rate %s", conversion.Rate)
+	}
+	if conversion.Result != "1200" {
+		t.Fatalf("unexpected result %s", conversion.Result)
+	}
+	if !conversion.Date.Equal(date) {
+		t.Fatal("unexpected date")
+	}
+
+	conversion, err = h.ConvertCurrency(context.Background(), &ConvertCurrencyRequest{
+		From:   "EUR",
+		To:     "USD",
+		Amount: "2",
+	})
+	if err != nil {
+		t.Fatal("unexpected err")
+	}
+
+	if conversion.Result != "2.5" {
+		t.Fatalf("unexpected result %s", conversion.Result)
+	}
+
+	_, err = h.ConvertCurrency(context.Background(), &ConvertCurrencyRequest{
+		From:   "USD",
+		To:     "BND",
+		Amount: "1",
+	})
+	if !errors.Is(err, ErrRateNotFound) {
+		t.Fatal("expected ErrRateNotFound")
+	}
+}
+
+func TestHandler_IngestCurrencyRates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	latest := time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, gAny).Return([]storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1", Date: latest}}, nil)
+	mockStore.EXPECT().CreateCurrencyRates(gAny, gAny).DoAndReturn(func(_ context.Context, rates []storage.Rate) error {
+		if len(rates) != 2 {
+			t.Errorf("expected the new publication in one batch, got %d rates", len(rates))
+		}
+		return nil
+	})
+
+	h := NewHandler(mockStore)
+	h.Events = events.NewBus()
+	sub := h.Events.Subscribe()
+	defer h.Events.Unsubscribe(sub)
+
+	result, err := h.IngestCurrencyRates(context.Background(), Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+		{Quote: "JPY", Rate: "140", Date: "2023-01-05"},
+		{Quote: "USD", Rate: "1", Date: "2023-01-04"},
+	}})
+	if err != nil {
+		t.Fatal("unexpected err")
+	}
+	if result.Inserted != 2 || result.Updated != 0 {
+		t.Fatalf("unexpected stored count %+v", result)
+	}
+
+	e := <-sub.C
+	if e.Date.Format("2006-01-02") != "2023-01-05" || e.Rates["JPY"] != "140" {
+		t.Fatal("unexpected event")
+	}
+}
+
+func TestHandler_IngestCurrencyRates_StoreError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, gAny).Return(nil, nil)
+	mockStore.EXPECT().CreateCurrencyRates(gAny, gAny).Return(errors.New("copy failed"))
+
+	h := NewHandler(mockStore)
+	h.Events = events.NewBus()
+	sub := h.Events.Subscribe()
+	defer h.Events.Unsubscribe(sub)
+
+	result, err := h.IngestCurrencyRates(context.Background(), Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+	}})
+	if err == nil || result.Written() != 0 {
+		t.Fatalf("expected an error and nothing stored, got %+v: %v", result, err)
+	}
+
+	select {
+	case <-sub.C:
+		t.Fatal("a failed ingestion must not publish events")
+	default:
+	}
+}
+
+func TestHandler_BackfillCurrencyRates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	start := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	// already stored, in a non-UTC location as the driver may return it
+	stored := time.Date(2023, 1, 4, 0, 0, 0, 0, time.FixedZone("", 0))
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, storage.CurrencyFilter{StartDate: start, EndDate: end}).
+		Return([]storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1", Date: stored}}, nil)
+
+	var created []string
+	mockStore.EXPECT().CreateCurrencyRates(gAny, gAny).DoAndReturn(func(_ context.Context, rates []storage.Rate) error {
+		for _, rate := range rates {
+			created = append(created, rate.Date.Format("2006-01-02")+" "+rate.Quote)
+		}
+		return nil
+	})
+
+	h := NewHandler(mockStore)
+	h.Events = events.NewBus()
+	sub := h.Events.Subscribe()
+	defer h.Events.Unsubscribe(sub)
+
+	result, err := h.BackfillCurrencyRates(context.Background(), Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.2", Date: "2023-01-06"},
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+		{Quote: "USD", Rate: "1", Date: "2023-01-04"},
+		{Quote: "USD", Rate: "0.9", Date: "2023-01-03"},
+		{Quote: "JPY", Rate: "139", Date: "2023-01-03"},
+		{Quote: "USD", Rate: "0.8", Date: "2023-01-02"},
+	}}, start, end)
+	if err != nil {
+		t.Fatal("unexpected err")
+	}
+	if result.Inserted != 3 || result.Updated != 0 {
+		t.Fatalf("unexpected written count %+v", result)
+	}
+	if created[0][:10] != "2023-01-03" || created[2] != "2023-01-05 USD" {
+		t.Fatalf("unexpected rates written %v", created)
+	}
+
+	select {
+	case <-sub.C:
+		t.Fatal("backfill must not publish events")
+	default:
+	}
+}
+
+func TestHandler_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	h := NewHandler(memory.New())
+
+	feed := Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+		{Quote: "JPY", Rate: "140", Date: "2023-01-05"},
+		{Quote: "USD", Rate: "1.05", Date: "2023-01-04"},
+		{Quote: "JPY", Rate: "139.50", Date: "2023-01-04"},
+	}}
+	if result, err := h.IngestCurrencyRates(ctx, feed); err != nil || result.Inserted != 4 {
+		t.Fatalf("unexpected ingestion %+v: %v", result, err)
+	}
+	if result, err := h.IngestCurrencyRates(ctx, feed); err != nil || result.Written() != 0 {
+		t.Fatalf("expected nothing new on the second run, got %+v: %v", result, err)
+	}
+
+	latest, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{GetLatestDate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Rates["JPY"] != "140" || latest.Rates["USD"] != "1.1" {
+		t.Fatalf("unexpected latest rates %v", latest.Rates)
+	}
+
+	analyzed, err := h.GetAnalyzedCurrencyRate(ctx, &GetAnalyzedCurrencyRateRequest{Quotes: []string{"JPY"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := analyzed.RatesAnalyzed["JPY"]; got.Min != "139.5" || got.Max != "140" || got.Avg != "139.75" {
+		t.Fatalf("unexpected analysis %+v", got)
+	}
+}
+
+func TestHandler_IngestCurrencyRates_Revisions(t *testing.T) {
+	ctx := context.Background()
+	h := NewHandler(memory.New())
+	h.Events = events.NewBus()
+
+	if _, err := h.IngestCurrencyRates(ctx, Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.05", Date: "2023-01-04"},
+		{Quote: "USD", Rate: "1.1", Date: "2023-01-05"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond)
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+
+	sub := h.Events.Subscribe()
+	defer h.Events.Unsubscribe(sub)
+
+	// the feed corrects the 4th and republishes the 5th unchanged
+	result, err := h.IngestCurrencyRates(ctx, Rates{Rates: RateList{
+		{Quote: "USD", Rate: "1.06", Date: "2023-01-04"},
+		{Quote: "USD", Rate: "1.10", Date: "2023-01-05"},
+	}})
+	if err != nil || result != (IngestResult{Updated: 1}) {
+		t.Fatalf("expected one revision, got %+v: %v", result, err)
+	}
+	select {
+	case e := <-sub.C:
+		t.Fatalf("unexpected event for a revision %+v", e)
+	default:
+	}
+
+	date := time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC)
+	current, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{Date: date})
+	if err != nil || current.Rates["USD"] != "1.06" {
+		t.Fatalf("expected the corrected rate, got %v: %v", current, err)
+	}
+	original, err := h.GetCurrencyRate(ctx, &GetCurrencyRateRequest{Date: date, AsOf: before})
+	if err != nil || original.Rates["USD"] != "1.05" {
+		t.Fatalf("expected the original rate as of before the correction, got %v: %v", original, err)
+	}
+}
+
+func TestReadFeed(t *testing.T) {
+	doc := `<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender><gesmes:name>European Central Bank</gesmes:name></gesmes:Sender>
+	<Cube>
+		<Cube time="2023-01-05"><Cube currency="USD" rate="1.1"/></Cube>
+		<Cube time="2023-01-03"><Cube currency="USD" rate="1.05"/></Cube>
+	</Cube>
+</gesmes:Envelope>
+`
+	feed, err := ReadFeed(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(doc))
+	if feed.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("digest %s does not cover the whole document", feed.SHA256)
+	}
+	first, last := feed.Dates()
+	if first.Format("2006-01-02") != "2023-01-03" || last.Format("2006-01-02") != "2023-01-05" {
+		t.Errorf("unexpected dates %s %s", first, last)
+	}
+}
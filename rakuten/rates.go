@@ -1,53 +1,88 @@
 package rakuten
 
 import (
-	"encoding/xml"
-	"github.com/syahnur197/rakuten/storage"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
 )
 
 type Rate struct {
 	Base  string `db:"base"`
-	Quote string `xml:"currency,attr" db:"quote"`
-	Rate  string `xml:"rate,attr" db:"rate"`
-	Date  string `xml:"time,attr" db:"published_date"`
+	Quote string `db:"quote"`
+	Rate  string `db:"rate"`
+	Date  string `db:"published_date"`
 }
 
 type Rates struct {
-	Rates RateList `xml:"Cube>Cube"`
+	Rates RateList
 }
 
 type RateList []Rate
 
-func (ls *RateList) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	date := start.Attr[0].Value
+// ParseCurrencyRates parses an ECB reference rates document, e.g. the body
+// of DefaultECBURL or a saved copy of it. A document that strays from the
+// ECB layout is rejected with a *FeedError locating the problem, and no
+// rates.
+func ParseCurrencyRates(r io.Reader) (Rates, error) {
+	rates, err := parseFeed(r)
+	if err != nil {
+		return Rates{}, errors.Wrap(err, "failed to parse currency rates")
+	}
+	return Rates{Rates: rates}, nil
+}
 
-	for {
-		tok, err := d.Token()
-		if err != nil {
-			if err == io.EOF {
-				return nil
-			}
-			return err
-		}
+// Feed is an ECB reference rates document, with what the ingestion audit
+// log records about it.
+type Feed struct {
+	Rates Rates
+	// StatusCode of the response the feed was fetched with, 0 when it was
+	// read from a file.
+	StatusCode int
+	// SHA256 is the hex digest of the document.
+	SHA256 string
+}
 
-		if se, ok := tok.(xml.StartElement); ok {
-			rate := Rate{Date: date}
-			if err := d.DecodeElement(&rate, &se); err != nil {
-				return err
-			}
+// ReadFeed reads and parses an ECB reference rates document from r. The
+// returned feed holds the digest of what was read even when parsing fails.
+func ReadFeed(r io.Reader) (*Feed, error) {
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+
+	rates, err := ParseCurrencyRates(tee)
+	if err == nil {
+		// the digest covers the whole document, trailing bytes included
+		_, err = io.Copy(io.Discard, tee)
+	}
+	return &Feed{Rates: rates, SHA256: hex.EncodeToString(h.Sum(nil))}, err
+}
 
-			*ls = append(*ls, rate)
+// Dates returns the first and last publication dates of the feed, or zero
+// times when it has none.
+func (f *Feed) Dates() (first, last time.Time) {
+	for _, rate := range f.Rates.Rates {
+		date, err := time.Parse("2006-01-02", rate.Date)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
 		}
 	}
+	return first, last
 }
 
 func ConvertToStoreRate(rate Rate) (storage.Rate, error) {
-	// ideally we want to handle the error
 	date, err := time.Parse("2006-01-02", rate.Date)
 	if err != nil {
-		return storage.Rate{}, err
+		return storage.Rate{}, errors.Errorf("invalid date %q of the %s rate, must be YYYY-MM-DD", rate.Date, rate.Quote)
 	}
 
 	return storage.Rate{
@@ -0,0 +1,61 @@
+// Package response records what handlers write, for the middleware that
+// logs, measures and traces requests.
+package response
+
+import "net/http"
+
+// Recorder is a http.ResponseWriter that records the status and the number
+// of body bytes written through it.
+type Recorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// NewRecorder wraps w, unless w is a Recorder already, in which case it is
+// returned so that stacked middleware share it.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	if rec, ok := w.(*Recorder); ok {
+		return rec
+	}
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status is the status written, 200 when the handler wrote none.
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+// Bytes is the number of body bytes written.
+func (r *Recorder) Bytes() int64 {
+	return r.bytes
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps streaming handlers working behind the middleware.
+func (r *Recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets streaming handlers reach the connection behind the
+// middleware.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
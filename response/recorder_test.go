@@ -0,0 +1,40 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewRecorder(w)
+	if NewRecorder(rec) != rec {
+		t.Fatal("expected a recorder to be reused")
+	}
+
+	rec.WriteHeader(http.StatusNotFound)
+	rec.WriteHeader(http.StatusInternalServerError)
+	if _, err := rec.Write([]byte("not found")); err != nil {
+		t.Fatal(err)
+	}
+	rec.Flush()
+
+	if rec.Status() != http.StatusNotFound || rec.Bytes() != 9 {
+		t.Fatalf("unexpected status %d and bytes %d", rec.Status(), rec.Bytes())
+	}
+	if !w.Flushed || rec.Unwrap() != w {
+		t.Fatal("expected the response writer to be flushed and unwrapped")
+	}
+}
+
+func TestRecorder_DefaultStatus(t *testing.T) {
+	rec := NewRecorder(httptest.NewRecorder())
+	if _, err := rec.Write([]byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+	rec.WriteHeader(http.StatusInternalServerError)
+	if rec.Status() != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Status())
+	}
+}
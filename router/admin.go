@@ -0,0 +1,151 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/usage"
+)
+
+type updateScopesRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeys serves /admin/keys (GET to list, POST to issue) and
+// /admin/keys/{id} (PATCH to replace scopes, DELETE to revoke).
+func (rtr *Router) APIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.Auth == nil {
+		notFound(w)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/keys"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		keys, err := rtr.Auth.GetKeys(ctx)
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain api keys", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, keys)
+
+	case id == "" && r.Method == http.MethodPost:
+		req := &auth.IssueKeyRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			badRequest(w, "invalid request body")
+			return
+		}
+
+		key, err := rtr.Auth.IssueKey(ctx, req)
+		if errors.Is(err, auth.ErrInvalidRequest) {
+			badRequest(w, err.Error())
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to issue api key", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusCreated, key)
+
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodPatch:
+		req := &updateScopesRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			badRequest(w, "invalid request body")
+			return
+		}
+
+		err := rtr.Auth.UpdateScopes(ctx, id, req.Scopes)
+		if errors.Is(err, auth.ErrInvalidRequest) {
+			badRequest(w, err.Error())
+			return
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to update api key scopes", zap.Error(err))
+			internalError(w)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodDelete:
+		err := rtr.Auth.RevokeKey(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to revoke api key", zap.Error(err))
+			internalError(w)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		notFound(w)
+	}
+}
+
+// UsageReport serves /admin/usage. The period is ?month=YYYY-MM, or
+// ?from=YYYY-MM-DD&to=YYYY-MM-DD (both inclusive), defaulting to the
+// current month.
+func (rtr *Router) UsageReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.Usage == nil || r.Method != http.MethodGet {
+		notFound(w)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := usage.MonthRange(time.Now())
+
+	if month := query.Get("month"); month != "" {
+		t, err := time.Parse("2006-01", month)
+		if err != nil {
+			badRequest(w, "invalid month format, must be YYYY-MM")
+			return
+		}
+		from, to = usage.MonthRange(t)
+	}
+	if value := query.Get("from"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			badRequest(w, "invalid from format, must be YYYY-MM-DD")
+			return
+		}
+		from = t
+	}
+	if value := query.Get("to"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			badRequest(w, "invalid to format, must be YYYY-MM-DD")
+			return
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+
+	report, err := rtr.Usage.Report(ctx, from, to)
+	if err != nil {
+		rtr.logger(ctx).Error("failed to obtain usage report", zap.Error(err))
+		internalError(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/alerts"
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// AlertRules serves /alerts (GET to list, POST to create), /alerts/{id}
+// (DELETE) and /alerts/{id}/deliveries (GET).
+func (rtr *Router) AlertRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.Alerts == nil {
+		notFound(w)
+		return
+	}
+
+	// keys see and delete only their own rules, admin keys every rule,
+	// including those created before owners were recorded
+	var keyID, owner string
+	if key, ok := auth.KeyFromContext(ctx); ok {
+		keyID = key.ID
+		if !key.HasScope(auth.ScopeAdmin) {
+			owner = key.ID
+		}
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/alerts"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		rules, err := rtr.Alerts.GetRules(ctx, owner)
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain alert rules", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+
+	case path == "" && r.Method == http.MethodPost:
+		req := &alerts.CreateRuleRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			badRequest(w, "invalid request body")
+			return
+		}
+		req.KeyID = keyID
+
+		rule, err := rtr.Alerts.CreateRule(ctx, req)
+		if errors.Is(err, alerts.ErrInvalidRule) {
+			badRequest(w, err.Error())
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to create alert rule", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusCreated, rule)
+
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		err := rtr.Alerts.DeleteRule(ctx, parts[0], owner)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to delete alert rule", zap.Error(err))
+			internalError(w)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case len(parts) == 2 && parts[1] == "deliveries" && r.Method == http.MethodGet:
+		deliveries, err := rtr.Alerts.GetDeliveries(ctx, parts[0], owner)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain alert deliveries", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, deliveries)
+
+	default:
+		notFound(w)
+	}
+}
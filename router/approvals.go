@@ -0,0 +1,134 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	defaultApprovalLimit = 100
+	maxApprovalLimit     = 1000
+)
+
+// Approvals serves /admin/approvals, the changes awaiting approval and the
+// decisions taken, oldest first, filtered by ?status=, ?kind=,
+// ?from=YYYY-MM-DD&to=YYYY-MM-DD (publication dates) and capped by
+// ?limit=, /admin/approvals/{id}, and POST /admin/approvals/{id}/approve
+// and /admin/approvals/{id}/reject with an optional {"comment": "..."}.
+// Decisions are attributed to the name of the authenticated key, which may
+// not be the maker of the change.
+func (rtr *Router) Approvals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.H.Approvals == nil {
+		notFound(w)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/approvals"), "/")
+	id, action, _ := strings.Cut(path, "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		req := &rakuten.GetApprovalsRequest{Limit: defaultApprovalLimit}
+		query := r.URL.Query()
+		switch req.Status = query.Get("status"); req.Status {
+		case "", storage.ApprovalPending, storage.ApprovalApproved, storage.ApprovalRejected:
+		default:
+			badRequest(w, "invalid status, must be pending, approved or rejected")
+			return
+		}
+		switch req.Kind = query.Get("kind"); req.Kind {
+		case "", storage.ApprovalPublication, storage.ApprovalOverride, storage.ApprovalOverrideDeletion:
+		default:
+			badRequest(w, "invalid kind, must be publication, override or override_delete")
+			return
+		}
+		if value := query.Get("from"); value != "" {
+			t, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				badRequest(w, "invalid from format, must be YYYY-MM-DD")
+				return
+			}
+			req.StartDate = t
+		}
+		if value := query.Get("to"); value != "" {
+			t, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				badRequest(w, "invalid to format, must be YYYY-MM-DD")
+				return
+			}
+			req.EndDate = t
+		}
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxApprovalLimit {
+				badRequest(w, "invalid limit, must be between 1 and 1000")
+				return
+			}
+			req.Limit = limit
+		}
+
+		approvals, err := rtr.H.GetApprovals(ctx, req)
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain approvals", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, approvals)
+
+	case id != "" && action == "" && r.Method == http.MethodGet:
+		approval, err := rtr.H.GetApproval(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain approval", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, approval)
+
+	case id != "" && (action == "approve" || action == "reject") && r.Method == http.MethodPost:
+		req := &rakuten.DecisionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+			badRequest(w, "invalid request body")
+			return
+		}
+		req.Approve = action == "approve"
+		if key, ok := auth.KeyFromContext(ctx); ok {
+			req.DecidedBy, req.DecidedByKey = key.Name, key.ID
+		}
+
+		approval, err := rtr.H.DecideApproval(ctx, id, req)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			notFound(w)
+		case errors.Is(err, rakuten.ErrSelfApproval):
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Message: err.Error()})
+		case errors.Is(err, rakuten.ErrDecided):
+			writeJSON(w, http.StatusConflict, ErrorResponse{Message: err.Error()})
+		case err != nil:
+			rtr.logger(ctx).Error("failed to decide approval", zap.Error(err))
+			internalError(w)
+		default:
+			writeJSON(w, http.StatusOK, approval)
+		}
+
+	default:
+		notFound(w)
+	}
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRouter_Approvals(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)
+	approval := storage.Approval{ID: "approval-1", Kind: storage.ApprovalOverride, Date: date, OverrideID: "override-1", RequestedBy: "ops", RequestedByKey: "key-ops", Status: storage.ApprovalPending}
+	decidedAt := time.Now()
+	decided := approval
+	decided.Status, decided.DecidedBy, decided.DecidedAt, decided.Comment = storage.ApprovalApproved, "lead", &decidedAt, "ok"
+
+	approvals := mock_storage.NewMockApprovalStore(ctrl)
+	approvals.EXPECT().GetApprovals(gAny, storage.ApprovalFilter{Status: storage.ApprovalPending, Limit: 100}).Return([]storage.Approval{approval}, nil)
+	gomock.InOrder(
+		approvals.EXPECT().GetApproval(gAny, "approval-1").Return(approval, nil).Times(2),
+		approvals.EXPECT().DecideApproval(gAny, gAny).Return(nil),
+		approvals.EXPECT().GetApproval(gAny, "approval-1").Return(decided, nil),
+	)
+	approvals.EXPECT().GetApproval(gAny, "missing").Return(storage.Approval{}, storage.ErrNotFound)
+
+	h := rakuten.NewHandler(mock_storage.NewMockRakutenStore(ctrl))
+	h.Approvals = approvals
+	rtr := NewRouter(h)
+
+	serve := func(method, path, body, name string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r = r.WithContext(auth.NewContext(r.Context(), &auth.Key{ID: "key-" + name, Name: name}))
+		w := httptest.NewRecorder()
+		rtr.Approvals(w, r)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/admin/approvals?status=pending", "", "lead")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"override_id":"override-1"`) {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body)
+	}
+	if w := serve(http.MethodGet, "/admin/approvals?status=done", "", "lead"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown status, got %d", w.Code)
+	}
+
+	if w := serve(http.MethodPost, "/admin/approvals/approval-1/approve", "", "ops"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 approving one's own change, got %d %s", w.Code, w.Body)
+	}
+	w = serve(http.MethodPost, "/admin/approvals/approval-1/approve", `{"comment": "ok"}`, "lead")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"approved"`) || !strings.Contains(w.Body.String(), `"decided_by":"lead"`) {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body)
+	}
+
+	if w := serve(http.MethodPost, "/admin/approvals/missing/reject", "", "lead"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown approval, got %d", w.Code)
+	}
+	if w := serve(http.MethodPost, "/admin/approvals/approval-1/cancel", "", "lead"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown action, got %d", w.Code)
+	}
+}
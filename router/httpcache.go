@@ -0,0 +1,78 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// asOfRatesCacheControl applies to rates as of a past moment, which
+	// never change.
+	asOfRatesCacheControl = "private, max-age=31536000, immutable"
+	// pastRatesCacheControl applies to dates before today, which change
+	// only when a correction is ingested.
+	pastRatesCacheControl = "private, max-age=86400, must-revalidate"
+	// currentRatesCacheControl applies to /rates/latest and today, which
+	// change when the next publication is ingested.
+	currentRatesCacheControl = "private, max-age=300, must-revalidate"
+
+	// asOfSettled is how long ago as_of must be for its rates to be
+	// immutable. Writes in flight, or recorded by a host whose clock lags,
+	// may still be stamped with an earlier moment.
+	asOfSettled = time.Minute
+)
+
+// writeCacheable writes body with a strong ETag derived from its content
+// and, unless lastModified is zero, a Last-Modified header. Requests whose
+// If-None-Match or If-Modified-Since validators still match get 304 Not
+// Modified without a body.
+func writeCacheable(w http.ResponseWriter, r *http.Request, body []byte, lastModified time.Time, cacheControl string) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// notModified evaluates the conditional request headers. If-Modified-Since
+// is ignored when If-None-Match is present, as required by RFC 9110.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRouter_GetCurrencyRate_Caching(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	recordedAt := time.Date(2023, 1, 6, 10, 0, 0, 0, time.UTC)
+	testData := []storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1.1", Date: date, RecordedAt: recordedAt}}
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, gAny).Return(testData, nil).AnyTimes()
+
+	rtr := NewRouter(rakuten.NewHandler(mockStore))
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		rtr.GetCurrencyRate(w, r)
+		return w
+	}
+
+	w := serve("/rates/2023-01-05", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Cache-Control") != pastRatesCacheControl {
+		t.Fatal("expected long lived cache control for past dates")
+	}
+	if w.Header().Get("Last-Modified") != "Fri, 06 Jan 2023 10:00:00 GMT" {
+		t.Fatalf("expected Last-Modified when the rates were recorded, got %s", w.Header().Get("Last-Modified"))
+	}
+
+	w = serve("/rates/2023-01-05", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	w = serve("/rates/2023-01-05", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {"Fri, 06 Jan 2023 10:00:00 GMT"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected If-None-Match to take precedence, got %d", w.Code)
+	}
+
+	w = serve("/rates/2023-01-05", http.Header{"If-Modified-Since": {"Fri, 06 Jan 2023 10:00:00 GMT"}})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+
+	// recorded after the publication date, by a correction
+	w = serve("/rates/2023-01-05", http.Header{"If-Modified-Since": {"Fri, 06 Jan 2023 00:00:00 GMT"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for rates recorded since, got %d", w.Code)
+	}
+
+	w = serve("/rates/latest", nil)
+	if w.Header().Get("Cache-Control") != currentRatesCacheControl {
+		t.Fatal("expected short lived cache control for latest")
+	}
+}
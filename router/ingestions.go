@@ -0,0 +1,127 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	defaultIngestionLimit = 100
+	maxIngestionLimit     = 1000
+)
+
+type ingestionRunResponse struct {
+	ID         string    `json:"id"`
+	Source     string    `json:"source"`
+	URL        string    `json:"url"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	FeedHash   string    `json:"feed_hash,omitempty"`
+	FirstDate  string    `json:"first_date,omitempty"`
+	LastDate   string    `json:"last_date,omitempty"`
+	Inserted   int       `json:"rows_inserted"`
+	Updated    int       `json:"rows_updated"`
+	Rejected   int       `json:"rows_rejected"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newIngestionRunResponse(run storage.IngestionRun) ingestionRunResponse {
+	response := ingestionRunResponse{
+		ID:         run.ID,
+		Source:     run.Source,
+		URL:        run.URL,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		HTTPStatus: run.HTTPStatus,
+		FeedHash:   run.FeedHash,
+		Inserted:   run.Inserted,
+		Updated:    run.Updated,
+		Rejected:   run.Rejected,
+		Error:      run.Error,
+	}
+	if run.FirstDate != nil {
+		response.FirstDate = run.FirstDate.Format("2006-01-02")
+	}
+	if run.LastDate != nil {
+		response.LastDate = run.LastDate.Format("2006-01-02")
+	}
+	return response
+}
+
+// IngestionRuns serves /admin/ingestions, the ingestion audit log newest
+// first, filtered by ?from=YYYY-MM-DD&to=YYYY-MM-DD (both inclusive) and
+// capped by ?limit=, and /admin/ingestions/{id}.
+func (rtr *Router) IngestionRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.Ingestions == nil || r.Method != http.MethodGet {
+		notFound(w)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/ingestions"), "/")
+	if id != "" {
+		run, err := rtr.Ingestions.GetIngestionRun(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain ingestion run", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, newIngestionRunResponse(run))
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.IngestionFilter{Limit: defaultIngestionLimit}
+
+	if value := query.Get("from"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			badRequest(w, "invalid from format, must be YYYY-MM-DD")
+			return
+		}
+		filter.From = t
+	}
+	if value := query.Get("to"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			badRequest(w, "invalid to format, must be YYYY-MM-DD")
+			return
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxIngestionLimit {
+			badRequest(w, "invalid limit, must be between 1 and 1000")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	runs, err := rtr.Ingestions.GetIngestionRuns(ctx, filter)
+	if err != nil {
+		rtr.logger(ctx).Error("failed to obtain ingestion runs", zap.Error(err))
+		internalError(w)
+		return
+	}
+
+	response := make([]ingestionRunResponse, 0, len(runs))
+	for _, run := range runs {
+		response = append(response, newIngestionRunResponse(run))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
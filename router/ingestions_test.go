@@ -0,0 +1,63 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRouter_IngestionRuns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	first := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	run := storage.IngestionRun{ID: "run-1", Source: "serve", HTTPStatus: 200, FirstDate: &first, Inserted: 31}
+
+	runs := mock_storage.NewMockIngestionStore(ctrl)
+	runs.EXPECT().GetIngestionRuns(gAny, storage.IngestionFilter{
+		From:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+		Limit: 5,
+	}).Return([]storage.IngestionRun{run}, nil)
+	runs.EXPECT().GetIngestionRun(gAny, "run-1").Return(run, nil).Times(2)
+	runs.EXPECT().GetIngestionRun(gAny, "missing").Return(storage.IngestionRun{}, storage.ErrNotFound)
+
+	rtr := NewRouter(rakuten.NewHandler(mock_storage.NewMockRakutenStore(ctrl)))
+	rtr.Ingestions = runs
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/admin/ingestions?from=2023-01-01&to=2023-01-02&limit=5", http.StatusOK},
+		{"/admin/ingestions/run-1", http.StatusOK},
+		{"/admin/ingestions/missing", http.StatusNotFound},
+		{"/admin/ingestions?from=yesterday", http.StatusBadRequest},
+		{"/admin/ingestions?limit=5000", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rtr.IngestionRuns(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: got status %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	rtr.IngestionRuns(w, httptest.NewRequest(http.MethodGet, "/admin/ingestions/run-1", nil))
+	var body map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["first_date"] != "2023-01-02" || body["rows_inserted"] != float64(31) {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
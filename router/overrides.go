@@ -0,0 +1,152 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// RateOverrides serves /admin/overrides (GET to list, POST to create) and
+// /admin/overrides/{id} (GET, PUT to replace the rate and reason, DELETE,
+// which answers 202 with the approval to request when approval is
+// required).
+// The list is filtered by ?from=YYYY-MM-DD&to=YYYY-MM-DD and ?as_of=.
+// Changes are attributed to the name of the authenticated key.
+func (rtr *Router) RateOverrides(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.H.Overrides == nil {
+		notFound(w)
+		return
+	}
+
+	var author, authorKey string
+	if key, ok := auth.KeyFromContext(ctx); ok {
+		author, authorKey = key.Name, key.ID
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/overrides"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		req := &rakuten.GetOverridesRequest{}
+		query := r.URL.Query()
+		if value := query.Get("from"); value != "" {
+			t, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				badRequest(w, "invalid from format, must be YYYY-MM-DD")
+				return
+			}
+			req.StartDate = t
+		}
+		if value := query.Get("to"); value != "" {
+			t, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				badRequest(w, "invalid to format, must be YYYY-MM-DD")
+				return
+			}
+			req.EndDate = t
+		}
+		asOf, err := parseAsOf(r)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		req.AsOf = asOf
+
+		overrides, err := rtr.H.GetOverrides(ctx, req)
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain rate overrides", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, overrides)
+
+	case id == "" && r.Method == http.MethodPost:
+		req := &rakuten.OverrideRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			badRequest(w, "invalid request body")
+			return
+		}
+		req.Author, req.AuthorKey = author, authorKey
+
+		override, err := rtr.H.CreateOverride(ctx, req)
+		if errors.Is(err, rakuten.ErrInvalidOverride) {
+			badRequest(w, err.Error())
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to create rate override", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusCreated, override)
+
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodGet:
+		override, err := rtr.H.GetOverride(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain rate override", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, override)
+
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodPut:
+		req := &rakuten.OverrideRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			badRequest(w, "invalid request body")
+			return
+		}
+		req.Author, req.AuthorKey = author, authorKey
+
+		override, err := rtr.H.UpdateOverride(ctx, id, req)
+		if errors.Is(err, rakuten.ErrInvalidOverride) {
+			badRequest(w, err.Error())
+			return
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to update rate override", zap.Error(err))
+			internalError(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, override)
+
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodDelete:
+		approval, err := rtr.H.DeleteOverride(ctx, id, author, authorKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			notFound(w)
+			return
+		}
+		if err != nil {
+			rtr.logger(ctx).Error("failed to delete rate override", zap.Error(err))
+			internalError(w)
+			return
+		}
+		if approval != nil {
+			// deleted once approved
+			writeJSON(w, http.StatusAccepted, approval)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		notFound(w)
+	}
+}
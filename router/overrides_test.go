@@ -0,0 +1,59 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRouter_RateOverrides(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	override := storage.RateOverride{ID: "override-1", Base: "EUR", Quote: "USD", Rate: "1.2", Date: date, Reason: "feed error", Author: "ops"}
+
+	overrides := mock_storage.NewMockOverrideStore(ctrl)
+	overrides.EXPECT().CreateRateOverride(gAny, gAny).DoAndReturn(func(_ context.Context, o storage.RateOverride) (string, error) {
+		if o.Author != "ops" || o.Quote != "USD" || !o.Date.Equal(date) {
+			t.Errorf("unexpected override %+v", o)
+		}
+		return "override-1", nil
+	})
+	overrides.EXPECT().GetRateOverride(gAny, "override-1").Return(override, nil)
+	overrides.EXPECT().DeleteRateOverride(gAny, "missing").Return(storage.ErrNotFound)
+
+	h := rakuten.NewHandler(mock_storage.NewMockRakutenStore(ctrl))
+	h.Overrides = overrides
+	rtr := NewRouter(h)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r = r.WithContext(auth.NewContext(r.Context(), &auth.Key{ID: "key-1", Name: "ops"}))
+		w := httptest.NewRecorder()
+		rtr.RateOverrides(w, r)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/admin/overrides", `{"quote": "USD", "date": "2023-01-05", "rate": "1.2", "reason": "feed error"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"author":"ops"`) {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body)
+	}
+
+	if w := serve(http.MethodPost, "/admin/overrides", `{"quote": "USD", "date": "2023-01-05", "rate": "1.2"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a reason, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/admin/overrides/missing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting an unknown override, got %d", w.Code)
+	}
+}
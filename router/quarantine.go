@@ -0,0 +1,103 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	defaultQuarantineLimit = 100
+	maxQuarantineLimit     = 1000
+)
+
+type quarantinedRateResponse struct {
+	ID         string    `json:"id"`
+	Base       string    `json:"base"`
+	Quote      string    `json:"quote"`
+	Rate       string    `json:"rate,omitempty"`
+	Date       string    `json:"date"`
+	Rule       string    `json:"rule"`
+	Detail     string    `json:"detail,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// QuarantinedRates serves /admin/quarantine, the ingested rates that failed a
+// data quality rule newest first, filtered by ?from=YYYY-MM-DD&to=YYYY-MM-DD
+// (both inclusive, on the publication date) and ?rule=, and capped by
+// ?limit=.
+func (rtr *Router) QuarantinedRates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	if rtr.Quarantine == nil || r.Method != http.MethodGet || r.URL.Path != "/admin/quarantine" {
+		notFound(w)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.QuarantineFilter{Limit: defaultQuarantineLimit}
+
+	if value := query.Get("from"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			badRequest(w, "invalid from format, must be YYYY-MM-DD")
+			return
+		}
+		filter.StartDate = t
+	}
+	if value := query.Get("to"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			badRequest(w, "invalid to format, must be YYYY-MM-DD")
+			return
+		}
+		filter.EndDate = t
+	}
+	if value := query.Get("rule"); value != "" {
+		known := false
+		for _, rule := range rakuten.QualityRules {
+			known = known || rule == value
+		}
+		if !known {
+			badRequest(w, "invalid rule")
+			return
+		}
+		filter.Rule = value
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxQuarantineLimit {
+			badRequest(w, "invalid limit, must be between 1 and 1000")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	rates, err := rtr.Quarantine.GetQuarantinedRates(ctx, filter)
+	if err != nil {
+		rtr.logger(ctx).Error("failed to obtain quarantined rates", zap.Error(err))
+		internalError(w)
+		return
+	}
+
+	response := make([]quarantinedRateResponse, 0, len(rates))
+	for _, rate := range rates {
+		response = append(response, quarantinedRateResponse{
+			ID:         rate.ID,
+			Base:       rate.Base,
+			Quote:      rate.Quote,
+			Rate:       rate.Rate,
+			Date:       rate.Date.Format("2006-01-02"),
+			Rule:       rate.Rule,
+			Detail:     rate.Detail,
+			DetectedAt: rate.DetectedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
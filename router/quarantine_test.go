@@ -0,0 +1,63 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRouter_QuarantinedRates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	rate := storage.QuarantinedRate{ID: "q-1", Base: "EUR", Quote: "USD", Rate: "-1", Date: date, Rule: rakuten.RuleNonPositive}
+
+	quarantine := mock_storage.NewMockQuarantineStore(ctrl)
+	quarantine.EXPECT().GetQuarantinedRates(gAny, storage.QuarantineFilter{
+		StartDate: date,
+		EndDate:   date,
+		Rule:      rakuten.RuleNonPositive,
+		Limit:     5,
+	}).Return([]storage.QuarantinedRate{rate}, nil)
+	quarantine.EXPECT().GetQuarantinedRates(gAny, storage.QuarantineFilter{Limit: 100}).Return([]storage.QuarantinedRate{rate}, nil)
+
+	rtr := NewRouter(rakuten.NewHandler(mock_storage.NewMockRakutenStore(ctrl)))
+	rtr.Quarantine = quarantine
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/admin/quarantine?from=2023-01-05&to=2023-01-05&rule=non_positive&limit=5", http.StatusOK},
+		{"/admin/quarantine?rule=spike", http.StatusBadRequest},
+		{"/admin/quarantine?to=tomorrow", http.StatusBadRequest},
+		{"/admin/quarantine?limit=0", http.StatusBadRequest},
+		{"/admin/quarantine/q-1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rtr.QuarantinedRates(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: got status %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	rtr.QuarantinedRates(w, httptest.NewRequest(http.MethodGet, "/admin/quarantine", nil))
+	var body []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 1 || body[0]["date"] != "2023-01-05" || body[0]["rule"] != "non_positive" || body[0]["rate"] != "-1" {
+		t.Fatalf("unexpected response %v", body)
+	}
+}
@@ -1,23 +1,55 @@
 package router
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/alerts"
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/logging"
 	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/usage"
 )
 
 type Router struct {
 	H *rakuten.Handler
+
+	// Alerts, when set, enables the /alerts endpoints.
+	Alerts *alerts.Service
+
+	// Auth, when set, enables the /admin/keys endpoints.
+	Auth *auth.Service
+
+	// Usage, when set, enables the /admin/usage endpoint.
+	Usage *usage.Meter
+
+	// Ingestions, when set, enables the /admin/ingestions endpoints.
+	Ingestions storage.IngestionStore
+
+	// Quarantine, when set, enables the /admin/quarantine endpoint.
+	Quarantine storage.QuarantineStore
+
+	// Logger receives errors hit while serving requests. It defaults to
+	// the global logger.
+	Logger *zap.Logger
 }
 
 func NewRouter(h *rakuten.Handler) *Router {
 	return &Router{H: h}
 }
 
+// logger returns rtr.Logger annotated with the request ID of ctx.
+func (rtr *Router) logger(ctx context.Context) *zap.Logger {
+	return logging.With(ctx, rtr.Logger)
+}
+
 func (rtr *Router) Ping(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -47,45 +79,92 @@ func (rtr *Router) GetCurrencyRate(w http.ResponseWriter, r *http.Request) {
 		req.Date = t
 	}
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	req.AsOf = asOf
+
 	rates, err := rtr.H.GetCurrencyRate(ctx, req)
 	if err != nil {
-		log.Println("failed to obtained currency rates")
+		rtr.logger(ctx).Error("failed to obtain currency rates", zap.Error(err))
 		internalError(w)
 		return
 	}
 
 	ratesResponseJson, err := json.Marshal(rates)
 	if err != nil {
-		log.Println("failed to marshal rates")
+		rtr.logger(ctx).Error("failed to marshal rates", zap.Error(err))
 		internalError(w)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(ratesResponseJson)
+	cacheControl := currentRatesCacheControl
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if !req.AsOf.IsZero() && req.AsOf.Before(time.Now().Add(-asOfSettled)) {
+		cacheControl = asOfRatesCacheControl
+	} else if !req.GetLatestDate && req.Date.Before(today) && len(rates.Rates) > 0 {
+		cacheControl = pastRatesCacheControl
+	}
+
+	writeCacheable(w, r, ratesResponseJson, rates.LastModified, cacheControl)
 }
 
 func (rtr *Router) GetAnalyzedCurrencyRate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	w.Header().Set("Content-Type", "application/json")
 
-	rates, err := rtr.H.GetAnalyzedCurrencyRate(ctx)
+	asOf, err := parseAsOf(r)
 	if err != nil {
-		log.Println("failed to obtained analyzed currency rates")
+		badRequest(w, err.Error())
+		return
+	}
+
+	rates, err := rtr.H.GetAnalyzedCurrencyRate(ctx, &rakuten.GetAnalyzedCurrencyRateRequest{AsOf: asOf})
+	if err != nil {
+		rtr.logger(ctx).Error("failed to obtain analyzed currency rates", zap.Error(err))
 		internalError(w)
 		return
 	}
 
 	ratesResponseJson, err := json.Marshal(rates)
 	if err != nil {
-		log.Println("failed to marshal analyzed rates")
+		rtr.logger(ctx).Error("failed to marshal analyzed rates", zap.Error(err))
 		internalError(w)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(ratesResponseJson)
 }
 
+// parseAsOf returns the ?as_of= timestamp of r, or the zero time when it
+// is absent.
+func parseAsOf(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("as_of")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid as_of format, must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	responseJson, err := json.Marshal(v)
+	if err != nil {
+		zap.L().Error("failed to marshal response", zap.Error(err))
+		internalError(w)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write(responseJson)
+}
+
 type ErrorResponse struct {
 	Message string `json:"message"`
 }
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestRouter_GetCurrencyRate_AsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	asOf := time.Date(2023, 1, 6, 12, 0, 0, 0, time.UTC)
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, storage.CurrencyFilter{Date: date, AsOf: asOf}).
+		Return([]storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1.1", Date: date}}, nil)
+	mockStore.EXPECT().GetAnalyzedCurrencyRates(gAny, storage.AnalysisFilter{AsOf: asOf}).
+		Return([]storage.AnalyzedRate{{Base: "EUR", Quote: "USD", Min: "1.1", Max: "1.1", Avg: "1.1"}}, nil)
+
+	rtr := NewRouter(rakuten.NewHandler(mockStore))
+
+	w := httptest.NewRecorder()
+	rtr.GetCurrencyRate(w, httptest.NewRequest(http.MethodGet, "/rates/2023-01-05?as_of=2023-01-06T12:00:00Z", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != asOfRatesCacheControl {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	// writes in flight may still land before a moment this recent
+	recent := time.Now().Add(-10 * time.Second).UTC().Truncate(time.Second)
+	mockStore.EXPECT().GetCurrencyRates(gAny, storage.CurrencyFilter{Date: date, AsOf: recent}).
+		Return([]storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1.1", Date: date}}, nil)
+	w = httptest.NewRecorder()
+	rtr.GetCurrencyRate(w, httptest.NewRequest(http.MethodGet, "/rates/2023-01-05?as_of="+recent.Format(time.RFC3339), nil))
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != pastRatesCacheControl {
+		t.Fatalf("expected a recent as_of not to be immutable, got %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	rtr.GetAnalyzedCurrencyRate(w, httptest.NewRequest(http.MethodGet, "/rates/analyze?as_of=2023-01-06T12:00:00Z", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected analysis response %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	rtr.GetCurrencyRate(w, httptest.NewRequest(http.MethodGet, "/rates/2023-01-05?as_of=2023-01-06", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an as_of without a time, got %d", w.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/events"
+	"github.com/syahnur197/rakuten/rakuten"
+)
+
+const streamKeepAliveInterval = 30 * time.Second
+
+type streamEvent struct {
+	Base  string            `json:"base"`
+	Date  string            `json:"date"`
+	Rates map[string]string `json:"rates"`
+}
+
+// StreamCurrencyRates is a Server-Sent Events stream with one "rates" event
+// per newly stored publication date. The event ID is the publication date,
+// so a client reconnecting with Last-Event-ID (or ?last_event_id= for
+// clients that cannot set headers) first receives every publication after
+// that date from storage. ?symbols=USD,JPY limits the rates in each event.
+func (rtr *Router) StreamCurrencyRates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok || rtr.H.Events == nil {
+		w.Header().Set("Content-Type", "application/json")
+		internalError(w)
+		return
+	}
+
+	var last time.Time
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventID != "" {
+		t, err := time.Parse("2006-01-02", lastEventID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			badRequest(w, "invalid Last-Event-ID, must be YYYY-MM-DD")
+			return
+		}
+		last = t
+	}
+
+	symbols := parseSymbols(r.URL.Query().Get("symbols"))
+
+	// subscribe before catching up so nothing published in between is lost
+	sub := rtr.H.Events.Subscribe()
+	defer rtr.H.Events.Unsubscribe(sub)
+
+	if err := clearWriteDeadline(w); err != nil {
+		rtr.logger(ctx).Debug("stream is subject to the write timeout", zap.Error(err))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if !last.IsZero() {
+		missed, err := rtr.H.GetCurrencyRateRange(ctx, &rakuten.GetCurrencyRateRangeRequest{
+			StartDate: last.AddDate(0, 0, 1),
+			Quotes:    symbols,
+		})
+		if err != nil {
+			rtr.logger(ctx).Error("failed to obtain missed currency rates", zap.Error(err))
+			return
+		}
+
+		for _, rates := range missed {
+			if err := writeStreamEvent(w, rates.Base, rates.Date, rates.Rates); err != nil {
+				return
+			}
+			last = rates.Date
+		}
+		flusher.Flush()
+	}
+
+	ticker := time.NewTicker(streamKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				// dropped for falling behind or closed on shutdown, the
+				// client resumes on reconnect
+				return
+			}
+			if !e.Date.After(last) {
+				continue
+			}
+
+			if err := writeStreamEvent(w, e.Base, e.Date, filterRates(e, symbols)); err != nil {
+				return
+			}
+			flusher.Flush()
+			last = e.Date
+		}
+	}
+}
+
+// clearWriteDeadline lifts the server's write timeout from a stream, which
+// lasts as long as the client stays. It unwraps middleware the way
+// http.ResponseController does, which the module's Go version predates.
+func clearWriteDeadline(w http.ResponseWriter) error {
+	for {
+		switch rw := w.(type) {
+		case interface{ SetWriteDeadline(time.Time) error }:
+			return rw.SetWriteDeadline(time.Time{})
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return errors.New("the response writer has no write deadline")
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, base string, date time.Time, rates map[string]string) error {
+	id := date.Format("2006-01-02")
+
+	data, err := json.Marshal(streamEvent{Base: base, Date: id, Rates: rates})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: rates\ndata: %s\n\n", id, data)
+	return err
+}
+
+func filterRates(e events.RatesPublished, symbols []string) map[string]string {
+	if len(symbols) == 0 {
+		return e.Rates
+	}
+
+	rates := map[string]string{}
+	for _, symbol := range symbols {
+		if rate, ok := e.Rates[symbol]; ok {
+			rates[symbol] = rate
+		}
+	}
+	return rates
+}
+
+func parseSymbols(value string) []string {
+	var symbols []string
+	for _, symbol := range strings.Split(value, ",") {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
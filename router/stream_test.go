@@ -0,0 +1,91 @@
+package router
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/events"
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+	"github.com/syahnur197/rakuten/tracing"
+)
+
+func TestRouter_StreamCurrencyRates_Ends(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(rtr *Router)
+	}{
+		{
+			name: "bus closed",
+			setup: func(rtr *Router) {
+				time.AfterFunc(50*time.Millisecond, rtr.H.Events.Close)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			h := rakuten.NewHandler(mock_storage.NewMockRakutenStore(ctrl))
+			h.Events = events.NewBus()
+			rtr := NewRouter(h)
+			tt.setup(rtr)
+
+			done := make(chan *httptest.ResponseRecorder)
+			go func() {
+				w := httptest.NewRecorder()
+				rtr.StreamCurrencyRates(w, httptest.NewRequest(http.MethodGet, "/rates/stream", nil))
+				done <- w
+			}()
+
+			select {
+			case w := <-done:
+				if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+					t.Errorf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream did not end")
+			}
+		})
+	}
+}
+
+func TestRouter_StreamCurrencyRates_WriteTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	h := rakuten.NewHandler(mock_storage.NewMockRakutenStore(ctrl))
+	h.Events = events.NewBus()
+	rtr := NewRouter(h)
+
+	const writeTimeout = 100 * time.Millisecond
+	server := httptest.NewUnstartedServer(tracing.Middleware("/rates/stream", rtr.StreamCurrencyRates))
+	server.Config.WriteTimeout = writeTimeout
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// subscribed once the headers are sent, published past the timeout
+	time.AfterFunc(3*writeTimeout, func() {
+		h.Events.Publish(events.RatesPublished{Base: "EUR", Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), Rates: map[string]string{"USD": "1.1"}})
+	})
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if lines.Text() == "event: rates" {
+			return
+		}
+	}
+	t.Fatalf("stream ended before the event: %v", lines.Err())
+}
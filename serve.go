@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/alerts"
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/events"
+	"github.com/syahnur197/rakuten/gql"
+	"github.com/syahnur197/rakuten/health"
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/metrics"
+	"github.com/syahnur197/rakuten/rakuten"
+	"github.com/syahnur197/rakuten/router"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/cache"
+	"github.com/syahnur197/rakuten/tracing"
+	"github.com/syahnur197/rakuten/usage"
+)
+
+// serve migrates the schema, serves the API until ctx is done and ingests
+// the ECB feed in the background.
+func serve(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	app, err := newApp(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	logger, s := app.logger, app.store
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: "rakuten",
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to set up tracing")
+	}
+
+	m := metrics.New()
+	m.RegisterDB(app.db.DB, cfg.DB.Name)
+
+	c := cache.New(m.InstrumentStore(s), cache.DefaultSize, cache.DefaultTTL)
+	m.RegisterCache(c)
+
+	h := rakuten.NewHandler(c)
+	h.Events = events.NewBus()
+	h.Overrides = s
+	if cfg.Approval.Required {
+		h.Approvals = s
+	}
+	h.Quality = cfg.Quality.Checks()
+	h.Quarantine = s
+	h.Logger = logger
+
+	// setup database schema
+	logger.Info("initialise schema")
+	if err := s.Migrate(); err != nil {
+		return err
+	}
+
+	if cfg.Auth.AdminAPIKey == "" {
+		logger.Warn("ADMIN_API_KEY is not set, api keys can only be managed with an existing admin key")
+	}
+	authService := auth.NewService(s, cfg.Auth.AdminAPIKey)
+	authService.Logger = logger
+	meter := usage.NewMeter(s, authService)
+	meter.Logger = logger
+
+	// workers outlive ctx until the server has drained, so that the usage
+	// of the last requests is flushed
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		meter.Run(workerCtx, usage.DefaultFlushInterval)
+	}()
+
+	a := alerts.NewService(s, h)
+	a.Logger = logger
+
+	// webhooks are sent apart from ingestion, a slow receiver only delays
+	// other deliveries
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		a.Run(ctx, alerts.DefaultDeliveryInterval)
+	}()
+
+	checker := health.NewChecker(s, h)
+	checker.MaxMissedPublications = cfg.Health.MaxMissedPublications
+	checker.MaxIngestionAge = cfg.Health.MaxIngestionAge
+
+	// fetch and store currency rates in the background, requests are
+	// served from the stored rates meanwhile and /readyz reports whether
+	// there are any
+	ingestCtx, stopIngestion := context.WithCancel(ctx)
+	defer stopIngestion()
+
+	ing := &ingester{url: cfg.ECB.URL, h: h, runs: s, metrics: m, health: checker, logger: logger}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ing.run(ingestCtx, cfg.ECB.IngestInterval, a)
+	}()
+
+	// setting up mux
+	logger.Info("setting up mux")
+	mux := http.NewServeMux()
+
+	r := router.NewRouter(h)
+	r.Alerts = a
+	r.Auth = authService
+	r.Usage = meter
+	r.Ingestions = s
+	r.Quarantine = s
+	r.Logger = logger
+
+	gqlServer, err := gql.NewServer(h)
+	if err != nil {
+		stopIngestion()
+		stopWorkers()
+		workers.Wait()
+		return errors.Wrap(err, "failed to build graphql schema")
+	}
+
+	handle := func(pattern, route, scope string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, tracing.Middleware(route, m.Instrument(route, authService.Require(scope, meter.Track(route, handler)))))
+	}
+
+	public := func(route string, handler http.HandlerFunc) {
+		mux.HandleFunc(route, tracing.Middleware(route, m.Instrument(route, handler)))
+	}
+
+	public("/ping", r.Ping)
+	public("/healthz", checker.Healthz)
+	public("/readyz", checker.Readyz)
+	public("/status", checker.Status)
+	mux.Handle("/metrics", m.Handler())
+	handle("/rates/analyze", "/rates/analyze", auth.ScopeRatesRead, r.GetAnalyzedCurrencyRate)
+	handle("/rates/stream", "/rates/stream", auth.ScopeRatesRead, r.StreamCurrencyRates)
+	handle("/rates/", "/rates/{date}", auth.ScopeRatesRead, r.GetCurrencyRate)
+	handle("/graphql", "/graphql", auth.ScopeRatesRead, gqlServer.ServeHTTP)
+	handle("/alerts", "/alerts", auth.ScopeAlerts, r.AlertRules)
+	handle("/alerts/", "/alerts/{id}", auth.ScopeAlerts, r.AlertRules)
+	handle("/admin/keys", "/admin/keys", auth.ScopeAdmin, r.APIKeys)
+	handle("/admin/keys/", "/admin/keys/{id}", auth.ScopeAdmin, r.APIKeys)
+	handle("/admin/usage", "/admin/usage", auth.ScopeAdmin, r.UsageReport)
+	handle("/admin/ingestions", "/admin/ingestions", auth.ScopeAdmin, r.IngestionRuns)
+	handle("/admin/ingestions/", "/admin/ingestions/{id}", auth.ScopeAdmin, r.IngestionRuns)
+	handle("/admin/overrides", "/admin/overrides", auth.ScopeAdmin, r.RateOverrides)
+	handle("/admin/overrides/", "/admin/overrides/{id}", auth.ScopeAdmin, r.RateOverrides)
+	handle("/admin/approvals", "/admin/approvals", auth.ScopeAdmin, r.Approvals)
+	handle("/admin/approvals/", "/admin/approvals/{id}", auth.ScopeAdmin, r.Approvals)
+	handle("/admin/quarantine", "/admin/quarantine", auth.ScopeAdmin, r.QuarantinedRates)
+
+	server := &http.Server{
+		Addr:         cfg.HTTP.Addr,
+		Handler:      logging.RequestID(logging.AccessLog(logger, mux)),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+	// Shutdown does not wait for streams, close them so they drain too
+	server.RegisterOnShutdown(h.Events.Close)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", zap.String("addr", cfg.HTTP.Addr))
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		err = errors.Wrap(err, "server stopped")
+	case <-ctx.Done():
+		err = nil
+	}
+
+	logger.Info("shutting down", zap.Duration("timeout", cfg.HTTP.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to drain connections", zap.Error(err))
+	}
+
+	stopIngestion()
+	stopWorkers()
+	workers.Wait()
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("failed to flush traces", zap.Error(err))
+	}
+	logger.Info("stopped")
+	return err
+}
+
+// ingestRetryBackoff is how soon a failed ingestion is first retried.
+const ingestRetryBackoff = 30 * time.Second
+
+// ingester fetches the ECB feed and stores new publications, reporting
+// every run to the metrics and the health checker.
+type ingester struct {
+	url     string
+	h       *rakuten.Handler
+	runs    storage.IngestionStore
+	metrics *metrics.Metrics
+	health  *health.Checker
+	logger  *zap.Logger
+}
+
+// run fetches the ECB feed right away and then every interval, so that
+// new publications are stored and streamed without a restart, and
+// evaluates alert rules against the publications stored by every run.
+// Failed runs are retried sooner, backing off from ingestRetryBackoff up
+// to interval. It returns once ctx is done.
+func (i *ingester) run(ctx context.Context, interval time.Duration, a *alerts.Service) {
+	retry := ingestRetryBackoff
+
+	// so that the publications the first run stores are new to Evaluate
+	if err := a.Evaluate(ctx); err != nil && ctx.Err() == nil {
+		i.logger.Error("failed to evaluate alert rules", zap.Error(err))
+	}
+
+	for {
+		wait := interval
+		if err := i.ingest(ctx); err != nil {
+			if ctx.Err() != nil {
+				// interrupted by shutdown
+				return
+			}
+
+			if retry < interval {
+				wait = retry
+			}
+			retry *= 2
+			i.logger.Error("failed to ingest currency rates", zap.Duration("retry_in", wait), zap.Error(err))
+		} else {
+			retry = ingestRetryBackoff
+		}
+
+		if err := a.Evaluate(ctx); err != nil && ctx.Err() == nil {
+			i.logger.Error("failed to evaluate alert rules", zap.Error(err))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// ingest fetches the ECB feed once and stores the new publications.
+func (i *ingester) ingest(ctx context.Context) error {
+	result, err := i.fetchAndStore(ctx)
+	i.health.RecordIngestion(result.Written(), err)
+	return err
+}
+
+// fetchAndStore records every run in the ingestion audit log.
+func (i *ingester) fetchAndStore(ctx context.Context) (result rakuten.IngestResult, err error) {
+	run := newRun("serve", i.url)
+	var feed *rakuten.Feed
+	defer func() { recordRun(i.runs, i.logger, run, feed, result, err) }()
+
+	feed, err = rakuten.FetchFeed(ctx, i.url)
+	if err != nil {
+		i.metrics.ObserveIngestion(metrics.IngestionFetchError, 0, time.Time{})
+		return result, errors.Wrap(err, "failed to fetch currency rates")
+	}
+
+	result, err = i.h.IngestCurrencyRates(ctx, feed.Rates)
+	latest, latestErr := i.h.LatestPublishedDate(ctx)
+	if latestErr != nil {
+		i.logger.Error("failed to get latest publication date", zap.Error(latestErr))
+	}
+	if err != nil {
+		i.metrics.ObserveIngestion(metrics.IngestionStoreError, result.Written(), latest)
+		return result, errors.Wrap(err, "failed to store currency rates")
+	}
+
+	i.metrics.ObserveIngestion(metrics.IngestionSuccess, result.Written(), latest)
+	if result.Written() > 0 {
+		i.logger.Info("stored new currency rates", zap.Int("inserted", result.Inserted), zap.Int("updated", result.Updated))
+	}
+	return result, nil
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	createAlertRuleSql = `
+		INSERT INTO alert_rule (
+			kind,
+			base,
+			quote,
+			direction,
+			threshold,
+			webhook_url,
+			secret,
+			key_id
+		) VALUES (
+			:kind,
+			:base,
+			:quote,
+			:direction,
+			:threshold,
+			:webhook_url,
+			:secret,
+			:key_id
+		) RETURNING id;
+	`
+
+	getAlertRuleSql = `
+		SELECT
+			id,
+			kind,
+			base,
+			quote,
+			direction,
+			TRIM(TRAILING '.' FROM (TRIM(TRAILING '0' FROM CAST(threshold AS TEXT)))) as threshold,
+			webhook_url,
+			secret,
+			key_id,
+			created_at
+		FROM alert_rule
+	`
+
+	deleteAlertRuleSql = `
+		DELETE FROM alert_rule WHERE id = :id
+	`
+
+	createAlertDeliverySql = `
+		INSERT INTO alert_delivery (
+			rule_id,
+			quote,
+			published_date,
+			payload,
+			status
+		) VALUES (
+			:rule_id,
+			:quote,
+			:published_date,
+			:payload,
+			:status
+		)
+		ON CONFLICT (rule_id, quote, published_date) DO NOTHING
+		RETURNING id;
+	`
+
+	updateAlertDeliverySql = `
+		UPDATE alert_delivery SET
+			status = :status,
+			attempts = :attempts,
+			response_status = :response_status,
+			error = :error,
+			next_attempt_at = :next_attempt_at,
+			updated_at = NOW()
+		WHERE id = :id
+	`
+
+	getAlertDeliveriesSql = `
+		SELECT
+			id,
+			rule_id,
+			quote,
+			published_date,
+			payload,
+			status,
+			attempts,
+			response_status,
+			error,
+			created_at,
+			updated_at,
+			next_attempt_at
+		FROM alert_delivery
+	`
+)
+
+func (s *Storage) CreateAlertRule(ctx context.Context, rule AlertRule) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateAlertRule")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createAlertRuleSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, rule).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create alert rule")
+	}
+	return id, nil
+}
+
+func (s *Storage) GetAlertRules(ctx context.Context, keyID string) (_ []AlertRule, err error) {
+	ctx, end := s.startQuery(ctx, "GetAlertRules")
+	defer func() { end(err) }()
+
+	var rules []AlertRule
+
+	var conditions []string
+	params := map[string]interface{}{}
+	if keyID != "" {
+		conditions = append(conditions, "key_id = :key_id")
+		params["key_id"] = keyID
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, fmt.Sprintf("%s %s ORDER BY created_at", getAlertRuleSql, where(conditions)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving alert rules")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &rules, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve alert rules")
+	}
+	return rules, nil
+}
+
+func (s *Storage) GetAlertRule(ctx context.Context, id string) (_ AlertRule, err error) {
+	ctx, end := s.startQuery(ctx, "GetAlertRule")
+	defer func() { end(err) }()
+
+	var rule AlertRule
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAlertRuleSql+" WHERE id = :id")
+	if err != nil {
+		return rule, errors.Wrap(err, "failed to prepare statement for retrieving alert rule")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &rule, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rule, ErrNotFound
+		}
+		return rule, errors.Wrap(err, "failed to retrieve alert rule")
+	}
+	return rule, nil
+}
+
+func (s *Storage) DeleteAlertRule(ctx context.Context, id, keyID string) (err error) {
+	ctx, end := s.startQuery(ctx, "DeleteAlertRule")
+	defer func() { end(err) }()
+
+	query := deleteAlertRuleSql
+	params := map[string]interface{}{"id": id}
+	if keyID != "" {
+		query += " AND key_id = :key_id"
+		params["key_id"] = keyID
+	}
+	return s.execAffectingOne(ctx, query, params, "failed to delete alert rule")
+}
+
+func (s *Storage) CreateAlertDelivery(ctx context.Context, delivery AlertDelivery) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateAlertDelivery")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createAlertDeliverySql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, delivery).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to create alert delivery")
+	}
+	return id, nil
+}
+
+func (s *Storage) UpdateAlertDelivery(ctx context.Context, delivery AlertDelivery) (err error) {
+	ctx, end := s.startQuery(ctx, "UpdateAlertDelivery")
+	defer func() { end(err) }()
+
+	if _, err := s.db.NamedExecContext(ctx, updateAlertDeliverySql, delivery); err != nil {
+		return errors.Wrap(err, "failed to update alert delivery")
+	}
+	return nil
+}
+
+func (s *Storage) GetAlertDeliveries(ctx context.Context, ruleID string) (_ []AlertDelivery, err error) {
+	ctx, end := s.startQuery(ctx, "GetAlertDeliveries")
+	defer func() { end(err) }()
+
+	var deliveries []AlertDelivery
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAlertDeliveriesSql+" WHERE rule_id = :rule_id ORDER BY created_at DESC")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving alert deliveries")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &deliveries, map[string]interface{}{"rule_id": ruleID}); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve alert deliveries")
+	}
+	return deliveries, nil
+}
+
+func (s *Storage) GetDueAlertDeliveries(ctx context.Context, limit int) (_ []AlertDelivery, err error) {
+	ctx, end := s.startQuery(ctx, "GetDueAlertDeliveries")
+	defer func() { end(err) }()
+
+	var deliveries []AlertDelivery
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAlertDeliveriesSql+`
+		WHERE status = 'pending' AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT :limit`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving due alert deliveries")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &deliveries, map[string]interface{}{"limit": limit}); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve due alert deliveries")
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	createAPIKeySql = `
+		INSERT INTO api_key (
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			rate_limit,
+			burst,
+			monthly_quota,
+			quota_mode
+		) VALUES (
+			:name,
+			:prefix,
+			:key_hash,
+			:scopes,
+			:rate_limit,
+			:burst,
+			:monthly_quota,
+			:quota_mode
+		) RETURNING id;
+	`
+
+	getAPIKeySql = `
+		SELECT
+			id,
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			rate_limit,
+			burst,
+			monthly_quota,
+			quota_mode,
+			created_at,
+			revoked_at
+		FROM api_key
+	`
+
+	updateAPIKeyScopesSql = `
+		UPDATE api_key SET scopes = :scopes WHERE id = :id AND revoked_at IS NULL
+	`
+
+	revokeAPIKeySql = `
+		UPDATE api_key SET revoked_at = NOW() WHERE id = :id AND revoked_at IS NULL
+	`
+)
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key APIKey) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateAPIKey")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createAPIKeySql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, key).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create api key")
+	}
+	return id, nil
+}
+
+func (s *Storage) GetAPIKeys(ctx context.Context) (_ []APIKey, err error) {
+	ctx, end := s.startQuery(ctx, "GetAPIKeys")
+	defer func() { end(err) }()
+
+	var keys []APIKey
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAPIKeySql+" ORDER BY created_at")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving api keys")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &keys, map[string]interface{}{}); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve api keys")
+	}
+	return keys, nil
+}
+
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (_ APIKey, err error) {
+	ctx, end := s.startQuery(ctx, "GetAPIKeyByHash")
+	defer func() { end(err) }()
+
+	var key APIKey
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAPIKeySql+" WHERE key_hash = :key_hash AND revoked_at IS NULL")
+	if err != nil {
+		return key, errors.Wrap(err, "failed to prepare statement for retrieving api key")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &key, map[string]interface{}{"key_hash": hash}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, ErrNotFound
+		}
+		return key, errors.Wrap(err, "failed to retrieve api key")
+	}
+	return key, nil
+}
+
+func (s *Storage) UpdateAPIKeyScopes(ctx context.Context, id string, scopes []string) (err error) {
+	ctx, end := s.startQuery(ctx, "UpdateAPIKeyScopes")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{"id": id, "scopes": pq.Array(scopes)}
+	return s.execAffectingOne(ctx, updateAPIKeyScopesSql, params, "failed to update api key scopes")
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id string) (err error) {
+	ctx, end := s.startQuery(ctx, "RevokeAPIKey")
+	defer func() { end(err) }()
+
+	return s.execAffectingOne(ctx, revokeAPIKeySql, map[string]interface{}{"id": id}, "failed to revoke api key")
+}
+
+// execAffectingOne runs a named statement and returns ErrNotFound when it
+// did not affect any row.
+func (s *Storage) execAffectingOne(ctx context.Context, query string, params interface{}, message string) error {
+	result, err := s.db.NamedExecContext(ctx, query, params)
+	if err != nil {
+		return errors.Wrap(err, message)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, message)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	createApprovalSql = `
+		INSERT INTO approval (
+			kind,
+			published_date,
+			override_id,
+			requested_by,
+			requested_by_key
+		) VALUES (
+			:kind,
+			:published_date,
+			:override_id,
+			:requested_by,
+			:requested_by_key
+		) RETURNING id;
+	`
+
+	decideApprovalSql = `
+		UPDATE approval SET
+			status = :status,
+			decided_by = :decided_by,
+			decided_by_key = :decided_by_key,
+			decided_at = NOW(),
+			comment = :comment
+		WHERE id = :id AND status = 'pending'
+	`
+
+	getApprovalSql = `
+		SELECT
+			id,
+			kind,
+			published_date,
+			override_id,
+			requested_by,
+			requested_by_key,
+			requested_at,
+			status,
+			decided_by,
+			decided_by_key,
+			decided_at,
+			comment
+		FROM approval
+	`
+)
+
+func (s *Storage) CreateApproval(ctx context.Context, approval Approval) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateApproval")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createApprovalSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, approval).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create approval")
+	}
+	return id, nil
+}
+
+func (s *Storage) GetApprovals(ctx context.Context, filter ApprovalFilter) (_ []Approval, err error) {
+	ctx, end := s.startQuery(ctx, "GetApprovals")
+	defer func() { end(err) }()
+
+	var approvals []Approval
+
+	params := map[string]interface{}{}
+	conditions := dateRangeConditions(filter.StartDate, filter.EndDate, params)
+
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = :kind")
+		params["kind"] = filter.Kind
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = :status")
+		params["status"] = filter.Status
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY requested_at, published_date", getApprovalSql, where(conditions))
+	if filter.Limit > 0 {
+		query += " LIMIT :limit"
+		params["limit"] = filter.Limit
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving approvals")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &approvals, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve approvals")
+	}
+	return approvals, nil
+}
+
+func (s *Storage) GetApproval(ctx context.Context, id string) (_ Approval, err error) {
+	ctx, end := s.startQuery(ctx, "GetApproval")
+	defer func() { end(err) }()
+
+	var approval Approval
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getApprovalSql+" WHERE id = :id")
+	if err != nil {
+		return approval, errors.Wrap(err, "failed to prepare statement for retrieving approval")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &approval, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return approval, ErrNotFound
+		}
+		return approval, errors.Wrap(err, "failed to retrieve approval")
+	}
+	return approval, nil
+}
+
+func (s *Storage) DecideApproval(ctx context.Context, approval Approval) (err error) {
+	ctx, end := s.startQuery(ctx, "DecideApproval")
+	defer func() { end(err) }()
+
+	return s.execAffectingOne(ctx, decideApprovalSql, approval, "failed to decide approval")
+}
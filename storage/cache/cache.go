@@ -0,0 +1,250 @@
+// Package cache provides a read-through caching decorator for
+// storage.RakutenStore.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	DefaultSize = 1024
+	DefaultTTL  = 10 * time.Minute
+
+	// loadTimeout bounds a query to the wrapped store, which outlives the
+	// callers waiting for it.
+	loadTimeout = 30 * time.Second
+)
+
+var (
+	_ storage.RakutenStore = (*Store)(nil)
+)
+
+// Store caches GetCurrencyRates and GetAnalyzedCurrencyRates results of the
+// wrapped store in an LRU with a TTL. Concurrent identical queries are
+// coalesced into one call to the wrapped store, and every write through
+// Store drops the whole cache.
+type Store struct {
+	storage.RakutenStore
+
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	generation uint64
+
+	group singleflight.Group
+	// joined is called once a caller waits for a query, for tests
+	joined func()
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// New wraps s in a cache holding up to size results for ttl each. Zero
+// values fall back to DefaultSize and DefaultTTL.
+func New(s storage.RakutenStore, size int, ttl time.Duration) *Store {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Store{
+		RakutenStore: s,
+		size:         size,
+		ttl:          ttl,
+		now:          time.Now,
+		entries:      map[string]*list.Element{},
+		lru:          list.New(),
+	}
+}
+
+func (s *Store) CreateCurrencyRatesTable() error {
+	defer s.Invalidate()
+	return s.RakutenStore.CreateCurrencyRatesTable()
+}
+
+func (s *Store) CreateCurrencyRate(ctx context.Context, rate storage.Rate) (string, error) {
+	defer s.Invalidate()
+	return s.RakutenStore.CreateCurrencyRate(ctx, rate)
+}
+
+func (s *Store) CreateCurrencyRates(ctx context.Context, rates []storage.Rate) error {
+	defer s.Invalidate()
+	return s.RakutenStore.CreateCurrencyRates(ctx, rates)
+}
+
+func (s *Store) GetCurrencyRates(ctx context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+	key := fmt.Sprintf("rates|%s|%t|%s|%s|%s|%s",
+		formatDate(filter.Date), filter.GetLatestDate, formatDate(filter.StartDate), formatDate(filter.EndDate), quotesKey(filter.Quotes), formatTime(filter.AsOf))
+
+	v, err := s.get(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return s.RakutenStore.GetCurrencyRates(ctx, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]storage.Rate), nil
+}
+
+func (s *Store) GetAnalyzedCurrencyRates(ctx context.Context, filter storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
+	key := fmt.Sprintf("analysis|%s|%s|%s|%s",
+		formatDate(filter.StartDate), formatDate(filter.EndDate), quotesKey(filter.Quotes), formatTime(filter.AsOf))
+
+	v, err := s.get(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return s.RakutenStore.GetAnalyzedCurrencyRates(ctx, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]storage.AnalyzedRate), nil
+}
+
+// Invalidate drops every cached result. Queries already in flight are not
+// cached when they complete.
+func (s *Store) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.entries = map[string]*list.Element{}
+	s.lru.Init()
+}
+
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	entries := s.lru.Len()
+	s.mu.Unlock()
+
+	return Stats{
+		Hits:      atomic.LoadUint64(&s.hits),
+		Misses:    atomic.LoadUint64(&s.misses),
+		Evictions: atomic.LoadUint64(&s.evictions),
+		Entries:   entries,
+	}
+}
+
+func (s *Store) get(ctx context.Context, key string, load func(context.Context) (interface{}, error)) (interface{}, error) {
+	s.mu.Lock()
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*entry)
+		if s.now().Before(e.expires) {
+			s.lru.MoveToFront(el)
+			s.mu.Unlock()
+			atomic.AddUint64(&s.hits, 1)
+			return e.value, nil
+		}
+		s.lru.Remove(el)
+		delete(s.entries, key)
+	}
+	generation := s.generation
+	s.mu.Unlock()
+
+	atomic.AddUint64(&s.misses, 1)
+
+	// the generation is part of the flight key so that a query started
+	// after a write never joins one started before it. The query is shared,
+	// so it does not stop when the caller that started it gives up; each
+	// caller only stops waiting.
+	ch := s.group.DoChan(fmt.Sprintf("%d|%s", generation, key), func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(detached{ctx}, loadTimeout)
+		defer cancel()
+
+		v, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.put(key, v, generation)
+		return v, nil
+	})
+	if s.joined != nil {
+		s.joined()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		return res.Val, res.Err
+	}
+}
+
+// detached keeps the values of a context, such as its trace, without its
+// deadline and cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+func (s *Store) put(key string, v interface{}, generation uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if generation != s.generation {
+		return
+	}
+
+	if el, ok := s.entries[key]; ok {
+		s.lru.Remove(el)
+	}
+	s.entries[key] = s.lru.PushFront(&entry{key: key, value: v, expires: s.now().Add(s.ttl)})
+
+	for s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*entry).key)
+		atomic.AddUint64(&s.evictions, 1)
+	}
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func quotesKey(quotes []string) string {
+	sorted := append([]string(nil), quotes...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/memory"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+	"github.com/syahnur197/rakuten/storage/storagetest"
+)
+
+func TestStore_GetCurrencyRates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	now := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	testData := []storage.Rate{{Base: "EUR", Quote: "USD", Rate: "1.1", Date: now}}
+
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetCurrencyRates(gAny, gAny).Return(testData, nil).Times(3)
+	mockStore.EXPECT().CreateCurrencyRate(gAny, gAny).Return("id", nil)
+
+	s := New(mockStore, 1, time.Minute)
+	s.now = func() time.Time { return now }
+
+	latest := storage.CurrencyFilter{GetLatestDate: true}
+	usd := storage.CurrencyFilter{GetLatestDate: true, Quotes: []string{"USD"}}
+
+	// miss, hit
+	s.GetCurrencyRates(context.Background(), latest)
+	s.GetCurrencyRates(context.Background(), latest)
+
+	// a write drops the cache
+	s.CreateCurrencyRate(context.Background(), storage.Rate{})
+	s.GetCurrencyRates(context.Background(), latest)
+
+	// expired entries are reloaded
+	now = now.Add(2 * time.Minute)
+	s.GetCurrencyRates(context.Background(), latest)
+
+	// a different filter evicts the only entry
+	now = now.Add(-2 * time.Minute)
+	mockStore.EXPECT().GetCurrencyRates(gAny, usd).Return(testData, nil)
+	s.GetCurrencyRates(context.Background(), usd)
+
+	stats := s.Stats()
+	if stats.Hits != 1 || stats.Misses != 4 || stats.Evictions != 1 || stats.Entries != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestStore_CoalescesConcurrentQueries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	release := make(chan struct{})
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetAnalyzedCurrencyRates(gAny, gAny).DoAndReturn(func(context.Context, storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
+		<-release
+		return []storage.AnalyzedRate{{Base: "EUR", Quote: "USD"}}, nil
+	}).Times(1)
+
+	s := New(mockStore, 0, 0)
+	joined := make(chan struct{}, 10)
+	s.joined = func() { joined <- struct{}{} }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rates, err := s.GetAnalyzedCurrencyRates(context.Background(), storage.AnalysisFilter{})
+			if err != nil || len(rates) != 1 {
+				t.Error("unexpected result")
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		<-joined
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestStore_Get_Cancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	release := make(chan struct{})
+	loadErr := make(chan error, 1)
+	mockStore := mock_storage.NewMockRakutenStore(ctrl)
+	mockStore.EXPECT().GetAnalyzedCurrencyRates(gAny, gAny).DoAndReturn(func(ctx context.Context, _ storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
+		<-release
+		loadErr <- ctx.Err()
+		return []storage.AnalyzedRate{{Base: "EUR", Quote: "USD"}}, nil
+	}).Times(1)
+
+	s := New(mockStore, 0, 0)
+	joined := make(chan struct{}, 2)
+	s.joined = func() { joined <- struct{}{} }
+
+	// the caller that starts the query gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := s.GetAnalyzedCurrencyRates(ctx, storage.AnalysisFilter{})
+		first <- err
+	}()
+	<-joined
+
+	second := make(chan []storage.AnalyzedRate, 1)
+	go func() {
+		rates, err := s.GetAnalyzedCurrencyRates(context.Background(), storage.AnalysisFilter{})
+		if err != nil {
+			t.Error(err)
+		}
+		second <- rates
+	}()
+	<-joined
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if rates := <-second; len(rates) != 1 {
+		t.Fatalf("expected the other caller to get the result, got %+v", rates)
+	}
+	if err := <-loadErr; err != nil {
+		t.Fatalf("expected the query to outlive the first caller, got %v", err)
+	}
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.RakutenStore {
+		return New(memory.New(), DefaultSize, DefaultTTL)
+	})
+}
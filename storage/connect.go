@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 10 * time.Second
+)
+
+// Connect opens the Postgres database at dsn and pings it, retrying with
+// exponential backoff until it answers or ctx is done, so that the service
+// survives a database that starts after it.
+func Connect(ctx context.Context, dsn string, logger *zap.Logger) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open database")
+	}
+
+	backoff := connectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+
+		logger.Warn("database unavailable, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, errors.Wrapf(err, "failed to connect to database after %d attempts", attempt)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > connectMaxBackoff {
+			backoff = connectMaxBackoff
+		}
+	}
+}
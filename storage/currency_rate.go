@@ -3,6 +3,10 @@ package storage
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -21,17 +25,60 @@ const (
 		) RETURNING id;
 	`
 
+	// lockCurrencyRatesSql serialises the transactions writing rates until
+	// they end. Under READ COMMITTED, two of them superseding the same rate
+	// at once would each miss the version the other inserts, leaving two
+	// current versions.
+	lockCurrencyRatesSql = `SELECT pg_advisory_xact_lock(hashtext('currency_rate'))`
+
+	// supersedeCurrencyRateSql ends the current version of a rate about to
+	// be written again. NOW() is the start of the transaction, so the new
+	// version is recorded at the same moment.
+	supersedeCurrencyRateSql = `
+		UPDATE currency_rate SET superseded_at = NOW()
+		WHERE base = :base
+			AND quote = :quote
+			AND published_date = :published_date
+			AND superseded_at IS NULL
+	`
+
+	// currency_rate_import holds a batch copied in by CreateCurrencyRates
+	// until the current versions it replaces are superseded.
+	createCurrencyRateImportSql = `
+		CREATE TEMP TABLE currency_rate_import (
+			base VARCHAR(3) NOT NULL,
+			quote VARCHAR(3) NOT NULL,
+			rate NUMERIC(20,10) NOT NULL,
+			published_date DATE NOT NULL
+		) ON COMMIT DROP
+	`
+
+	supersedeImportedCurrencyRatesSql = `
+		UPDATE currency_rate c SET superseded_at = NOW()
+		FROM currency_rate_import i
+		WHERE c.base = i.base
+			AND c.quote = i.quote
+			AND c.published_date = i.published_date
+			AND c.superseded_at IS NULL
+	`
+
+	insertImportedCurrencyRatesSql = `
+		INSERT INTO currency_rate (base, quote, rate, published_date)
+		SELECT base, quote, rate, published_date FROM currency_rate_import
+	`
+
 	getCurrencyRateSql = `
 		SELECT 
 		    base, 
 		    quote, 
-		    TRIM(TRAILING '0' FROM CAST(rate AS TEXT)) as rate, 
-		    published_date 
+		    TRIM(TRAILING '.' FROM (TRIM(TRAILING '0' FROM CAST(rate AS TEXT)))) as rate, 
+		    published_date,
+		    recorded_at
 		FROM currency_rate
 	`
 
 	getLatestCurrencyRateDateSql = `
-		SELECT MAX(published_date) FROM currency_rate LIMIT 1
+		SELECT MAX(published_date) FROM currency_rate
 	`
 
 	getAnalyzedCurrencyRateSql = `
@@ -42,13 +89,33 @@ const (
 			TRIM(TRAILING '.' FROM (TRIM(TRAILING '0' FROM CAST(MAX(rate) AS TEXT)))) as max, 
 			TRIM(TRAILING '.' FROM (TRIM(TRAILING '0' FROM CAST(AVG(rate) AS TEXT)))) as avg
 		FROM currency_rate
+	`
+
+	groupAnalyzedCurrencyRateSql = `
 		GROUP BY base, quote
+		ORDER BY base, quote
 	`
 )
 
-func (s *Storage) CreateCurrencyRate(ctx context.Context, rate Rate) (string, error) {
+func (s *Storage) CreateCurrencyRate(ctx context.Context, rate Rate) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateCurrencyRate")
+	defer func() { end(err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to begin currency rate transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, lockCurrencyRatesSql); err != nil {
+		return "", errors.Wrap(err, "failed to lock currency rates")
+	}
+	if _, err := tx.NamedExecContext(ctx, supersedeCurrencyRateSql, rate); err != nil {
+		return "", errors.Wrap(err, "failed to supersede currency rate")
+	}
+
 	var id string
-	nstmt, err := s.db.PrepareNamedContext(ctx, createCurrencyRateSql)
+	nstmt, err := tx.PrepareNamedContext(ctx, createCurrencyRateSql)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to prepared name context")
 	}
@@ -56,25 +123,82 @@ func (s *Storage) CreateCurrencyRate(ctx context.Context, rate Rate) (string, er
 	if err := nstmt.QueryRowContext(ctx, rate).Scan(&id); err != nil {
 		return "", errors.Wrap(err, "failed to create currency rate")
 	}
-	return id, nil
+	return id, errors.Wrap(tx.Commit(), "failed to commit currency rate")
 }
 
-func (s *Storage) GetCurrencyRates(ctx context.Context, filter CurrencyFilter) ([]Rate, error) {
-	var rates []Rate
+func (s *Storage) CreateCurrencyRates(ctx context.Context, rates []Rate) (err error) {
+	ctx, end := s.startQuery(ctx, "CreateCurrencyRates")
+	defer func() { end(err) }()
+
+	if len(rates) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin currency rates transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, lockCurrencyRatesSql); err != nil {
+		return errors.Wrap(err, "failed to lock currency rates")
+	}
+	if _, err := tx.ExecContext(ctx, createCurrencyRateImportSql); err != nil {
+		return errors.Wrap(err, "failed to create currency rates import table")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("currency_rate_import", "base", "quote", "rate", "published_date"))
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare currency rates copy")
+	}
+	defer stmt.Close()
 
-	query := ""
+	for _, rate := range rates {
+		if _, err := stmt.ExecContext(ctx, rate.Base, rate.Quote, rate.Rate, rate.Date.Format("2006-01-02")); err != nil {
+			return errors.Wrap(err, "failed to copy currency rate")
+		}
+	}
+	// flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to copy currency rates")
+	}
 
-	if filter.Date.IsZero() && filter.GetLatestDate {
-		query = fmt.Sprintf(`%s WHERE published_date = (%s)`, getCurrencyRateSql, getLatestCurrencyRateDateSql)
+	if _, err := tx.ExecContext(ctx, supersedeImportedCurrencyRatesSql); err != nil {
+		return errors.Wrap(err, "failed to supersede currency rates")
+	}
+	if _, err := tx.ExecContext(ctx, insertImportedCurrencyRatesSql); err != nil {
+		return errors.Wrap(err, "failed to create currency rates")
 	}
 
+	return errors.Wrap(tx.Commit(), "failed to commit currency rates")
+}
+
+func (s *Storage) GetCurrencyRates(ctx context.Context, filter CurrencyFilter) (_ []Rate, err error) {
+	ctx, end := s.startQuery(ctx, "GetCurrencyRates")
+	defer func() { end(err) }()
+
+	var rates []Rate
+
 	params := map[string]interface{}{}
+	versions := asOfConditions(filter.AsOf, params)
+	conditions := append([]string(nil), versions...)
 
 	if !filter.Date.IsZero() {
-		query = fmt.Sprintf(`%s WHERE published_date = :published_date`, getCurrencyRateSql)
+		conditions = append(conditions, "published_date = :published_date")
 		params["published_date"] = filter.Date
+	} else if filter.GetLatestDate {
+		conditions = append(conditions, fmt.Sprintf("published_date = (%s %s)", getLatestCurrencyRateDateSql, where(versions)))
+	} else {
+		conditions = append(conditions, dateRangeConditions(filter.StartDate, filter.EndDate, params)...)
+	}
+
+	if len(filter.Quotes) > 0 {
+		conditions = append(conditions, "quote = ANY(:quotes)")
+		params["quotes"] = pq.Array(filter.Quotes)
 	}
 
+	query := fmt.Sprintf("%s %s ORDER BY published_date, quote", getCurrencyRateSql, where(conditions))
+
 	nstmt, err := s.db.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to prepare statement for retrieving currency rates")
@@ -86,16 +210,60 @@ func (s *Storage) GetCurrencyRates(ctx context.Context, filter CurrencyFilter) (
 	return rates, nil
 }
 
-func (s *Storage) GetAnalyzedCurrencyRates(ctx context.Context) ([]AnalyzedRate, error) {
+func (s *Storage) GetAnalyzedCurrencyRates(ctx context.Context, filter AnalysisFilter) (_ []AnalyzedRate, err error) {
+	ctx, end := s.startQuery(ctx, "GetAnalyzedCurrencyRates")
+	defer func() { end(err) }()
+
 	var rates []AnalyzedRate
 
-	nstmt, err := s.db.PrepareNamedContext(ctx, getAnalyzedCurrencyRateSql)
+	params := map[string]interface{}{}
+	conditions := asOfConditions(filter.AsOf, params)
+	conditions = append(conditions, dateRangeConditions(filter.StartDate, filter.EndDate, params)...)
+
+	if len(filter.Quotes) > 0 {
+		conditions = append(conditions, "quote = ANY(:quotes)")
+		params["quotes"] = pq.Array(filter.Quotes)
+	}
+
+	query := fmt.Sprintf("%s %s %s", getAnalyzedCurrencyRateSql, where(conditions), groupAnalyzedCurrencyRateSql)
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to prepare statement for retrieving analyzed currency rates")
 	}
 	defer nstmt.Close()
-	if err = nstmt.SelectContext(ctx, &rates, map[string]interface{}{}); err != nil {
+	if err = nstmt.SelectContext(ctx, &rates, params); err != nil {
 		return nil, errors.Wrap(err, "failed to retrieve analyzed currency rates")
 	}
 	return rates, nil
 }
+
+func dateRangeConditions(start, end time.Time, params map[string]interface{}) []string {
+	var conditions []string
+	if !start.IsZero() {
+		conditions = append(conditions, "published_date >= :start_date")
+		params["start_date"] = start
+	}
+	if !end.IsZero() {
+		conditions = append(conditions, "published_date <= :end_date")
+		params["end_date"] = end
+	}
+	return conditions
+}
+
+// asOfConditions select the current version of each rate, or the version
+// that was current at asOf when it is set.
+func asOfConditions(asOf time.Time, params map[string]interface{}) []string {
+	if asOf.IsZero() {
+		return []string{"superseded_at IS NULL"}
+	}
+	params["as_of"] = asOf
+	return []string{"recorded_at <= :as_of", "(superseded_at IS NULL OR superseded_at > :as_of)"}
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RateScale is the number of decimals kept for rates, as in the
+	// NUMERIC(20,10) columns of the Postgres schema.
+	RateScale = 10
+	// AvgScale is the number of decimals of averaged rates.
+	AvgScale = 16
+)
+
+// ParseDecimal parses a decimal number as Postgres parses a NUMERIC(20,10)
+// value. Backends without a decimal type use it to match Postgres.
+func ParseDecimal(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return nil, errors.Errorf("invalid decimal %q", s)
+	}
+
+	limit := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(20-RateScale), nil))
+	if new(big.Rat).Abs(r).Cmp(limit) >= 0 {
+		return nil, errors.Errorf("decimal %q overflows NUMERIC(20,%d)", s, RateScale)
+	}
+	return r, nil
+}
+
+// NormalizeDecimal returns s rounded to RateScale decimals without
+// trailing zeros, which is how Postgres returns a stored rate.
+func NormalizeDecimal(s string) (string, error) {
+	r, err := ParseDecimal(s)
+	if err != nil {
+		return "", err
+	}
+	return FormatDecimal(r, RateScale), nil
+}
+
+// FormatDecimal formats r with up to scale decimals, without trailing zeros
+// or a trailing point, like the TRIM expressions of the Postgres queries.
+func FormatDecimal(r *big.Rat, scale int) string {
+	s := r.FloatString(scale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// AnalyzeRates returns the minimum, maximum and average rate per base and
+// quote, ordered by base and quote, as GetAnalyzedCurrencyRates does.
+func AnalyzeRates(rates []Rate) ([]AnalyzedRate, error) {
+	type pair struct{ base, quote string }
+	type stats struct {
+		min, max, sum *big.Rat
+		count         int64
+	}
+
+	groups := map[pair]*stats{}
+	var pairs []pair
+	for _, rate := range rates {
+		value, err := ParseDecimal(rate.Rate)
+		if err != nil {
+			return nil, err
+		}
+
+		p := pair{rate.Base, rate.Quote}
+		g, ok := groups[p]
+		if !ok {
+			g = &stats{min: value, max: value, sum: new(big.Rat)}
+			groups[p] = g
+			pairs = append(pairs, p)
+		}
+		if value.Cmp(g.min) < 0 {
+			g.min = value
+		}
+		if value.Cmp(g.max) > 0 {
+			g.max = value
+		}
+		g.sum.Add(g.sum, value)
+		g.count++
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].base != pairs[j].base {
+			return pairs[i].base < pairs[j].base
+		}
+		return pairs[i].quote < pairs[j].quote
+	})
+
+	analyzed := make([]AnalyzedRate, 0, len(pairs))
+	for _, p := range pairs {
+		g := groups[p]
+		avg := new(big.Rat).Quo(g.sum, new(big.Rat).SetInt64(g.count))
+		analyzed = append(analyzed, AnalyzedRate{
+			Base:  p.base,
+			Quote: p.quote,
+			Min:   FormatDecimal(g.min, RateScale),
+			Max:   FormatDecimal(g.max, RateScale),
+			Avg:   FormatDecimal(avg, AvgScale),
+		})
+	}
+	return analyzed, nil
+}
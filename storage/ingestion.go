@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	createIngestionRunSql = `
+		INSERT INTO ingestion_run (
+			source,
+			url,
+			started_at,
+			finished_at,
+			http_status,
+			feed_hash,
+			first_date,
+			last_date,
+			rows_inserted,
+			rows_updated,
+			rows_rejected,
+			error
+		) VALUES (
+			:source,
+			:url,
+			:started_at,
+			:finished_at,
+			:http_status,
+			:feed_hash,
+			:first_date,
+			:last_date,
+			:rows_inserted,
+			:rows_updated,
+			:rows_rejected,
+			:error
+		) RETURNING id;
+	`
+
+	getIngestionRunSql = `
+		SELECT
+			id,
+			source,
+			url,
+			started_at,
+			finished_at,
+			http_status,
+			feed_hash,
+			first_date,
+			last_date,
+			rows_inserted,
+			rows_updated,
+			rows_rejected,
+			error
+		FROM ingestion_run
+	`
+)
+
+func (s *Storage) CreateIngestionRun(ctx context.Context, run IngestionRun) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateIngestionRun")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createIngestionRunSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, run).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create ingestion run")
+	}
+	return id, nil
+}
+
+func (s *Storage) GetIngestionRuns(ctx context.Context, filter IngestionFilter) (_ []IngestionRun, err error) {
+	ctx, end := s.startQuery(ctx, "GetIngestionRuns")
+	defer func() { end(err) }()
+
+	var runs []IngestionRun
+
+	var conditions []string
+	params := map[string]interface{}{}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "started_at >= :from")
+		params["from"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "started_at < :to")
+		params["to"] = filter.To
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY started_at DESC", getIngestionRunSql, where(conditions))
+	if filter.Limit > 0 {
+		query += " LIMIT :limit"
+		params["limit"] = filter.Limit
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving ingestion runs")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &runs, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve ingestion runs")
+	}
+	return runs, nil
+}
+
+func (s *Storage) GetIngestionRun(ctx context.Context, id string) (_ IngestionRun, err error) {
+	ctx, end := s.startQuery(ctx, "GetIngestionRun")
+	defer func() { end(err) }()
+
+	var run IngestionRun
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getIngestionRunSql+" WHERE id = :id")
+	if err != nil {
+		return run, errors.Wrap(err, "failed to prepare statement for retrieving ingestion run")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &run, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return run, ErrNotFound
+		}
+		return run, errors.Wrap(err, "failed to retrieve ingestion run")
+	}
+	return run, nil
+}
@@ -0,0 +1,224 @@
+// Package memory provides a storage.RakutenStore keeping rates in memory,
+// for tests, demos and programs embedding the service without a database.
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+var (
+	_ storage.RakutenStore = (*Store)(nil)
+)
+
+// Store is safe for concurrent use. Rates are normalized on write as
+// Postgres stores them, and queries return copies in the order the
+// Postgres store does.
+type Store struct {
+	mu     sync.RWMutex
+	rates  []version
+	nextID int
+}
+
+// version is a rate as stored between its RecordedAt and superseded,
+// which is zero for the current version.
+type version struct {
+	storage.Rate
+	superseded time.Time
+}
+
+func New() *Store {
+	return &Store{}
+}
+
+// CreateCurrencyRatesTable does nothing, the store needs no schema.
+func (s *Store) CreateCurrencyRatesTable() error {
+	return nil
+}
+
+func (s *Store) CreateCurrencyRate(ctx context.Context, rate storage.Rate) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	rate, err := normalize(rate)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create currency rate")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.write(time.Now(), rate)
+	return strconv.Itoa(s.nextID), nil
+}
+
+func (s *Store) CreateCurrencyRates(ctx context.Context, rates []storage.Rate) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// validate every rate first, so that none is stored if one is invalid
+	normalized := make([]storage.Rate, 0, len(rates))
+	for _, rate := range rates {
+		rate, err := normalize(rate)
+		if err != nil {
+			return errors.Wrap(err, "failed to create currency rates")
+		}
+		normalized = append(normalized, rate)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID += len(normalized)
+	s.write(time.Now(), normalized...)
+	return nil
+}
+
+// write supersedes the current versions of rates and appends them as
+// recorded at now. Callers must hold s.mu for writing.
+func (s *Store) write(now time.Time, rates ...storage.Rate) {
+	type key struct {
+		base, quote string
+		date        time.Time
+	}
+	written := map[key]bool{}
+	for _, rate := range rates {
+		written[key{rate.Base, rate.Quote, rate.Date}] = true
+	}
+
+	for i := range s.rates {
+		v := &s.rates[i]
+		if v.superseded.IsZero() && written[key{v.Base, v.Quote, v.Date}] {
+			v.superseded = now
+		}
+	}
+	for _, rate := range rates {
+		rate.RecordedAt = now
+		s.rates = append(s.rates, version{Rate: rate})
+	}
+}
+
+func (s *Store) GetCurrencyRates(ctx context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var match func(time.Time) bool
+	switch {
+	case !filter.Date.IsZero():
+		date := day(filter.Date)
+		match = date.Equal
+	case filter.GetLatestDate:
+		latest := s.latestDate(filter.AsOf)
+		match = func(date time.Time) bool { return !latest.IsZero() && date.Equal(latest) }
+	default:
+		match = inRange(filter.StartDate, filter.EndDate)
+	}
+
+	rates := s.filter(match, filter.Quotes, filter.AsOf)
+	sort.SliceStable(rates, func(i, j int) bool {
+		if !rates[i].Date.Equal(rates[j].Date) {
+			return rates[i].Date.Before(rates[j].Date)
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+	return rates, nil
+}
+
+func (s *Store) GetAnalyzedCurrencyRates(ctx context.Context, filter storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	rates := s.filter(inRange(filter.StartDate, filter.EndDate), filter.Quotes, filter.AsOf)
+	s.mu.RUnlock()
+
+	analyzed, err := storage.AnalyzeRates(rates)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to analyze currency rates")
+	}
+	return analyzed, nil
+}
+
+// filter returns copies of the versions current at asOf, or now when it
+// is zero, of the rates published on a date matching match, limited to
+// quotes unless it is empty. Callers must hold s.mu.
+func (s *Store) filter(match func(time.Time) bool, quotes []string, asOf time.Time) []storage.Rate {
+	wanted := map[string]bool{}
+	for _, quote := range quotes {
+		wanted[quote] = true
+	}
+
+	var rates []storage.Rate
+	for _, v := range s.rates {
+		if !v.currentAt(asOf) || !match(v.Date) || (len(wanted) > 0 && !wanted[v.Quote]) {
+			continue
+		}
+		rates = append(rates, v.Rate)
+	}
+	return rates
+}
+
+// latestDate returns the latest published date as of asOf, or the zero
+// time when there are no rates. Callers must hold s.mu.
+func (s *Store) latestDate(asOf time.Time) time.Time {
+	var latest time.Time
+	for _, v := range s.rates {
+		if v.currentAt(asOf) && v.Date.After(latest) {
+			latest = v.Date
+		}
+	}
+	return latest
+}
+
+// currentAt reports whether v was the current version at asOf, or is the
+// current version when asOf is zero.
+func (v version) currentAt(asOf time.Time) bool {
+	if asOf.IsZero() {
+		return v.superseded.IsZero()
+	}
+	return !v.RecordedAt.After(asOf) && (v.superseded.IsZero() || v.superseded.After(asOf))
+}
+
+// normalize returns rate as Postgres would store it.
+func normalize(rate storage.Rate) (storage.Rate, error) {
+	value, err := storage.NormalizeDecimal(rate.Rate)
+	if err != nil {
+		return rate, err
+	}
+	rate.Rate = value
+	rate.Date = day(rate.Date)
+	return rate, nil
+}
+
+// inRange matches the dates between start and end inclusive, either bound
+// may be left zero.
+func inRange(start, end time.Time) func(time.Time) bool {
+	start, end = day(start), day(end)
+	return func(date time.Time) bool {
+		return (start.IsZero() || !date.Before(start)) && (end.IsZero() || !date.After(end))
+	}
+}
+
+// day truncates t to its date in UTC, as a DATE column stores it.
+func day(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/storagetest"
+)
+
+func jan(d int) time.Time {
+	return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestStore_GetCurrencyRates(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	rates, err := s.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true})
+	if err != nil || len(rates) != 0 {
+		t.Fatalf("expected no rates in an empty store, got %+v: %v", rates, err)
+	}
+
+	for _, rate := range []storage.Rate{
+		{Base: "EUR", Quote: "USD", Rate: "1.0599", Date: jan(5)},
+		{Base: "EUR", Quote: "SGD", Rate: "200.50", Date: jan(5)},
+		{Base: "EUR", Quote: "SGD", Rate: "100", Date: time.Date(2023, 1, 2, 15, 0, 0, 0, time.FixedZone("CET", 3600))},
+	} {
+		if _, err := s.CreateCurrencyRate(ctx, rate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rates, err = s.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0].Quote != "SGD" || rates[0].Rate != "200.5" || !rates[0].Date.Equal(jan(5)) {
+		t.Fatalf("unexpected latest rates %+v", rates)
+	}
+
+	rates, err = s.GetCurrencyRates(ctx, storage.CurrencyFilter{Date: jan(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 || rates[0].Rate != "100" || !rates[0].Date.Equal(jan(2)) {
+		t.Fatalf("unexpected rates for date %+v", rates)
+	}
+
+	rates, err = s.GetCurrencyRates(ctx, storage.CurrencyFilter{EndDate: jan(5), Quotes: []string{"SGD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || !rates[0].Date.Equal(jan(2)) {
+		t.Fatalf("unexpected rates for range %+v", rates)
+	}
+
+	rates[0].Rate = "0"
+	if rates, _ := s.GetCurrencyRates(ctx, storage.CurrencyFilter{Date: jan(2)}); rates[0].Rate != "100" {
+		t.Fatal("returned rates must be copies")
+	}
+}
+
+func TestStore_GetAnalyzedCurrencyRates(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	for i, rate := range []string{"100", "200.5", "150"} {
+		if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "SGD", Rate: rate, Date: jan(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "AUD", Rate: "1.5", Date: jan(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzed, err := s.GetAnalyzedCurrencyRates(ctx, storage.AnalysisFilter{StartDate: jan(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.AnalyzedRate{{Base: "EUR", Quote: "SGD", Min: "150", Max: "200.5", Avg: "175.25"}}
+	if len(analyzed) != 1 || analyzed[0] != want[0] {
+		t.Fatalf("got %+v, want %+v", analyzed, want)
+	}
+
+	analyzed, err = s.GetAnalyzedCurrencyRates(ctx, storage.AnalysisFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analyzed) != 2 || analyzed[0].Quote != "AUD" || analyzed[1].Avg != "150.1666666666666667" {
+		t.Fatalf("unexpected analysis %+v", analyzed)
+	}
+}
+
+func TestStore_CreateCurrencyRate_Invalid(t *testing.T) {
+	s := New()
+	if _, err := s.CreateCurrencyRate(context.Background(), storage.Rate{Base: "EUR", Quote: "USD", Rate: "1e3", Date: jan(1)}); err == nil {
+		t.Fatal("expected an error for a non decimal rate")
+	}
+}
+
+func TestStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "USD", Rate: "1.1", Date: jan(i + 1)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := s.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rates, err := s.GetCurrencyRates(ctx, storage.CurrencyFilter{})
+	if err != nil || len(rates) != 8 {
+		t.Fatalf("expected 8 rates, got %d: %v", len(rates), err)
+	}
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.RakutenStore {
+		return New()
+	})
+}
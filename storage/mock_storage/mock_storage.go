@@ -7,6 +7,7 @@ package mock_storage
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	storage "github.com/syahnur197/rakuten/storage"
@@ -50,6 +51,20 @@ func (mr *MockRakutenStoreMockRecorder) CreateCurrencyRate(ctx, rate interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCurrencyRate", reflect.TypeOf((*MockRakutenStore)(nil).CreateCurrencyRate), ctx, rate)
 }
 
+// CreateCurrencyRates mocks base method.
+func (m *MockRakutenStore) CreateCurrencyRates(ctx context.Context, rates []storage.Rate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCurrencyRates", ctx, rates)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCurrencyRates indicates an expected call of CreateCurrencyRates.
+func (mr *MockRakutenStoreMockRecorder) CreateCurrencyRates(ctx, rates interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCurrencyRates", reflect.TypeOf((*MockRakutenStore)(nil).CreateCurrencyRates), ctx, rates)
+}
+
 // CreateCurrencyRatesTable mocks base method.
 func (m *MockRakutenStore) CreateCurrencyRatesTable() error {
 	m.ctrl.T.Helper()
@@ -65,18 +80,18 @@ func (mr *MockRakutenStoreMockRecorder) CreateCurrencyRatesTable() *gomock.Call
 }
 
 // GetAnalyzedCurrencyRates mocks base method.
-func (m *MockRakutenStore) GetAnalyzedCurrencyRates(ctx context.Context) ([]storage.AnalyzedRate, error) {
+func (m *MockRakutenStore) GetAnalyzedCurrencyRates(ctx context.Context, filter storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAnalyzedCurrencyRates", ctx)
+	ret := m.ctrl.Call(m, "GetAnalyzedCurrencyRates", ctx, filter)
 	ret0, _ := ret[0].([]storage.AnalyzedRate)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAnalyzedCurrencyRates indicates an expected call of GetAnalyzedCurrencyRates.
-func (mr *MockRakutenStoreMockRecorder) GetAnalyzedCurrencyRates(ctx interface{}) *gomock.Call {
+func (mr *MockRakutenStoreMockRecorder) GetAnalyzedCurrencyRates(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnalyzedCurrencyRates", reflect.TypeOf((*MockRakutenStore)(nil).GetAnalyzedCurrencyRates), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnalyzedCurrencyRates", reflect.TypeOf((*MockRakutenStore)(nil).GetAnalyzedCurrencyRates), ctx, filter)
 }
 
 // GetCurrencyRates mocks base method.
@@ -93,3 +108,1431 @@ func (mr *MockRakutenStoreMockRecorder) GetCurrencyRates(ctx, filter interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrencyRates", reflect.TypeOf((*MockRakutenStore)(nil).GetCurrencyRates), ctx, filter)
 }
+
+// MockAlertStore is a mock of AlertStore interface.
+type MockAlertStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockAlertStoreMockRecorder
+}
+
+// MockAlertStoreMockRecorder is the mock recorder for MockAlertStore.
+type MockAlertStoreMockRecorder struct {
+	mock *MockAlertStore
+}
+
+// NewMockAlertStore creates a new mock instance.
+func NewMockAlertStore(ctrl *gomock.Controller) *MockAlertStore {
+	mock := &MockAlertStore{ctrl: ctrl}
+	mock.recorder = &MockAlertStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAlertStore) EXPECT() *MockAlertStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateAlertDelivery mocks base method.
+func (m *MockAlertStore) CreateAlertDelivery(ctx context.Context, delivery storage.AlertDelivery) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlertDelivery", ctx, delivery)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlertDelivery indicates an expected call of CreateAlertDelivery.
+func (mr *MockAlertStoreMockRecorder) CreateAlertDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlertDelivery", reflect.TypeOf((*MockAlertStore)(nil).CreateAlertDelivery), ctx, delivery)
+}
+
+// CreateAlertRule mocks base method.
+func (m *MockAlertStore) CreateAlertRule(ctx context.Context, rule storage.AlertRule) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlertRule", ctx, rule)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlertRule indicates an expected call of CreateAlertRule.
+func (mr *MockAlertStoreMockRecorder) CreateAlertRule(ctx, rule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlertRule", reflect.TypeOf((*MockAlertStore)(nil).CreateAlertRule), ctx, rule)
+}
+
+// CreateAlertTables mocks base method.
+func (m *MockAlertStore) CreateAlertTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlertTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAlertTables indicates an expected call of CreateAlertTables.
+func (mr *MockAlertStoreMockRecorder) CreateAlertTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlertTables", reflect.TypeOf((*MockAlertStore)(nil).CreateAlertTables))
+}
+
+// DeleteAlertRule mocks base method.
+func (m *MockAlertStore) DeleteAlertRule(ctx context.Context, id, keyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAlertRule", ctx, id, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAlertRule indicates an expected call of DeleteAlertRule.
+func (mr *MockAlertStoreMockRecorder) DeleteAlertRule(ctx, id, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAlertRule", reflect.TypeOf((*MockAlertStore)(nil).DeleteAlertRule), ctx, id, keyID)
+}
+
+// GetAlertDeliveries mocks base method.
+func (m *MockAlertStore) GetAlertDeliveries(ctx context.Context, ruleID string) ([]storage.AlertDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertDeliveries", ctx, ruleID)
+	ret0, _ := ret[0].([]storage.AlertDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertDeliveries indicates an expected call of GetAlertDeliveries.
+func (mr *MockAlertStoreMockRecorder) GetAlertDeliveries(ctx, ruleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertDeliveries", reflect.TypeOf((*MockAlertStore)(nil).GetAlertDeliveries), ctx, ruleID)
+}
+
+// GetAlertRule mocks base method.
+func (m *MockAlertStore) GetAlertRule(ctx context.Context, id string) (storage.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRule", ctx, id)
+	ret0, _ := ret[0].(storage.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRule indicates an expected call of GetAlertRule.
+func (mr *MockAlertStoreMockRecorder) GetAlertRule(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRule", reflect.TypeOf((*MockAlertStore)(nil).GetAlertRule), ctx, id)
+}
+
+// GetAlertRules mocks base method.
+func (m *MockAlertStore) GetAlertRules(ctx context.Context, keyID string) ([]storage.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRules", ctx, keyID)
+	ret0, _ := ret[0].([]storage.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRules indicates an expected call of GetAlertRules.
+func (mr *MockAlertStoreMockRecorder) GetAlertRules(ctx, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRules", reflect.TypeOf((*MockAlertStore)(nil).GetAlertRules), ctx, keyID)
+}
+
+// GetDueAlertDeliveries mocks base method.
+func (m *MockAlertStore) GetDueAlertDeliveries(ctx context.Context, limit int) ([]storage.AlertDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueAlertDeliveries", ctx, limit)
+	ret0, _ := ret[0].([]storage.AlertDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueAlertDeliveries indicates an expected call of GetDueAlertDeliveries.
+func (mr *MockAlertStoreMockRecorder) GetDueAlertDeliveries(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueAlertDeliveries", reflect.TypeOf((*MockAlertStore)(nil).GetDueAlertDeliveries), ctx, limit)
+}
+
+// UpdateAlertDelivery mocks base method.
+func (m *MockAlertStore) UpdateAlertDelivery(ctx context.Context, delivery storage.AlertDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAlertDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAlertDelivery indicates an expected call of UpdateAlertDelivery.
+func (mr *MockAlertStoreMockRecorder) UpdateAlertDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAlertDelivery", reflect.TypeOf((*MockAlertStore)(nil).UpdateAlertDelivery), ctx, delivery)
+}
+
+// MockAPIKeyStore is a mock of APIKeyStore interface.
+type MockAPIKeyStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyStoreMockRecorder
+}
+
+// MockAPIKeyStoreMockRecorder is the mock recorder for MockAPIKeyStore.
+type MockAPIKeyStoreMockRecorder struct {
+	mock *MockAPIKeyStore
+}
+
+// NewMockAPIKeyStore creates a new mock instance.
+func NewMockAPIKeyStore(ctrl *gomock.Controller) *MockAPIKeyStore {
+	mock := &MockAPIKeyStore{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyStore) EXPECT() *MockAPIKeyStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockAPIKeyStore) CreateAPIKey(ctx context.Context, key storage.APIKey) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockAPIKeyStoreMockRecorder) CreateAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockAPIKeyStore)(nil).CreateAPIKey), ctx, key)
+}
+
+// CreateAPIKeyTables mocks base method.
+func (m *MockAPIKeyStore) CreateAPIKeyTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKeyTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKeyTables indicates an expected call of CreateAPIKeyTables.
+func (mr *MockAPIKeyStoreMockRecorder) CreateAPIKeyTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKeyTables", reflect.TypeOf((*MockAPIKeyStore)(nil).CreateAPIKeyTables))
+}
+
+// GetAPIKeyByHash mocks base method.
+func (m *MockAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", ctx, hash)
+	ret0, _ := ret[0].(storage.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockAPIKeyStoreMockRecorder) GetAPIKeyByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockAPIKeyStore)(nil).GetAPIKeyByHash), ctx, hash)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockAPIKeyStore) GetAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx)
+	ret0, _ := ret[0].([]storage.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockAPIKeyStoreMockRecorder) GetAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockAPIKeyStore)(nil).GetAPIKeys), ctx)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKeyStore) RevokeAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyStoreMockRecorder) RevokeAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKeyStore)(nil).RevokeAPIKey), ctx, id)
+}
+
+// UpdateAPIKeyScopes mocks base method.
+func (m *MockAPIKeyStore) UpdateAPIKeyScopes(ctx context.Context, id string, scopes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAPIKeyScopes", ctx, id, scopes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAPIKeyScopes indicates an expected call of UpdateAPIKeyScopes.
+func (mr *MockAPIKeyStoreMockRecorder) UpdateAPIKeyScopes(ctx, id, scopes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAPIKeyScopes", reflect.TypeOf((*MockAPIKeyStore)(nil).UpdateAPIKeyScopes), ctx, id, scopes)
+}
+
+// MockIngestionStore is a mock of IngestionStore interface.
+type MockIngestionStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockIngestionStoreMockRecorder
+}
+
+// MockIngestionStoreMockRecorder is the mock recorder for MockIngestionStore.
+type MockIngestionStoreMockRecorder struct {
+	mock *MockIngestionStore
+}
+
+// NewMockIngestionStore creates a new mock instance.
+func NewMockIngestionStore(ctrl *gomock.Controller) *MockIngestionStore {
+	mock := &MockIngestionStore{ctrl: ctrl}
+	mock.recorder = &MockIngestionStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIngestionStore) EXPECT() *MockIngestionStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateIngestionRun mocks base method.
+func (m *MockIngestionStore) CreateIngestionRun(ctx context.Context, run storage.IngestionRun) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIngestionRun", ctx, run)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIngestionRun indicates an expected call of CreateIngestionRun.
+func (mr *MockIngestionStoreMockRecorder) CreateIngestionRun(ctx, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIngestionRun", reflect.TypeOf((*MockIngestionStore)(nil).CreateIngestionRun), ctx, run)
+}
+
+// CreateIngestionTables mocks base method.
+func (m *MockIngestionStore) CreateIngestionTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIngestionTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIngestionTables indicates an expected call of CreateIngestionTables.
+func (mr *MockIngestionStoreMockRecorder) CreateIngestionTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIngestionTables", reflect.TypeOf((*MockIngestionStore)(nil).CreateIngestionTables))
+}
+
+// GetIngestionRun mocks base method.
+func (m *MockIngestionStore) GetIngestionRun(ctx context.Context, id string) (storage.IngestionRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestionRun", ctx, id)
+	ret0, _ := ret[0].(storage.IngestionRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestionRun indicates an expected call of GetIngestionRun.
+func (mr *MockIngestionStoreMockRecorder) GetIngestionRun(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestionRun", reflect.TypeOf((*MockIngestionStore)(nil).GetIngestionRun), ctx, id)
+}
+
+// GetIngestionRuns mocks base method.
+func (m *MockIngestionStore) GetIngestionRuns(ctx context.Context, filter storage.IngestionFilter) ([]storage.IngestionRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestionRuns", ctx, filter)
+	ret0, _ := ret[0].([]storage.IngestionRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestionRuns indicates an expected call of GetIngestionRuns.
+func (mr *MockIngestionStoreMockRecorder) GetIngestionRuns(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestionRuns", reflect.TypeOf((*MockIngestionStore)(nil).GetIngestionRuns), ctx, filter)
+}
+
+// MockOverrideStore is a mock of OverrideStore interface.
+type MockOverrideStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockOverrideStoreMockRecorder
+}
+
+// MockOverrideStoreMockRecorder is the mock recorder for MockOverrideStore.
+type MockOverrideStoreMockRecorder struct {
+	mock *MockOverrideStore
+}
+
+// NewMockOverrideStore creates a new mock instance.
+func NewMockOverrideStore(ctrl *gomock.Controller) *MockOverrideStore {
+	mock := &MockOverrideStore{ctrl: ctrl}
+	mock.recorder = &MockOverrideStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOverrideStore) EXPECT() *MockOverrideStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateOverrideTables mocks base method.
+func (m *MockOverrideStore) CreateOverrideTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOverrideTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOverrideTables indicates an expected call of CreateOverrideTables.
+func (mr *MockOverrideStoreMockRecorder) CreateOverrideTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOverrideTables", reflect.TypeOf((*MockOverrideStore)(nil).CreateOverrideTables))
+}
+
+// CreateRateOverride mocks base method.
+func (m *MockOverrideStore) CreateRateOverride(ctx context.Context, override storage.RateOverride) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRateOverride", ctx, override)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRateOverride indicates an expected call of CreateRateOverride.
+func (mr *MockOverrideStoreMockRecorder) CreateRateOverride(ctx, override interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRateOverride", reflect.TypeOf((*MockOverrideStore)(nil).CreateRateOverride), ctx, override)
+}
+
+// DeleteRateOverride mocks base method.
+func (m *MockOverrideStore) DeleteRateOverride(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRateOverride", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRateOverride indicates an expected call of DeleteRateOverride.
+func (mr *MockOverrideStoreMockRecorder) DeleteRateOverride(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRateOverride", reflect.TypeOf((*MockOverrideStore)(nil).DeleteRateOverride), ctx, id)
+}
+
+// GetRateOverride mocks base method.
+func (m *MockOverrideStore) GetRateOverride(ctx context.Context, id string) (storage.RateOverride, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateOverride", ctx, id)
+	ret0, _ := ret[0].(storage.RateOverride)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRateOverride indicates an expected call of GetRateOverride.
+func (mr *MockOverrideStoreMockRecorder) GetRateOverride(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateOverride", reflect.TypeOf((*MockOverrideStore)(nil).GetRateOverride), ctx, id)
+}
+
+// GetRateOverrides mocks base method.
+func (m *MockOverrideStore) GetRateOverrides(ctx context.Context, filter storage.OverrideFilter) ([]storage.RateOverride, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateOverrides", ctx, filter)
+	ret0, _ := ret[0].([]storage.RateOverride)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRateOverrides indicates an expected call of GetRateOverrides.
+func (mr *MockOverrideStoreMockRecorder) GetRateOverrides(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateOverrides", reflect.TypeOf((*MockOverrideStore)(nil).GetRateOverrides), ctx, filter)
+}
+
+// GetRateOverridesChangedAt mocks base method.
+func (m *MockOverrideStore) GetRateOverridesChangedAt(ctx context.Context, filter storage.OverrideFilter) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateOverridesChangedAt", ctx, filter)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRateOverridesChangedAt indicates an expected call of GetRateOverridesChangedAt.
+func (mr *MockOverrideStoreMockRecorder) GetRateOverridesChangedAt(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateOverridesChangedAt", reflect.TypeOf((*MockOverrideStore)(nil).GetRateOverridesChangedAt), ctx, filter)
+}
+
+// UpdateRateOverride mocks base method.
+func (m *MockOverrideStore) UpdateRateOverride(ctx context.Context, override storage.RateOverride) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRateOverride", ctx, override)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRateOverride indicates an expected call of UpdateRateOverride.
+func (mr *MockOverrideStoreMockRecorder) UpdateRateOverride(ctx, override interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRateOverride", reflect.TypeOf((*MockOverrideStore)(nil).UpdateRateOverride), ctx, override)
+}
+
+// MockApprovalStore is a mock of ApprovalStore interface.
+type MockApprovalStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockApprovalStoreMockRecorder
+}
+
+// MockApprovalStoreMockRecorder is the mock recorder for MockApprovalStore.
+type MockApprovalStoreMockRecorder struct {
+	mock *MockApprovalStore
+}
+
+// NewMockApprovalStore creates a new mock instance.
+func NewMockApprovalStore(ctrl *gomock.Controller) *MockApprovalStore {
+	mock := &MockApprovalStore{ctrl: ctrl}
+	mock.recorder = &MockApprovalStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockApprovalStore) EXPECT() *MockApprovalStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateApproval mocks base method.
+func (m *MockApprovalStore) CreateApproval(ctx context.Context, approval storage.Approval) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApproval", ctx, approval)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateApproval indicates an expected call of CreateApproval.
+func (mr *MockApprovalStoreMockRecorder) CreateApproval(ctx, approval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApproval", reflect.TypeOf((*MockApprovalStore)(nil).CreateApproval), ctx, approval)
+}
+
+// CreateApprovalTables mocks base method.
+func (m *MockApprovalStore) CreateApprovalTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApprovalTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateApprovalTables indicates an expected call of CreateApprovalTables.
+func (mr *MockApprovalStoreMockRecorder) CreateApprovalTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApprovalTables", reflect.TypeOf((*MockApprovalStore)(nil).CreateApprovalTables))
+}
+
+// DecideApproval mocks base method.
+func (m *MockApprovalStore) DecideApproval(ctx context.Context, approval storage.Approval) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecideApproval", ctx, approval)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecideApproval indicates an expected call of DecideApproval.
+func (mr *MockApprovalStoreMockRecorder) DecideApproval(ctx, approval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideApproval", reflect.TypeOf((*MockApprovalStore)(nil).DecideApproval), ctx, approval)
+}
+
+// GetApproval mocks base method.
+func (m *MockApprovalStore) GetApproval(ctx context.Context, id string) (storage.Approval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApproval", ctx, id)
+	ret0, _ := ret[0].(storage.Approval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApproval indicates an expected call of GetApproval.
+func (mr *MockApprovalStoreMockRecorder) GetApproval(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApproval", reflect.TypeOf((*MockApprovalStore)(nil).GetApproval), ctx, id)
+}
+
+// GetApprovals mocks base method.
+func (m *MockApprovalStore) GetApprovals(ctx context.Context, filter storage.ApprovalFilter) ([]storage.Approval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApprovals", ctx, filter)
+	ret0, _ := ret[0].([]storage.Approval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApprovals indicates an expected call of GetApprovals.
+func (mr *MockApprovalStoreMockRecorder) GetApprovals(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApprovals", reflect.TypeOf((*MockApprovalStore)(nil).GetApprovals), ctx, filter)
+}
+
+// MockQuarantineStore is a mock of QuarantineStore interface.
+type MockQuarantineStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuarantineStoreMockRecorder
+}
+
+// MockQuarantineStoreMockRecorder is the mock recorder for MockQuarantineStore.
+type MockQuarantineStoreMockRecorder struct {
+	mock *MockQuarantineStore
+}
+
+// NewMockQuarantineStore creates a new mock instance.
+func NewMockQuarantineStore(ctrl *gomock.Controller) *MockQuarantineStore {
+	mock := &MockQuarantineStore{ctrl: ctrl}
+	mock.recorder = &MockQuarantineStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuarantineStore) EXPECT() *MockQuarantineStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateQuarantineTables mocks base method.
+func (m *MockQuarantineStore) CreateQuarantineTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateQuarantineTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateQuarantineTables indicates an expected call of CreateQuarantineTables.
+func (mr *MockQuarantineStoreMockRecorder) CreateQuarantineTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateQuarantineTables", reflect.TypeOf((*MockQuarantineStore)(nil).CreateQuarantineTables))
+}
+
+// GetQuarantinedRates mocks base method.
+func (m *MockQuarantineStore) GetQuarantinedRates(ctx context.Context, filter storage.QuarantineFilter) ([]storage.QuarantinedRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuarantinedRates", ctx, filter)
+	ret0, _ := ret[0].([]storage.QuarantinedRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuarantinedRates indicates an expected call of GetQuarantinedRates.
+func (mr *MockQuarantineStoreMockRecorder) GetQuarantinedRates(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuarantinedRates", reflect.TypeOf((*MockQuarantineStore)(nil).GetQuarantinedRates), ctx, filter)
+}
+
+// QuarantineRates mocks base method.
+func (m *MockQuarantineStore) QuarantineRates(ctx context.Context, rates []storage.QuarantinedRate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QuarantineRates", ctx, rates)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QuarantineRates indicates an expected call of QuarantineRates.
+func (mr *MockQuarantineStoreMockRecorder) QuarantineRates(ctx, rates interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QuarantineRates", reflect.TypeOf((*MockQuarantineStore)(nil).QuarantineRates), ctx, rates)
+}
+
+// MockHealthStore is a mock of HealthStore interface.
+type MockHealthStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthStoreMockRecorder
+}
+
+// MockHealthStoreMockRecorder is the mock recorder for MockHealthStore.
+type MockHealthStoreMockRecorder struct {
+	mock *MockHealthStore
+}
+
+// NewMockHealthStore creates a new mock instance.
+func NewMockHealthStore(ctrl *gomock.Controller) *MockHealthStore {
+	mock := &MockHealthStore{ctrl: ctrl}
+	mock.recorder = &MockHealthStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealthStore) EXPECT() *MockHealthStoreMockRecorder {
+	return m.recorder
+}
+
+// CheckSchema mocks base method.
+func (m *MockHealthStore) CheckSchema(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckSchema", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckSchema indicates an expected call of CheckSchema.
+func (mr *MockHealthStoreMockRecorder) CheckSchema(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSchema", reflect.TypeOf((*MockHealthStore)(nil).CheckSchema), ctx)
+}
+
+// Ping mocks base method.
+func (m *MockHealthStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockHealthStoreMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockHealthStore)(nil).Ping), ctx)
+}
+
+// MockUsageStore is a mock of UsageStore interface.
+type MockUsageStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockUsageStoreMockRecorder
+}
+
+// MockUsageStoreMockRecorder is the mock recorder for MockUsageStore.
+type MockUsageStoreMockRecorder struct {
+	mock *MockUsageStore
+}
+
+// NewMockUsageStore creates a new mock instance.
+func NewMockUsageStore(ctrl *gomock.Controller) *MockUsageStore {
+	mock := &MockUsageStore{ctrl: ctrl}
+	mock.recorder = &MockUsageStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUsageStore) EXPECT() *MockUsageStoreMockRecorder {
+	return m.recorder
+}
+
+// CreateUsageTables mocks base method.
+func (m *MockUsageStore) CreateUsageTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUsageTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUsageTables indicates an expected call of CreateUsageTables.
+func (mr *MockUsageStoreMockRecorder) CreateUsageTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUsageTables", reflect.TypeOf((*MockUsageStore)(nil).CreateUsageTables))
+}
+
+// GetUsage mocks base method.
+func (m *MockUsageStore) GetUsage(ctx context.Context, filter storage.UsageFilter) ([]storage.UsageCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", ctx, filter)
+	ret0, _ := ret[0].([]storage.UsageCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage.
+func (mr *MockUsageStoreMockRecorder) GetUsage(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockUsageStore)(nil).GetUsage), ctx, filter)
+}
+
+// IncrementUsage mocks base method.
+func (m *MockUsageStore) IncrementUsage(ctx context.Context, counts []storage.UsageCount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementUsage", ctx, counts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementUsage indicates an expected call of IncrementUsage.
+func (mr *MockUsageStoreMockRecorder) IncrementUsage(ctx, counts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementUsage", reflect.TypeOf((*MockUsageStore)(nil).IncrementUsage), ctx, counts)
+}
+
+// MockBackend is a mock of Backend interface.
+type MockBackend struct {
+	ctrl     *gomock.Controller
+	recorder *MockBackendMockRecorder
+}
+
+// MockBackendMockRecorder is the mock recorder for MockBackend.
+type MockBackendMockRecorder struct {
+	mock *MockBackend
+}
+
+// NewMockBackend creates a new mock instance.
+func NewMockBackend(ctrl *gomock.Controller) *MockBackend {
+	mock := &MockBackend{ctrl: ctrl}
+	mock.recorder = &MockBackendMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBackend) EXPECT() *MockBackendMockRecorder {
+	return m.recorder
+}
+
+// CheckSchema mocks base method.
+func (m *MockBackend) CheckSchema(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckSchema", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckSchema indicates an expected call of CheckSchema.
+func (mr *MockBackendMockRecorder) CheckSchema(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSchema", reflect.TypeOf((*MockBackend)(nil).CheckSchema), ctx)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockBackend) CreateAPIKey(ctx context.Context, key storage.APIKey) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockBackendMockRecorder) CreateAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockBackend)(nil).CreateAPIKey), ctx, key)
+}
+
+// CreateAPIKeyTables mocks base method.
+func (m *MockBackend) CreateAPIKeyTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKeyTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKeyTables indicates an expected call of CreateAPIKeyTables.
+func (mr *MockBackendMockRecorder) CreateAPIKeyTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKeyTables", reflect.TypeOf((*MockBackend)(nil).CreateAPIKeyTables))
+}
+
+// CreateAlertDelivery mocks base method.
+func (m *MockBackend) CreateAlertDelivery(ctx context.Context, delivery storage.AlertDelivery) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlertDelivery", ctx, delivery)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlertDelivery indicates an expected call of CreateAlertDelivery.
+func (mr *MockBackendMockRecorder) CreateAlertDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlertDelivery", reflect.TypeOf((*MockBackend)(nil).CreateAlertDelivery), ctx, delivery)
+}
+
+// CreateAlertRule mocks base method.
+func (m *MockBackend) CreateAlertRule(ctx context.Context, rule storage.AlertRule) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlertRule", ctx, rule)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlertRule indicates an expected call of CreateAlertRule.
+func (mr *MockBackendMockRecorder) CreateAlertRule(ctx, rule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlertRule", reflect.TypeOf((*MockBackend)(nil).CreateAlertRule), ctx, rule)
+}
+
+// CreateAlertTables mocks base method.
+func (m *MockBackend) CreateAlertTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlertTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAlertTables indicates an expected call of CreateAlertTables.
+func (mr *MockBackendMockRecorder) CreateAlertTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlertTables", reflect.TypeOf((*MockBackend)(nil).CreateAlertTables))
+}
+
+// CreateApproval mocks base method.
+func (m *MockBackend) CreateApproval(ctx context.Context, approval storage.Approval) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApproval", ctx, approval)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateApproval indicates an expected call of CreateApproval.
+func (mr *MockBackendMockRecorder) CreateApproval(ctx, approval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApproval", reflect.TypeOf((*MockBackend)(nil).CreateApproval), ctx, approval)
+}
+
+// CreateApprovalTables mocks base method.
+func (m *MockBackend) CreateApprovalTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApprovalTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateApprovalTables indicates an expected call of CreateApprovalTables.
+func (mr *MockBackendMockRecorder) CreateApprovalTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApprovalTables", reflect.TypeOf((*MockBackend)(nil).CreateApprovalTables))
+}
+
+// CreateCurrencyRate mocks base method.
+func (m *MockBackend) CreateCurrencyRate(ctx context.Context, rate storage.Rate) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCurrencyRate", ctx, rate)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCurrencyRate indicates an expected call of CreateCurrencyRate.
+func (mr *MockBackendMockRecorder) CreateCurrencyRate(ctx, rate interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCurrencyRate", reflect.TypeOf((*MockBackend)(nil).CreateCurrencyRate), ctx, rate)
+}
+
+// CreateCurrencyRates mocks base method.
+func (m *MockBackend) CreateCurrencyRates(ctx context.Context, rates []storage.Rate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCurrencyRates", ctx, rates)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCurrencyRates indicates an expected call of CreateCurrencyRates.
+func (mr *MockBackendMockRecorder) CreateCurrencyRates(ctx, rates interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCurrencyRates", reflect.TypeOf((*MockBackend)(nil).CreateCurrencyRates), ctx, rates)
+}
+
+// CreateCurrencyRatesTable mocks base method.
+func (m *MockBackend) CreateCurrencyRatesTable() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCurrencyRatesTable")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCurrencyRatesTable indicates an expected call of CreateCurrencyRatesTable.
+func (mr *MockBackendMockRecorder) CreateCurrencyRatesTable() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCurrencyRatesTable", reflect.TypeOf((*MockBackend)(nil).CreateCurrencyRatesTable))
+}
+
+// CreateIngestionRun mocks base method.
+func (m *MockBackend) CreateIngestionRun(ctx context.Context, run storage.IngestionRun) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIngestionRun", ctx, run)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIngestionRun indicates an expected call of CreateIngestionRun.
+func (mr *MockBackendMockRecorder) CreateIngestionRun(ctx, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIngestionRun", reflect.TypeOf((*MockBackend)(nil).CreateIngestionRun), ctx, run)
+}
+
+// CreateIngestionTables mocks base method.
+func (m *MockBackend) CreateIngestionTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIngestionTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIngestionTables indicates an expected call of CreateIngestionTables.
+func (mr *MockBackendMockRecorder) CreateIngestionTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIngestionTables", reflect.TypeOf((*MockBackend)(nil).CreateIngestionTables))
+}
+
+// CreateOverrideTables mocks base method.
+func (m *MockBackend) CreateOverrideTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOverrideTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOverrideTables indicates an expected call of CreateOverrideTables.
+func (mr *MockBackendMockRecorder) CreateOverrideTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOverrideTables", reflect.TypeOf((*MockBackend)(nil).CreateOverrideTables))
+}
+
+// CreateQuarantineTables mocks base method.
+func (m *MockBackend) CreateQuarantineTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateQuarantineTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateQuarantineTables indicates an expected call of CreateQuarantineTables.
+func (mr *MockBackendMockRecorder) CreateQuarantineTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateQuarantineTables", reflect.TypeOf((*MockBackend)(nil).CreateQuarantineTables))
+}
+
+// CreateRateOverride mocks base method.
+func (m *MockBackend) CreateRateOverride(ctx context.Context, override storage.RateOverride) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRateOverride", ctx, override)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRateOverride indicates an expected call of CreateRateOverride.
+func (mr *MockBackendMockRecorder) CreateRateOverride(ctx, override interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRateOverride", reflect.TypeOf((*MockBackend)(nil).CreateRateOverride), ctx, override)
+}
+
+// CreateUsageTables mocks base method.
+func (m *MockBackend) CreateUsageTables() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUsageTables")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUsageTables indicates an expected call of CreateUsageTables.
+func (mr *MockBackendMockRecorder) CreateUsageTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUsageTables", reflect.TypeOf((*MockBackend)(nil).CreateUsageTables))
+}
+
+// DecideApproval mocks base method.
+func (m *MockBackend) DecideApproval(ctx context.Context, approval storage.Approval) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecideApproval", ctx, approval)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecideApproval indicates an expected call of DecideApproval.
+func (mr *MockBackendMockRecorder) DecideApproval(ctx, approval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideApproval", reflect.TypeOf((*MockBackend)(nil).DecideApproval), ctx, approval)
+}
+
+// DeleteAlertRule mocks base method.
+func (m *MockBackend) DeleteAlertRule(ctx context.Context, id, keyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAlertRule", ctx, id, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAlertRule indicates an expected call of DeleteAlertRule.
+func (mr *MockBackendMockRecorder) DeleteAlertRule(ctx, id, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAlertRule", reflect.TypeOf((*MockBackend)(nil).DeleteAlertRule), ctx, id, keyID)
+}
+
+// DeleteRateOverride mocks base method.
+func (m *MockBackend) DeleteRateOverride(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRateOverride", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRateOverride indicates an expected call of DeleteRateOverride.
+func (mr *MockBackendMockRecorder) DeleteRateOverride(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRateOverride", reflect.TypeOf((*MockBackend)(nil).DeleteRateOverride), ctx, id)
+}
+
+// GetAPIKeyByHash mocks base method.
+func (m *MockBackend) GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", ctx, hash)
+	ret0, _ := ret[0].(storage.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockBackendMockRecorder) GetAPIKeyByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockBackend)(nil).GetAPIKeyByHash), ctx, hash)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockBackend) GetAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx)
+	ret0, _ := ret[0].([]storage.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockBackendMockRecorder) GetAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockBackend)(nil).GetAPIKeys), ctx)
+}
+
+// GetAlertDeliveries mocks base method.
+func (m *MockBackend) GetAlertDeliveries(ctx context.Context, ruleID string) ([]storage.AlertDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertDeliveries", ctx, ruleID)
+	ret0, _ := ret[0].([]storage.AlertDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertDeliveries indicates an expected call of GetAlertDeliveries.
+func (mr *MockBackendMockRecorder) GetAlertDeliveries(ctx, ruleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertDeliveries", reflect.TypeOf((*MockBackend)(nil).GetAlertDeliveries), ctx, ruleID)
+}
+
+// GetAlertRule mocks base method.
+func (m *MockBackend) GetAlertRule(ctx context.Context, id string) (storage.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRule", ctx, id)
+	ret0, _ := ret[0].(storage.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRule indicates an expected call of GetAlertRule.
+func (mr *MockBackendMockRecorder) GetAlertRule(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRule", reflect.TypeOf((*MockBackend)(nil).GetAlertRule), ctx, id)
+}
+
+// GetAlertRules mocks base method.
+func (m *MockBackend) GetAlertRules(ctx context.Context, keyID string) ([]storage.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRules", ctx, keyID)
+	ret0, _ := ret[0].([]storage.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRules indicates an expected call of GetAlertRules.
+func (mr *MockBackendMockRecorder) GetAlertRules(ctx, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRules", reflect.TypeOf((*MockBackend)(nil).GetAlertRules), ctx, keyID)
+}
+
+// GetAnalyzedCurrencyRates mocks base method.
+func (m *MockBackend) GetAnalyzedCurrencyRates(ctx context.Context, filter storage.AnalysisFilter) ([]storage.AnalyzedRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAnalyzedCurrencyRates", ctx, filter)
+	ret0, _ := ret[0].([]storage.AnalyzedRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAnalyzedCurrencyRates indicates an expected call of GetAnalyzedCurrencyRates.
+func (mr *MockBackendMockRecorder) GetAnalyzedCurrencyRates(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnalyzedCurrencyRates", reflect.TypeOf((*MockBackend)(nil).GetAnalyzedCurrencyRates), ctx, filter)
+}
+
+// GetApproval mocks base method.
+func (m *MockBackend) GetApproval(ctx context.Context, id string) (storage.Approval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApproval", ctx, id)
+	ret0, _ := ret[0].(storage.Approval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApproval indicates an expected call of GetApproval.
+func (mr *MockBackendMockRecorder) GetApproval(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApproval", reflect.TypeOf((*MockBackend)(nil).GetApproval), ctx, id)
+}
+
+// GetApprovals mocks base method.
+func (m *MockBackend) GetApprovals(ctx context.Context, filter storage.ApprovalFilter) ([]storage.Approval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApprovals", ctx, filter)
+	ret0, _ := ret[0].([]storage.Approval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApprovals indicates an expected call of GetApprovals.
+func (mr *MockBackendMockRecorder) GetApprovals(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApprovals", reflect.TypeOf((*MockBackend)(nil).GetApprovals), ctx, filter)
+}
+
+// GetCurrencyRates mocks base method.
+func (m *MockBackend) GetCurrencyRates(ctx context.Context, filter storage.CurrencyFilter) ([]storage.Rate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCurrencyRates", ctx, filter)
+	ret0, _ := ret[0].([]storage.Rate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCurrencyRates indicates an expected call of GetCurrencyRates.
+func (mr *MockBackendMockRecorder) GetCurrencyRates(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrencyRates", reflect.TypeOf((*MockBackend)(nil).GetCurrencyRates), ctx, filter)
+}
+
+// GetDueAlertDeliveries mocks base method.
+func (m *MockBackend) GetDueAlertDeliveries(ctx context.Context, limit int) ([]storage.AlertDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueAlertDeliveries", ctx, limit)
+	ret0, _ := ret[0].([]storage.AlertDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueAlertDeliveries indicates an expected call of GetDueAlertDeliveries.
+func (mr *MockBackendMockRecorder) GetDueAlertDeliveries(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueAlertDeliveries", reflect.TypeOf((*MockBackend)(nil).GetDueAlertDeliveries), ctx, limit)
+}
+
+// GetIngestionRun mocks base method.
+func (m *MockBackend) GetIngestionRun(ctx context.Context, id string) (storage.IngestionRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestionRun", ctx, id)
+	ret0, _ := ret[0].(storage.IngestionRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestionRun indicates an expected call of GetIngestionRun.
+func (mr *MockBackendMockRecorder) GetIngestionRun(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestionRun", reflect.TypeOf((*MockBackend)(nil).GetIngestionRun), ctx, id)
+}
+
+// GetIngestionRuns mocks base method.
+func (m *MockBackend) GetIngestionRuns(ctx context.Context, filter storage.IngestionFilter) ([]storage.IngestionRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestionRuns", ctx, filter)
+	ret0, _ := ret[0].([]storage.IngestionRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestionRuns indicates an expected call of GetIngestionRuns.
+func (mr *MockBackendMockRecorder) GetIngestionRuns(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestionRuns", reflect.TypeOf((*MockBackend)(nil).GetIngestionRuns), ctx, filter)
+}
+
+// GetQuarantinedRates mocks base method.
+func (m *MockBackend) GetQuarantinedRates(ctx context.Context, filter storage.QuarantineFilter) ([]storage.QuarantinedRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuarantinedRates", ctx, filter)
+	ret0, _ := ret[0].([]storage.QuarantinedRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuarantinedRates indicates an expected call of GetQuarantinedRates.
+func (mr *MockBackendMockRecorder) GetQuarantinedRates(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuarantinedRates", reflect.TypeOf((*MockBackend)(nil).GetQuarantinedRates), ctx, filter)
+}
+
+// GetRateOverride mocks base method.
+func (m *MockBackend) GetRateOverride(ctx context.Context, id string) (storage.RateOverride, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateOverride", ctx, id)
+	ret0, _ := ret[0].(storage.RateOverride)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRateOverride indicates an expected call of GetRateOverride.
+func (mr *MockBackendMockRecorder) GetRateOverride(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateOverride", reflect.TypeOf((*MockBackend)(nil).GetRateOverride), ctx, id)
+}
+
+// GetRateOverrides mocks base method.
+func (m *MockBackend) GetRateOverrides(ctx context.Context, filter storage.OverrideFilter) ([]storage.RateOverride, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateOverrides", ctx, filter)
+	ret0, _ := ret[0].([]storage.RateOverride)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRateOverrides indicates an expected call of GetRateOverrides.
+func (mr *MockBackendMockRecorder) GetRateOverrides(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateOverrides", reflect.TypeOf((*MockBackend)(nil).GetRateOverrides), ctx, filter)
+}
+
+// GetRateOverridesChangedAt mocks base method.
+func (m *MockBackend) GetRateOverridesChangedAt(ctx context.Context, filter storage.OverrideFilter) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRateOverridesChangedAt", ctx, filter)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRateOverridesChangedAt indicates an expected call of GetRateOverridesChangedAt.
+func (mr *MockBackendMockRecorder) GetRateOverridesChangedAt(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRateOverridesChangedAt", reflect.TypeOf((*MockBackend)(nil).GetRateOverridesChangedAt), ctx, filter)
+}
+
+// GetUsage mocks base method.
+func (m *MockBackend) GetUsage(ctx context.Context, filter storage.UsageFilter) ([]storage.UsageCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", ctx, filter)
+	ret0, _ := ret[0].([]storage.UsageCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage.
+func (mr *MockBackendMockRecorder) GetUsage(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockBackend)(nil).GetUsage), ctx, filter)
+}
+
+// IncrementUsage mocks base method.
+func (m *MockBackend) IncrementUsage(ctx context.Context, counts []storage.UsageCount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementUsage", ctx, counts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementUsage indicates an expected call of IncrementUsage.
+func (mr *MockBackendMockRecorder) IncrementUsage(ctx, counts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementUsage", reflect.TypeOf((*MockBackend)(nil).IncrementUsage), ctx, counts)
+}
+
+// Migrate mocks base method.
+func (m *MockBackend) Migrate() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Migrate")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Migrate indicates an expected call of Migrate.
+func (mr *MockBackendMockRecorder) Migrate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Migrate", reflect.TypeOf((*MockBackend)(nil).Migrate))
+}
+
+// Ping mocks base method.
+func (m *MockBackend) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockBackendMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockBackend)(nil).Ping), ctx)
+}
+
+// QuarantineRates mocks base method.
+func (m *MockBackend) QuarantineRates(ctx context.Context, rates []storage.QuarantinedRate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QuarantineRates", ctx, rates)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QuarantineRates indicates an expected call of QuarantineRates.
+func (mr *MockBackendMockRecorder) QuarantineRates(ctx, rates interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QuarantineRates", reflect.TypeOf((*MockBackend)(nil).QuarantineRates), ctx, rates)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockBackend) RevokeAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockBackendMockRecorder) RevokeAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockBackend)(nil).RevokeAPIKey), ctx, id)
+}
+
+// UpdateAPIKeyScopes mocks base method.
+func (m *MockBackend) UpdateAPIKeyScopes(ctx context.Context, id string, scopes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAPIKeyScopes", ctx, id, scopes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAPIKeyScopes indicates an expected call of UpdateAPIKeyScopes.
+func (mr *MockBackendMockRecorder) UpdateAPIKeyScopes(ctx, id, scopes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAPIKeyScopes", reflect.TypeOf((*MockBackend)(nil).UpdateAPIKeyScopes), ctx, id, scopes)
+}
+
+// UpdateAlertDelivery mocks base method.
+func (m *MockBackend) UpdateAlertDelivery(ctx context.Context, delivery storage.AlertDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAlertDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAlertDelivery indicates an expected call of UpdateAlertDelivery.
+func (mr *MockBackendMockRecorder) UpdateAlertDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAlertDelivery", reflect.TypeOf((*MockBackend)(nil).UpdateAlertDelivery), ctx, delivery)
+}
+
+// UpdateRateOverride mocks base method.
+func (m *MockBackend) UpdateRateOverride(ctx context.Context, override storage.RateOverride) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRateOverride", ctx, override)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRateOverride indicates an expected call of UpdateRateOverride.
+func (mr *MockBackendMockRecorder) UpdateRateOverride(ctx, override interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRateOverride", reflect.TypeOf((*MockBackend)(nil).UpdateRateOverride), ctx, override)
+}
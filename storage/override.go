@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	supersedeRateOverrideByKeySql = `
+		UPDATE rate_override SET superseded_at = NOW()
+		WHERE base = :base
+			AND quote = :quote
+			AND published_date = :published_date
+			AND superseded_at IS NULL
+	`
+
+	supersedeRateOverrideSql = `
+		UPDATE rate_override SET superseded_at = NOW()
+		WHERE id = :id AND superseded_at IS NULL
+		RETURNING base, quote, published_date
+	`
+
+	deleteRateOverrideSql = `
+		UPDATE rate_override SET superseded_at = NOW()
+		WHERE id = :id AND superseded_at IS NULL
+	`
+
+	createRateOverrideSql = `
+		INSERT INTO rate_override (
+			id,
+			base,
+			quote,
+			rate,
+			published_date,
+			reason,
+			author
+		) VALUES (
+			COALESCE(CAST(NULLIF(:id, '') AS UUID), uuid_generate_v1()),
+			:base,
+			:quote,
+			:rate,
+			:published_date,
+			:reason,
+			:author
+		) RETURNING id;
+	`
+
+	getRateOverrideSql = `
+		SELECT
+			id,
+			base,
+			quote,
+			TRIM(TRAILING '.' FROM (TRIM(TRAILING '0' FROM CAST(rate AS TEXT)))) as rate,
+			published_date,
+			reason,
+			author,
+			recorded_at
+		FROM rate_override
+	`
+
+	getRateOverrideVersionSql = `
+		SELECT recorded_at, superseded_at FROM rate_override
+	`
+)
+
+// overrideVersion is when a version of an override was written and, unless
+// it is current, superseded.
+type overrideVersion struct {
+	RecordedAt   time.Time  `db:"recorded_at"`
+	SupersededAt *time.Time `db:"superseded_at"`
+}
+
+func (s *Storage) CreateRateOverride(ctx context.Context, override RateOverride) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateRateOverride")
+	defer func() { end(err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to begin rate override transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, supersedeRateOverrideByKeySql, override); err != nil {
+		return "", errors.Wrap(err, "failed to supersede rate override")
+	}
+
+	override.ID = ""
+	id, err := insertRateOverride(ctx, tx, override)
+	if err != nil {
+		return "", err
+	}
+	return id, errors.Wrap(tx.Commit(), "failed to commit rate override")
+}
+
+func (s *Storage) GetRateOverrides(ctx context.Context, filter OverrideFilter) (_ []RateOverride, err error) {
+	ctx, end := s.startQuery(ctx, "GetRateOverrides")
+	defer func() { end(err) }()
+
+	var overrides []RateOverride
+
+	params := map[string]interface{}{}
+	conditions := asOfConditions(filter.AsOf, params)
+	conditions = append(conditions, dateRangeConditions(filter.StartDate, filter.EndDate, params)...)
+
+	if len(filter.Quotes) > 0 {
+		conditions = append(conditions, "quote = ANY(:quotes)")
+		params["quotes"] = pq.Array(filter.Quotes)
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY published_date, quote", getRateOverrideSql, where(conditions))
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving rate overrides")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &overrides, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve rate overrides")
+	}
+	return overrides, nil
+}
+
+func (s *Storage) GetRateOverridesChangedAt(ctx context.Context, filter OverrideFilter) (_ time.Time, err error) {
+	ctx, end := s.startQuery(ctx, "GetRateOverridesChangedAt")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{}
+	conditions := dateRangeConditions(filter.StartDate, filter.EndDate, params)
+	if !filter.AsOf.IsZero() {
+		conditions = append(conditions, "recorded_at <= :as_of")
+		params["as_of"] = filter.AsOf
+	}
+	if len(filter.Quotes) > 0 {
+		conditions = append(conditions, "quote = ANY(:quotes)")
+		params["quotes"] = pq.Array(filter.Quotes)
+	}
+
+	var versions []overrideVersion
+	nstmt, err := s.db.PrepareNamedContext(ctx, fmt.Sprintf("%s %s", getRateOverrideVersionSql, where(conditions)))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to prepare statement for retrieving rate override versions")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &versions, params); err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to retrieve rate override versions")
+	}
+
+	var changed time.Time
+	for _, v := range versions {
+		if v.RecordedAt.After(changed) {
+			changed = v.RecordedAt
+		}
+		if v.SupersededAt != nil && v.SupersededAt.After(changed) && (filter.AsOf.IsZero() || !v.SupersededAt.After(filter.AsOf)) {
+			changed = *v.SupersededAt
+		}
+	}
+	return changed, nil
+}
+
+func (s *Storage) GetRateOverride(ctx context.Context, id string) (_ RateOverride, err error) {
+	ctx, end := s.startQuery(ctx, "GetRateOverride")
+	defer func() { end(err) }()
+
+	var override RateOverride
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getRateOverrideSql+" WHERE id = :id AND superseded_at IS NULL")
+	if err != nil {
+		return override, errors.Wrap(err, "failed to prepare statement for retrieving rate override")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &override, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return override, ErrNotFound
+		}
+		return override, errors.Wrap(err, "failed to retrieve rate override")
+	}
+	return override, nil
+}
+
+func (s *Storage) UpdateRateOverride(ctx context.Context, override RateOverride) (err error) {
+	ctx, end := s.startQuery(ctx, "UpdateRateOverride")
+	defer func() { end(err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin rate override transaction")
+	}
+	defer tx.Rollback()
+
+	// the new version keeps the base, quote and date of the override
+	nstmt, err := tx.PrepareNamedContext(ctx, supersedeRateOverrideSql)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.GetContext(ctx, &override, override); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "failed to supersede rate override")
+	}
+
+	if _, err := insertRateOverride(ctx, tx, override); err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit rate override")
+}
+
+func (s *Storage) DeleteRateOverride(ctx context.Context, id string) (err error) {
+	ctx, end := s.startQuery(ctx, "DeleteRateOverride")
+	defer func() { end(err) }()
+
+	return s.execAffectingOne(ctx, deleteRateOverrideSql, map[string]interface{}{"id": id}, "failed to delete rate override")
+}
+
+// insertRateOverride inserts a version of override, under a new id unless
+// override has one.
+func insertRateOverride(ctx context.Context, tx *sqlx.Tx, override RateOverride) (string, error) {
+	var id string
+	nstmt, err := tx.PrepareNamedContext(ctx, createRateOverrideSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, override).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create rate override")
+	}
+	return id, nil
+}
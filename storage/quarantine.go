@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	quarantineRateSql = `
+		INSERT INTO quarantined_rate (
+			base,
+			quote,
+			rate,
+			published_date,
+			rule,
+			detail
+		) VALUES (
+			:base,
+			:quote,
+			:rate,
+			:published_date,
+			:rule,
+			:detail
+		) ON CONFLICT (base, quote, published_date, rule, rate) DO NOTHING
+	`
+
+	getQuarantinedRateSql = `
+		SELECT
+			id,
+			base,
+			quote,
+			rate,
+			published_date,
+			rule,
+			detail,
+			detected_at
+		FROM quarantined_rate
+	`
+)
+
+func (s *Storage) QuarantineRates(ctx context.Context, rates []QuarantinedRate) (err error) {
+	ctx, end := s.startQuery(ctx, "QuarantineRates")
+	defer func() { end(err) }()
+
+	if len(rates) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin quarantine transaction")
+	}
+	defer tx.Rollback()
+
+	nstmt, err := tx.PrepareNamedContext(ctx, quarantineRateSql)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	for _, rate := range rates {
+		if _, err := nstmt.ExecContext(ctx, rate); err != nil {
+			return errors.Wrap(err, "failed to quarantine rate")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit quarantined rates")
+}
+
+func (s *Storage) GetQuarantinedRates(ctx context.Context, filter QuarantineFilter) (_ []QuarantinedRate, err error) {
+	ctx, end := s.startQuery(ctx, "GetQuarantinedRates")
+	defer func() { end(err) }()
+
+	var rates []QuarantinedRate
+
+	params := map[string]interface{}{}
+	conditions := dateRangeConditions(filter.StartDate, filter.EndDate, params)
+
+	if filter.Rule != "" {
+		conditions = append(conditions, "rule = :rule")
+		params["rule"] = filter.Rule
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY detected_at DESC, published_date, quote", getQuarantinedRateSql, where(conditions))
+	if filter.Limit > 0 {
+		query += " LIMIT :limit"
+		params["limit"] = filter.Limit
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving quarantined rates")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &rates, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve quarantined rates")
+	}
+	return rates, nil
+}
@@ -1,17 +1,241 @@
 package storage
 
+import (
+	"context"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// schemaTables are the tables created by the Create*Tables methods.
+var schemaTables = []string{"currency_rate", "alert_rule", "alert_delivery", "api_key", "usage_hourly", "ingestion_run", "rate_override", "approval", "quarantined_rate"}
+
 func (s *Storage) CreateCurrencyRatesTable() error {
 	sql := `
 	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
-	DROP TABLE IF EXISTS currency_rate;
 	CREATE TABLE IF NOT EXISTS currency_rate (
     	"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
     	"base" VARCHAR(3) NOT NULL,	
     	"quote" VARCHAR(3) NOT NULL,
     	"rate" NUMERIC(20,10) NOT NULL,
     	"published_date" DATE NOT NULL 
+	);
+	ALTER TABLE currency_rate ADD COLUMN IF NOT EXISTS "recorded_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	ALTER TABLE currency_rate ADD COLUMN IF NOT EXISTS "superseded_at" TIMESTAMPTZ;
+	CREATE INDEX IF NOT EXISTS currency_rate_current ON currency_rate (base, quote, published_date) WHERE superseded_at IS NULL;`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Storage) CreateAlertTables() error {
+	sql := `
+	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+	CREATE TABLE IF NOT EXISTS alert_rule (
+		"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"kind" VARCHAR(16) NOT NULL,
+		"base" VARCHAR(3) NOT NULL,
+		"quote" VARCHAR(3) NOT NULL DEFAULT '',
+		"direction" VARCHAR(8) NOT NULL DEFAULT '',
+		"threshold" NUMERIC(20,10) NOT NULL,
+		"webhook_url" TEXT NOT NULL,
+		"secret" TEXT NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE IF NOT EXISTS alert_delivery (
+		"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"rule_id" UUID NOT NULL REFERENCES alert_rule (id) ON DELETE CASCADE,
+		"quote" VARCHAR(3) NOT NULL,
+		"published_date" DATE NOT NULL,
+		"payload" TEXT NOT NULL,
+		"status" VARCHAR(16) NOT NULL,
+		"attempts" INTEGER NOT NULL DEFAULT 0,
+		"response_status" INTEGER NOT NULL DEFAULT 0,
+		"error" TEXT NOT NULL DEFAULT '',
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (rule_id, quote, published_date)
+	);
+	ALTER TABLE alert_rule ADD COLUMN IF NOT EXISTS "key_id" TEXT NOT NULL DEFAULT '';
+	ALTER TABLE alert_delivery ADD COLUMN IF NOT EXISTS "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	CREATE INDEX IF NOT EXISTS alert_delivery_due ON alert_delivery (next_attempt_at) WHERE status = 'pending';`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Storage) CreateAPIKeyTables() error {
+	sql := `
+	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+	CREATE TABLE IF NOT EXISTS api_key (
+		"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"name" TEXT NOT NULL,
+		"prefix" VARCHAR(16) NOT NULL,
+		"key_hash" VARCHAR(64) NOT NULL UNIQUE,
+		"scopes" TEXT[] NOT NULL DEFAULT '{}',
+		"rate_limit" INTEGER NOT NULL,
+		"burst" INTEGER NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"revoked_at" TIMESTAMPTZ
+	);
+	ALTER TABLE api_key ADD COLUMN IF NOT EXISTS "monthly_quota" INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE api_key ADD COLUMN IF NOT EXISTS "quota_mode" VARCHAR(8) NOT NULL DEFAULT 'block';`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Storage) CreateUsageTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS usage_hourly (
+		"key_id" TEXT NOT NULL,
+		"endpoint" TEXT NOT NULL,
+		"hour" TIMESTAMPTZ NOT NULL,
+		"requests" BIGINT NOT NULL,
+		PRIMARY KEY (key_id, endpoint, hour)
 	);`
 
 	_, err := s.db.Exec(sql)
 	return err
 }
+
+func (s *Storage) CreateIngestionTables() error {
+	sql := `
+	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+	CREATE TABLE IF NOT EXISTS ingestion_run (
+		"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"source" VARCHAR(16) NOT NULL,
+		"url" TEXT NOT NULL,
+		"started_at" TIMESTAMPTZ NOT NULL,
+		"finished_at" TIMESTAMPTZ NOT NULL,
+		"http_status" INTEGER NOT NULL DEFAULT 0,
+		"feed_hash" VARCHAR(64) NOT NULL DEFAULT '',
+		"first_date" DATE,
+		"last_date" DATE,
+		"rows_inserted" INTEGER NOT NULL DEFAULT 0,
+		"rows_updated" INTEGER NOT NULL DEFAULT 0,
+		"rows_rejected" INTEGER NOT NULL DEFAULT 0,
+		"error" TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS ingestion_run_started_at ON ingestion_run (started_at);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Storage) CreateOverrideTables() error {
+	sql := `
+	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+	CREATE TABLE IF NOT EXISTS rate_override (
+		"revision_id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"id" UUID NOT NULL,
+		"base" VARCHAR(3) NOT NULL,
+		"quote" VARCHAR(3) NOT NULL,
+		"rate" NUMERIC(20,10) NOT NULL,
+		"published_date" DATE NOT NULL,
+		"reason" TEXT NOT NULL,
+		"author" TEXT NOT NULL,
+		"recorded_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"superseded_at" TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS rate_override_id ON rate_override (id);
+	CREATE INDEX IF NOT EXISTS rate_override_published_date ON rate_override (published_date);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Storage) CreateApprovalTables() error {
+	sql := `
+	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+	CREATE TABLE IF NOT EXISTS approval (
+		"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"kind" VARCHAR(16) NOT NULL,
+		"published_date" DATE NOT NULL,
+		"override_id" TEXT NOT NULL DEFAULT '',
+		"requested_by" TEXT NOT NULL,
+		"requested_by_key" TEXT NOT NULL DEFAULT '',
+		"requested_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"status" VARCHAR(16) NOT NULL DEFAULT 'pending',
+		"decided_by" TEXT NOT NULL DEFAULT '',
+		"decided_by_key" TEXT NOT NULL DEFAULT '',
+		"decided_at" TIMESTAMPTZ,
+		"comment" TEXT NOT NULL DEFAULT ''
+	);
+	ALTER TABLE approval ADD COLUMN IF NOT EXISTS "requested_by_key" TEXT NOT NULL DEFAULT '';
+	ALTER TABLE approval ADD COLUMN IF NOT EXISTS "decided_by_key" TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS approval_published_date ON approval (published_date);
+	CREATE INDEX IF NOT EXISTS approval_status ON approval (status);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Storage) CreateQuarantineTables() error {
+	sql := `
+	CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+	CREATE TABLE IF NOT EXISTS quarantined_rate (
+		"id" UUID DEFAULT uuid_generate_v1() PRIMARY KEY,
+		"base" VARCHAR(3) NOT NULL,
+		"quote" TEXT NOT NULL,
+		"rate" TEXT NOT NULL,
+		"published_date" DATE NOT NULL,
+		"rule" VARCHAR(32) NOT NULL,
+		"detail" TEXT NOT NULL DEFAULT '',
+		"detected_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS quarantined_rate_key ON quarantined_rate (base, quote, published_date, rule, rate);
+	CREATE INDEX IF NOT EXISTS quarantined_rate_detected_at ON quarantined_rate (detected_at);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+// Migrate creates every table that does not exist yet and adds missing
+// columns to existing ones. It is safe to run on every start.
+func (s *Storage) Migrate() error {
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"currency rates", s.CreateCurrencyRatesTable},
+		{"alerts", s.CreateAlertTables},
+		{"api keys", s.CreateAPIKeyTables},
+		{"usage", s.CreateUsageTables},
+		{"ingestion", s.CreateIngestionTables},
+		{"overrides", s.CreateOverrideTables},
+		{"approvals", s.CreateApprovalTables},
+		{"quarantine", s.CreateQuarantineTables},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return errors.Wrapf(err, "failed to migrate %s tables", step.name)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) Ping(ctx context.Context) (err error) {
+	ctx, end := s.startQuery(ctx, "Ping")
+	defer func() { end(err) }()
+
+	return s.db.PingContext(ctx)
+}
+
+func (s *Storage) CheckSchema(ctx context.Context) (err error) {
+	ctx, end := s.startQuery(ctx, "CheckSchema")
+	defer func() { end(err) }()
+
+	var missing []string
+	err = s.db.SelectContext(ctx, &missing,
+		`SELECT t FROM unnest($1::text[]) AS t WHERE to_regclass(t) IS NULL`, pq.Array(schemaTables))
+	if err != nil {
+		return errors.Wrap(err, "failed to check schema")
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
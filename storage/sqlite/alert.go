@@ -0,0 +1,261 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	createAlertRuleSql = `
+		INSERT INTO alert_rule (
+			kind,
+			base,
+			quote,
+			direction,
+			threshold,
+			webhook_url,
+			secret,
+			key_id
+		) VALUES (
+			:kind,
+			:base,
+			:quote,
+			:direction,
+			:threshold,
+			:webhook_url,
+			:secret,
+			:key_id
+		) RETURNING id;
+	`
+
+	getAlertRuleSql = `
+		SELECT
+			id,
+			kind,
+			base,
+			quote,
+			direction,
+			threshold,
+			webhook_url,
+			secret,
+			key_id,
+			created_at
+		FROM alert_rule
+	`
+
+	deleteAlertRuleSql = `
+		DELETE FROM alert_rule WHERE id = :id
+	`
+
+	createAlertDeliverySql = `
+		INSERT INTO alert_delivery (
+			rule_id,
+			quote,
+			published_date,
+			payload,
+			status
+		) VALUES (
+			:rule_id,
+			:quote,
+			:published_date,
+			:payload,
+			:status
+		)
+		ON CONFLICT (rule_id, quote, published_date) DO NOTHING
+		RETURNING id;
+	`
+
+	updateAlertDeliverySql = `
+		UPDATE alert_delivery SET
+			status = :status,
+			attempts = :attempts,
+			response_status = :response_status,
+			error = :error,
+			next_attempt_at = :next_attempt_at,
+			updated_at = :updated_at
+		WHERE id = :id
+	`
+
+	getAlertDeliveriesSql = `
+		SELECT
+			id,
+			rule_id,
+			quote,
+			published_date,
+			payload,
+			status,
+			attempts,
+			response_status,
+			error,
+			created_at,
+			updated_at,
+			next_attempt_at
+		FROM alert_delivery
+	`
+)
+
+func (s *Store) CreateAlertRule(ctx context.Context, rule storage.AlertRule) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateAlertRule")
+	defer func() { end(err) }()
+
+	if rule.Threshold, err = storage.NormalizeDecimal(rule.Threshold); err != nil {
+		return "", errors.Wrap(err, "failed to create alert rule")
+	}
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createAlertRuleSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, rule).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create alert rule")
+	}
+	return id, nil
+}
+
+func (s *Store) GetAlertRules(ctx context.Context, keyID string) (_ []storage.AlertRule, err error) {
+	ctx, end := s.startQuery(ctx, "GetAlertRules")
+	defer func() { end(err) }()
+
+	var rules []storage.AlertRule
+
+	var conditions []string
+	params := map[string]interface{}{}
+	if keyID != "" {
+		conditions = append(conditions, "key_id = :key_id")
+		params["key_id"] = keyID
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, fmt.Sprintf("%s %s ORDER BY created_at", getAlertRuleSql, where(conditions)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving alert rules")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &rules, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve alert rules")
+	}
+	return rules, nil
+}
+
+func (s *Store) GetAlertRule(ctx context.Context, id string) (_ storage.AlertRule, err error) {
+	ctx, end := s.startQuery(ctx, "GetAlertRule")
+	defer func() { end(err) }()
+
+	var rule storage.AlertRule
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAlertRuleSql+" WHERE id = :id")
+	if err != nil {
+		return rule, errors.Wrap(err, "failed to prepare statement for retrieving alert rule")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &rule, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rule, storage.ErrNotFound
+		}
+		return rule, errors.Wrap(err, "failed to retrieve alert rule")
+	}
+	return rule, nil
+}
+
+func (s *Store) DeleteAlertRule(ctx context.Context, id, keyID string) (err error) {
+	ctx, end := s.startQuery(ctx, "DeleteAlertRule")
+	defer func() { end(err) }()
+
+	query := deleteAlertRuleSql
+	params := map[string]interface{}{"id": id}
+	if keyID != "" {
+		query += " AND key_id = :key_id"
+		params["key_id"] = keyID
+	}
+	return s.execAffectingOne(ctx, query, params, "failed to delete alert rule")
+}
+
+func (s *Store) CreateAlertDelivery(ctx context.Context, delivery storage.AlertDelivery) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateAlertDelivery")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createAlertDeliverySql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	params := map[string]interface{}{
+		"rule_id":        delivery.RuleID,
+		"quote":          delivery.Quote,
+		"published_date": date(delivery.Date),
+		"payload":        delivery.Payload,
+		"status":         delivery.Status,
+	}
+	if err := nstmt.QueryRowContext(ctx, params).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to create alert delivery")
+	}
+	return id, nil
+}
+
+func (s *Store) UpdateAlertDelivery(ctx context.Context, delivery storage.AlertDelivery) (err error) {
+	ctx, end := s.startQuery(ctx, "UpdateAlertDelivery")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{
+		"id":              delivery.ID,
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_status": delivery.ResponseStatus,
+		"error":           delivery.Error,
+		"next_attempt_at": timestamp(delivery.NextAttemptAt),
+		"updated_at":      timestamp(time.Now()),
+	}
+	if _, err := s.db.NamedExecContext(ctx, updateAlertDeliverySql, params); err != nil {
+		return errors.Wrap(err, "failed to update alert delivery")
+	}
+	return nil
+}
+
+func (s *Store) GetAlertDeliveries(ctx context.Context, ruleID string) (_ []storage.AlertDelivery, err error) {
+	ctx, end := s.startQuery(ctx, "GetAlertDeliveries")
+	defer func() { end(err) }()
+
+	var deliveries []storage.AlertDelivery
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAlertDeliveriesSql+" WHERE rule_id = :rule_id ORDER BY created_at DESC")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving alert deliveries")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &deliveries, map[string]interface{}{"rule_id": ruleID}); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve alert deliveries")
+	}
+	return deliveries, nil
+}
+
+func (s *Store) GetDueAlertDeliveries(ctx context.Context, limit int) (_ []storage.AlertDelivery, err error) {
+	ctx, end := s.startQuery(ctx, "GetDueAlertDeliveries")
+	defer func() { end(err) }()
+
+	var deliveries []storage.AlertDelivery
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAlertDeliveriesSql+`
+		WHERE status = 'pending' AND next_attempt_at <= :now
+		ORDER BY next_attempt_at
+		LIMIT :limit`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving due alert deliveries")
+	}
+	defer nstmt.Close()
+	params := map[string]interface{}{"now": timestamp(time.Now()), "limit": limit}
+	if err = nstmt.SelectContext(ctx, &deliveries, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve due alert deliveries")
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// Scopes are stored as text in the Postgres array syntax, which
+// pq.StringArray reads and writes.
+const (
+	createAPIKeySql = `
+		INSERT INTO api_key (
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			rate_limit,
+			burst,
+			monthly_quota,
+			quota_mode
+		) VALUES (
+			:name,
+			:prefix,
+			:key_hash,
+			:scopes,
+			:rate_limit,
+			:burst,
+			:monthly_quota,
+			:quota_mode
+		) RETURNING id;
+	`
+
+	getAPIKeySql = `
+		SELECT
+			id,
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			rate_limit,
+			burst,
+			monthly_quota,
+			quota_mode,
+			created_at,
+			revoked_at
+		FROM api_key
+	`
+
+	updateAPIKeyScopesSql = `
+		UPDATE api_key SET scopes = :scopes WHERE id = :id AND revoked_at IS NULL
+	`
+
+	revokeAPIKeySql = `
+		UPDATE api_key SET revoked_at = :revoked_at WHERE id = :id AND revoked_at IS NULL
+	`
+)
+
+func (s *Store) CreateAPIKey(ctx context.Context, key storage.APIKey) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateAPIKey")
+	defer func() { end(err) }()
+
+	if key.Scopes == nil {
+		key.Scopes = pq.StringArray{}
+	}
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createAPIKeySql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, key).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create api key")
+	}
+	return id, nil
+}
+
+func (s *Store) GetAPIKeys(ctx context.Context) (_ []storage.APIKey, err error) {
+	ctx, end := s.startQuery(ctx, "GetAPIKeys")
+	defer func() { end(err) }()
+
+	var keys []storage.APIKey
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAPIKeySql+" ORDER BY created_at")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving api keys")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &keys, map[string]interface{}{}); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve api keys")
+	}
+	return keys, nil
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (_ storage.APIKey, err error) {
+	ctx, end := s.startQuery(ctx, "GetAPIKeyByHash")
+	defer func() { end(err) }()
+
+	var key storage.APIKey
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getAPIKeySql+" WHERE key_hash = :key_hash AND revoked_at IS NULL")
+	if err != nil {
+		return key, errors.Wrap(err, "failed to prepare statement for retrieving api key")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &key, map[string]interface{}{"key_hash": hash}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, storage.ErrNotFound
+		}
+		return key, errors.Wrap(err, "failed to retrieve api key")
+	}
+	return key, nil
+}
+
+func (s *Store) UpdateAPIKeyScopes(ctx context.Context, id string, scopes []string) (err error) {
+	ctx, end := s.startQuery(ctx, "UpdateAPIKeyScopes")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{"id": id, "scopes": pq.StringArray(scopes)}
+	return s.execAffectingOne(ctx, updateAPIKeyScopesSql, params, "failed to update api key scopes")
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id string) (err error) {
+	ctx, end := s.startQuery(ctx, "RevokeAPIKey")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{"id": id, "revoked_at": timestamp(time.Now())}
+	return s.execAffectingOne(ctx, revokeAPIKeySql, params, "failed to revoke api key")
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	createApprovalSql = `
+		INSERT INTO approval (
+			kind,
+			published_date,
+			override_id,
+			requested_by,
+			requested_by_key,
+			requested_at
+		) VALUES (
+			:kind,
+			:published_date,
+			:override_id,
+			:requested_by,
+			:requested_by_key,
+			:requested_at
+		) RETURNING id;
+	`
+
+	decideApprovalSql = `
+		UPDATE approval SET
+			status = :status,
+			decided_by = :decided_by,
+			decided_by_key = :decided_by_key,
+			decided_at = :decided_at,
+			comment = :comment
+		WHERE id = :id AND status = 'pending'
+	`
+
+	getApprovalSql = `
+		SELECT
+			id,
+			kind,
+			published_date,
+			override_id,
+			requested_by,
+			requested_by_key,
+			requested_at,
+			status,
+			decided_by,
+			decided_by_key,
+			decided_at,
+			comment
+		FROM approval
+	`
+)
+
+func (s *Store) CreateApproval(ctx context.Context, approval storage.Approval) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateApproval")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createApprovalSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	params := map[string]interface{}{
+		"kind":             approval.Kind,
+		"published_date":   date(approval.Date),
+		"override_id":      approval.OverrideID,
+		"requested_by":     approval.RequestedBy,
+		"requested_by_key": approval.RequestedByKey,
+		"requested_at":     timestamp(time.Now()),
+	}
+	if err := nstmt.QueryRowContext(ctx, params).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create approval")
+	}
+	return id, nil
+}
+
+func (s *Store) GetApprovals(ctx context.Context, filter storage.ApprovalFilter) (_ []storage.Approval, err error) {
+	ctx, end := s.startQuery(ctx, "GetApprovals")
+	defer func() { end(err) }()
+
+	var approvals []storage.Approval
+
+	params := map[string]interface{}{}
+	conditions := dateRangeConditions(filter.StartDate, filter.EndDate, params)
+
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = :kind")
+		params["kind"] = filter.Kind
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = :status")
+		params["status"] = filter.Status
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY requested_at, published_date", getApprovalSql, where(conditions))
+	if filter.Limit > 0 {
+		query += " LIMIT :limit"
+		params["limit"] = filter.Limit
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving approvals")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &approvals, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve approvals")
+	}
+	return approvals, nil
+}
+
+func (s *Store) GetApproval(ctx context.Context, id string) (_ storage.Approval, err error) {
+	ctx, end := s.startQuery(ctx, "GetApproval")
+	defer func() { end(err) }()
+
+	var approval storage.Approval
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getApprovalSql+" WHERE id = :id")
+	if err != nil {
+		return approval, errors.Wrap(err, "failed to prepare statement for retrieving approval")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &approval, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return approval, storage.ErrNotFound
+		}
+		return approval, errors.Wrap(err, "failed to retrieve approval")
+	}
+	return approval, nil
+}
+
+func (s *Store) DecideApproval(ctx context.Context, approval storage.Approval) (err error) {
+	ctx, end := s.startQuery(ctx, "DecideApproval")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{
+		"id":             approval.ID,
+		"status":         approval.Status,
+		"decided_by":     approval.DecidedBy,
+		"decided_by_key": approval.DecidedByKey,
+		"decided_at":     timestamp(time.Now()),
+		"comment":        approval.Comment,
+	}
+	return s.execAffectingOne(ctx, decideApprovalSql, params, "failed to decide approval")
+}
@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	createCurrencyRateSql = `
+		INSERT INTO currency_rate (
+			base,
+			quote,
+			rate,
+			published_date,
+			recorded_at
+		) VALUES (
+			:base,
+			:quote,
+			:rate,
+			:published_date,
+			:recorded_at
+		) RETURNING id;
+	`
+
+	supersedeCurrencyRateSql = `
+		UPDATE currency_rate SET superseded_at = :recorded_at
+		WHERE base = :base
+			AND quote = :quote
+			AND published_date = :published_date
+			AND superseded_at IS NULL
+	`
+
+	getCurrencyRateSql = `
+		SELECT
+			base,
+			quote,
+			rate,
+			published_date,
+			recorded_at
+		FROM currency_rate
+	`
+
+	getLatestCurrencyRateDateSql = `
+		SELECT MAX(published_date) FROM currency_rate
+	`
+)
+
+func (s *Store) CreateCurrencyRate(ctx context.Context, rate storage.Rate) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateCurrencyRate")
+	defer func() { end(err) }()
+
+	value, err := storage.NormalizeDecimal(rate.Rate)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create currency rate")
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to begin currency rate transaction")
+	}
+	defer tx.Rollback()
+
+	params := map[string]interface{}{
+		"base":           rate.Base,
+		"quote":          rate.Quote,
+		"rate":           value,
+		"published_date": date(rate.Date),
+		"recorded_at":    timestamp(time.Now()),
+	}
+	if _, err := tx.NamedExecContext(ctx, supersedeCurrencyRateSql, params); err != nil {
+		return "", errors.Wrap(err, "failed to supersede currency rate")
+	}
+
+	var id string
+	nstmt, err := tx.PrepareNamedContext(ctx, createCurrencyRateSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, params).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create currency rate")
+	}
+	return id, errors.Wrap(tx.Commit(), "failed to commit currency rate")
+}
+
+// insertBatchSize is the number of rows per INSERT statement, well below
+// the limit of 32766 parameters per statement.
+const insertBatchSize = 500
+
+func (s *Store) CreateCurrencyRates(ctx context.Context, rates []storage.Rate) (err error) {
+	ctx, end := s.startQuery(ctx, "CreateCurrencyRates")
+	defer func() { end(err) }()
+
+	if len(rates) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin currency rates transaction")
+	}
+	defer tx.Rollback()
+
+	recordedAt := timestamp(time.Now())
+	for len(rates) > 0 {
+		batch := rates
+		if len(batch) > insertBatchSize {
+			batch = batch[:insertBatchSize]
+		}
+		rates = rates[len(batch):]
+
+		keys := make([]string, 0, len(batch))
+		keyArgs := make([]interface{}, 0, 3*len(batch)+1)
+		keyArgs = append(keyArgs, recordedAt)
+		values := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, 5*len(batch))
+		for _, rate := range batch {
+			value, err := storage.NormalizeDecimal(rate.Rate)
+			if err != nil {
+				return errors.Wrap(err, "failed to create currency rates")
+			}
+			keys = append(keys, "(?, ?, ?)")
+			keyArgs = append(keyArgs, rate.Base, rate.Quote, date(rate.Date))
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, rate.Base, rate.Quote, value, date(rate.Date), recordedAt)
+		}
+
+		query := "UPDATE currency_rate SET superseded_at = ? WHERE superseded_at IS NULL AND (base, quote, published_date) IN (VALUES " + strings.Join(keys, ", ") + ")"
+		if _, err := tx.ExecContext(ctx, query, keyArgs...); err != nil {
+			return errors.Wrap(err, "failed to supersede currency rates")
+		}
+
+		query = "INSERT INTO currency_rate (base, quote, rate, published_date, recorded_at) VALUES " + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrap(err, "failed to create currency rates")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit currency rates")
+}
+
+func (s *Store) GetCurrencyRates(ctx context.Context, filter storage.CurrencyFilter) (_ []storage.Rate, err error) {
+	ctx, end := s.startQuery(ctx, "GetCurrencyRates")
+	defer func() { end(err) }()
+
+	var rates []storage.Rate
+
+	params := map[string]interface{}{}
+	versions := asOfConditions(filter.AsOf, params)
+	conditions := append([]string(nil), versions...)
+
+	if !filter.Date.IsZero() {
+		conditions = append(conditions, "published_date = :published_date")
+		params["published_date"] = date(filter.Date)
+	} else if filter.GetLatestDate {
+		conditions = append(conditions, fmt.Sprintf("published_date = (%s %s)", getLatestCurrencyRateDateSql, where(versions)))
+	} else {
+		conditions = append(conditions, dateRangeConditions(filter.StartDate, filter.EndDate, params)...)
+	}
+
+	if len(filter.Quotes) > 0 {
+		condition, err := quotesCondition(filter.Quotes, params)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY published_date, quote", getCurrencyRateSql, where(conditions))
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to prepare statement for retrieving currency rates")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &rates, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve currency rates")
+	}
+	return rates, nil
+}
+
+func (s *Store) GetAnalyzedCurrencyRates(ctx context.Context, filter storage.AnalysisFilter) (_ []storage.AnalyzedRate, err error) {
+	ctx, end := s.startQuery(ctx, "GetAnalyzedCurrencyRates")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{}
+	conditions := asOfConditions(filter.AsOf, params)
+	conditions = append(conditions, dateRangeConditions(filter.StartDate, filter.EndDate, params)...)
+
+	if len(filter.Quotes) > 0 {
+		condition, err := quotesCondition(filter.Quotes, params)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	// rates are aggregated in Go, SQLite only has floating point
+	// arithmetic
+	query := fmt.Sprintf("%s %s", getCurrencyRateSql, where(conditions))
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to prepare statement for retrieving analyzed currency rates")
+	}
+	defer nstmt.Close()
+	var rates []storage.Rate
+	if err = nstmt.SelectContext(ctx, &rates, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve analyzed currency rates")
+	}
+
+	analyzed, err := storage.AnalyzeRates(rates)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to analyze currency rates")
+	}
+	return analyzed, nil
+}
+
+func dateRangeConditions(start, end time.Time, params map[string]interface{}) []string {
+	var conditions []string
+	if !start.IsZero() {
+		conditions = append(conditions, "published_date >= :start_date")
+		params["start_date"] = date(start)
+	}
+	if !end.IsZero() {
+		conditions = append(conditions, "published_date <= :end_date")
+		params["end_date"] = date(end)
+	}
+	return conditions
+}
+
+// asOfConditions select the current version of each rate, or the version
+// that was current at asOf when it is set.
+func asOfConditions(asOf time.Time, params map[string]interface{}) []string {
+	if asOf.IsZero() {
+		return []string{"superseded_at IS NULL"}
+	}
+	params["as_of"] = timestamp(asOf)
+	return []string{"recorded_at <= :as_of", "(superseded_at IS NULL OR superseded_at > :as_of)"}
+}
+
+// quotesCondition matches any of quotes, passed as a JSON array since
+// SQLite has no array parameters.
+func quotesCondition(quotes []string, params map[string]interface{}) (string, error) {
+	b, err := json.Marshal(quotes)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode quotes")
+	}
+	params["quotes"] = string(b)
+	return "quote IN (SELECT value FROM json_each(:quotes))", nil
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
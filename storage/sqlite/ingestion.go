@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	createIngestionRunSql = `
+		INSERT INTO ingestion_run (
+			source,
+			url,
+			started_at,
+			finished_at,
+			http_status,
+			feed_hash,
+			first_date,
+			last_date,
+			rows_inserted,
+			rows_updated,
+			rows_rejected,
+			error
+		) VALUES (
+			:source,
+			:url,
+			:started_at,
+			:finished_at,
+			:http_status,
+			:feed_hash,
+			:first_date,
+			:last_date,
+			:rows_inserted,
+			:rows_updated,
+			:rows_rejected,
+			:error
+		) RETURNING id;
+	`
+
+	getIngestionRunSql = `
+		SELECT
+			id,
+			source,
+			url,
+			started_at,
+			finished_at,
+			http_status,
+			feed_hash,
+			first_date,
+			last_date,
+			rows_inserted,
+			rows_updated,
+			rows_rejected,
+			error
+		FROM ingestion_run
+	`
+)
+
+func (s *Store) CreateIngestionRun(ctx context.Context, run storage.IngestionRun) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateIngestionRun")
+	defer func() { end(err) }()
+
+	var id string
+	nstmt, err := s.db.PrepareNamedContext(ctx, createIngestionRunSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	params := map[string]interface{}{
+		"source":        run.Source,
+		"url":           run.URL,
+		"started_at":    timestamp(run.StartedAt),
+		"finished_at":   timestamp(run.FinishedAt),
+		"http_status":   run.HTTPStatus,
+		"feed_hash":     run.FeedHash,
+		"first_date":    nullDate(run.FirstDate),
+		"last_date":     nullDate(run.LastDate),
+		"rows_inserted": run.Inserted,
+		"rows_updated":  run.Updated,
+		"rows_rejected": run.Rejected,
+		"error":         run.Error,
+	}
+	if err := nstmt.QueryRowContext(ctx, params).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create ingestion run")
+	}
+	return id, nil
+}
+
+func (s *Store) GetIngestionRuns(ctx context.Context, filter storage.IngestionFilter) (_ []storage.IngestionRun, err error) {
+	ctx, end := s.startQuery(ctx, "GetIngestionRuns")
+	defer func() { end(err) }()
+
+	var runs []storage.IngestionRun
+
+	var conditions []string
+	params := map[string]interface{}{}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "started_at >= :from")
+		params["from"] = timestamp(filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "started_at < :to")
+		params["to"] = timestamp(filter.To)
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY started_at DESC", getIngestionRunSql, where(conditions))
+	if filter.Limit > 0 {
+		query += " LIMIT :limit"
+		params["limit"] = filter.Limit
+	}
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving ingestion runs")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &runs, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve ingestion runs")
+	}
+	return runs, nil
+}
+
+func (s *Store) GetIngestionRun(ctx context.Context, id string) (_ storage.IngestionRun, err error) {
+	ctx, end := s.startQuery(ctx, "GetIngestionRun")
+	defer func() { end(err) }()
+
+	var run storage.IngestionRun
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getIngestionRunSql+" WHERE id = :id")
+	if err != nil {
+		return run, errors.Wrap(err, "failed to prepare statement for retrieving ingestion run")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &run, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return run, storage.ErrNotFound
+		}
+		return run, errors.Wrap(err, "failed to retrieve ingestion run")
+	}
+	return run, nil
+}
+
+func nullDate(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return date(*t)
+}
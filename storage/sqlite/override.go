@@ -0,0 +1,274 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	supersedeRateOverrideByKeySql = `
+		UPDATE rate_override SET superseded_at = :recorded_at
+		WHERE base = :base
+			AND quote = :quote
+			AND published_date = :published_date
+			AND superseded_at IS NULL
+	`
+
+	supersedeRateOverrideSql = `
+		UPDATE rate_override SET superseded_at = :recorded_at
+		WHERE id = :id AND superseded_at IS NULL
+		RETURNING base, quote, published_date
+	`
+
+	deleteRateOverrideSql = `
+		UPDATE rate_override SET superseded_at = :recorded_at
+		WHERE id = :id AND superseded_at IS NULL
+	`
+
+	createRateOverrideSql = `
+		INSERT INTO rate_override (
+			id,
+			base,
+			quote,
+			rate,
+			published_date,
+			reason,
+			author,
+			recorded_at
+		) VALUES (
+			COALESCE(NULLIF(:id, ''), ` + newID + `),
+			:base,
+			:quote,
+			:rate,
+			:published_date,
+			:reason,
+			:author,
+			:recorded_at
+		) RETURNING id;
+	`
+
+	getRateOverrideSql = `
+		SELECT
+			id,
+			base,
+			quote,
+			rate,
+			published_date,
+			reason,
+			author,
+			recorded_at
+		FROM rate_override
+	`
+
+	getRateOverrideVersionSql = `
+		SELECT recorded_at, superseded_at FROM rate_override
+	`
+)
+
+// overrideVersion is when a version of an override was written and, unless
+// it is current, superseded.
+type overrideVersion struct {
+	RecordedAt   time.Time  `db:"recorded_at"`
+	SupersededAt *time.Time `db:"superseded_at"`
+}
+
+func (s *Store) CreateRateOverride(ctx context.Context, override storage.RateOverride) (_ string, err error) {
+	ctx, end := s.startQuery(ctx, "CreateRateOverride")
+	defer func() { end(err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to begin rate override transaction")
+	}
+	defer tx.Rollback()
+
+	override.ID = ""
+	params, err := overrideParams(override)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.NamedExecContext(ctx, supersedeRateOverrideByKeySql, params); err != nil {
+		return "", errors.Wrap(err, "failed to supersede rate override")
+	}
+
+	id, err := insertRateOverride(ctx, tx, params)
+	if err != nil {
+		return "", err
+	}
+	return id, errors.Wrap(tx.Commit(), "failed to commit rate override")
+}
+
+func (s *Store) GetRateOverrides(ctx context.Context, filter storage.OverrideFilter) (_ []storage.RateOverride, err error) {
+	ctx, end := s.startQuery(ctx, "GetRateOverrides")
+	defer func() { end(err) }()
+
+	var overrides []storage.RateOverride
+
+	params := map[string]interface{}{}
+	conditions := asOfConditions(filter.AsOf, params)
+	conditions = append(conditions, dateRangeConditions(filter.StartDate, filter.EndDate, params)...)
+
+	if len(filter.Quotes) > 0 {
+		condition, err := quotesCondition(filter.Quotes, params)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	query := fmt.Sprintf("%s %s ORDER BY published_date, quote", getRateOverrideSql, where(conditions))
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving rate overrides")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &overrides, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve rate overrides")
+	}
+	return overrides, nil
+}
+
+func (s *Store) GetRateOverridesChangedAt(ctx context.Context, filter storage.OverrideFilter) (_ time.Time, err error) {
+	ctx, end := s.startQuery(ctx, "GetRateOverridesChangedAt")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{}
+	conditions := dateRangeConditions(filter.StartDate, filter.EndDate, params)
+	if !filter.AsOf.IsZero() {
+		conditions = append(conditions, "recorded_at <= :as_of")
+		params["as_of"] = timestamp(filter.AsOf)
+	}
+	if len(filter.Quotes) > 0 {
+		condition, err := quotesCondition(filter.Quotes, params)
+		if err != nil {
+			return time.Time{}, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	var versions []overrideVersion
+	nstmt, err := s.db.PrepareNamedContext(ctx, fmt.Sprintf("%s %s", getRateOverrideVersionSql, where(conditions)))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to prepare statement for retrieving rate override versions")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &versions, params); err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to retrieve rate override versions")
+	}
+
+	var changed time.Time
+	for _, v := range versions {
+		if v.RecordedAt.After(changed) {
+			changed = v.RecordedAt
+		}
+		if v.SupersededAt != nil && v.SupersededAt.After(changed) && (filter.AsOf.IsZero() || !v.SupersededAt.After(filter.AsOf)) {
+			changed = *v.SupersededAt
+		}
+	}
+	return changed, nil
+}
+
+func (s *Store) GetRateOverride(ctx context.Context, id string) (_ storage.RateOverride, err error) {
+	ctx, end := s.startQuery(ctx, "GetRateOverride")
+	defer func() { end(err) }()
+
+	var override storage.RateOverride
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, getRateOverrideSql+" WHERE id = :id AND superseded_at IS NULL")
+	if err != nil {
+		return override, errors.Wrap(err, "failed to prepare statement for retrieving rate override")
+	}
+	defer nstmt.Close()
+	if err = nstmt.GetContext(ctx, &override, map[string]interface{}{"id": id}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return override, storage.ErrNotFound
+		}
+		return override, errors.Wrap(err, "failed to retrieve rate override")
+	}
+	return override, nil
+}
+
+func (s *Store) UpdateRateOverride(ctx context.Context, override storage.RateOverride) (err error) {
+	ctx, end := s.startQuery(ctx, "UpdateRateOverride")
+	defer func() { end(err) }()
+
+	params, err := overrideParams(override)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin rate override transaction")
+	}
+	defer tx.Rollback()
+
+	// the new version keeps the base, quote and date of the override
+	nstmt, err := tx.PrepareNamedContext(ctx, supersedeRateOverrideSql)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	var key storage.RateOverride
+	if err := nstmt.GetContext(ctx, &key, params); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return errors.Wrap(err, "failed to supersede rate override")
+	}
+	params["base"], params["quote"], params["published_date"] = key.Base, key.Quote, date(key.Date)
+
+	if _, err := insertRateOverride(ctx, tx, params); err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit rate override")
+}
+
+func (s *Store) DeleteRateOverride(ctx context.Context, id string) (err error) {
+	ctx, end := s.startQuery(ctx, "DeleteRateOverride")
+	defer func() { end(err) }()
+
+	params := map[string]interface{}{"id": id, "recorded_at": timestamp(time.Now())}
+	return s.execAffectingOne(ctx, deleteRateOverrideSql, params, "failed to delete rate override")
+}
+
+// overrideParams binds override as stored, recorded now.
+func overrideParams(override storage.RateOverride) (map[string]interface{}, error) {
+	value, err := storage.NormalizeDecimal(override.Rate)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid rate override")
+	}
+	return map[string]interface{}{
+		"id":             override.ID,
+		"base":           override.Base,
+		"quote":          override.Quote,
+		"rate":           value,
+		"published_date": date(override.Date),
+		"reason":         override.Reason,
+		"author":         override.Author,
+		"recorded_at":    timestamp(time.Now()),
+	}, nil
+}
+
+// insertRateOverride inserts a version of the override bound in params,
+// under a new id unless it has one.
+func insertRateOverride(ctx context.Context, tx *sqlx.Tx, params map[string]interface{}) (string, error) {
+	var id string
+	nstmt, err := tx.PrepareNamedContext(ctx, createRateOverrideSql)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+	if err := nstmt.QueryRowContext(ctx, params).Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to create rate override")
+	}
+	return id, nil
+}
@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// schemaTables are the tables created by the Create*Tables methods.
+var schemaTables = []string{"currency_rate", "alert_rule", "alert_delivery", "api_key", "usage_hourly", "ingestion_run", "rate_override", "approval", "quarantined_rate"}
+
+// newID generates a random UUID, like uuid_generate_v1 does for the
+// Postgres schema.
+const newID = `(lower(
+	hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+))`
+
+// now is the default of timestamp columns. Named queries bind the time
+// instead, sqlx would take the colons for parameters.
+const now = `(strftime('%Y-%m-%d %H:%M:%f', 'now'))`
+
+func (s *Store) CreateCurrencyRatesTable() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS currency_rate (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"base" TEXT NOT NULL,
+		"quote" TEXT NOT NULL,
+		"rate" TEXT NOT NULL,
+		"published_date" DATE NOT NULL,
+		"recorded_at" TIMESTAMP NOT NULL DEFAULT ` + now + `,
+		"superseded_at" TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS currency_rate_published_date ON currency_rate (published_date);`
+
+	if _, err := s.db.Exec(sql); err != nil {
+		return err
+	}
+
+	// tables created before rates were versioned, SQLite cannot add a
+	// NOT NULL column without a constant default
+	if err := s.addColumn("currency_rate", "recorded_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if err := s.addColumn("currency_rate", "superseded_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+
+	sql = `
+	UPDATE currency_rate SET recorded_at = ` + now + ` WHERE recorded_at IS NULL;
+	CREATE INDEX IF NOT EXISTS currency_rate_current ON currency_rate (base, quote, published_date) WHERE superseded_at IS NULL;`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+// addColumn adds column to table unless it exists, SQLite has no ADD
+// COLUMN IF NOT EXISTS.
+func (s *Store) addColumn(table, column, definition string) error {
+	var exists bool
+	err := s.db.Get(&exists, `SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN "%s" %s`, table, column, definition))
+	return err
+}
+
+func (s *Store) CreateAlertTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS alert_rule (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"kind" TEXT NOT NULL,
+		"base" TEXT NOT NULL,
+		"quote" TEXT NOT NULL DEFAULT '',
+		"direction" TEXT NOT NULL DEFAULT '',
+		"threshold" TEXT NOT NULL,
+		"webhook_url" TEXT NOT NULL,
+		"secret" TEXT NOT NULL,
+		"created_at" TIMESTAMP NOT NULL DEFAULT ` + now + `
+	);
+	CREATE TABLE IF NOT EXISTS alert_delivery (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"rule_id" TEXT NOT NULL REFERENCES alert_rule (id) ON DELETE CASCADE,
+		"quote" TEXT NOT NULL,
+		"published_date" DATE NOT NULL,
+		"payload" TEXT NOT NULL,
+		"status" TEXT NOT NULL,
+		"attempts" INTEGER NOT NULL DEFAULT 0,
+		"response_status" INTEGER NOT NULL DEFAULT 0,
+		"error" TEXT NOT NULL DEFAULT '',
+		"created_at" TIMESTAMP NOT NULL DEFAULT ` + now + `,
+		"updated_at" TIMESTAMP NOT NULL DEFAULT ` + now + `,
+		UNIQUE (rule_id, quote, published_date)
+	);`
+
+	if _, err := s.db.Exec(sql); err != nil {
+		return err
+	}
+
+	if err := s.addColumn("alert_rule", "key_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// a constant default, due at once, as SQLite cannot add a column
+	// defaulting to the current time
+	if err := s.addColumn("alert_delivery", "next_attempt_at", "TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00.000'"); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS alert_delivery_due ON alert_delivery (next_attempt_at) WHERE status = 'pending';`)
+	return err
+}
+
+func (s *Store) CreateAPIKeyTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS api_key (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"name" TEXT NOT NULL,
+		"prefix" TEXT NOT NULL,
+		"key_hash" TEXT NOT NULL UNIQUE,
+		"scopes" TEXT NOT NULL DEFAULT '{}',
+		"rate_limit" INTEGER NOT NULL,
+		"burst" INTEGER NOT NULL,
+		"monthly_quota" INTEGER NOT NULL DEFAULT 0,
+		"quota_mode" TEXT NOT NULL DEFAULT 'block',
+		"created_at" TIMESTAMP NOT NULL DEFAULT ` + now + `,
+		"revoked_at" TIMESTAMP
+	);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Store) CreateUsageTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS usage_hourly (
+		"key_id" TEXT NOT NULL,
+		"endpoint" TEXT NOT NULL,
+		"hour" TIMESTAMP NOT NULL,
+		"requests" INTEGER NOT NULL,
+		PRIMARY KEY (key_id, endpoint, hour)
+	);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Store) CreateIngestionTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS ingestion_run (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"source" TEXT NOT NULL,
+		"url" TEXT NOT NULL,
+		"started_at" TIMESTAMP NOT NULL,
+		"finished_at" TIMESTAMP NOT NULL,
+		"http_status" INTEGER NOT NULL DEFAULT 0,
+		"feed_hash" TEXT NOT NULL DEFAULT '',
+		"first_date" DATE,
+		"last_date" DATE,
+		"rows_inserted" INTEGER NOT NULL DEFAULT 0,
+		"rows_updated" INTEGER NOT NULL DEFAULT 0,
+		"rows_rejected" INTEGER NOT NULL DEFAULT 0,
+		"error" TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS ingestion_run_started_at ON ingestion_run (started_at);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Store) CreateOverrideTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS rate_override (
+		"revision_id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"id" TEXT NOT NULL,
+		"base" TEXT NOT NULL,
+		"quote" TEXT NOT NULL,
+		"rate" TEXT NOT NULL,
+		"published_date" DATE NOT NULL,
+		"reason" TEXT NOT NULL,
+		"author" TEXT NOT NULL,
+		"recorded_at" TIMESTAMP NOT NULL DEFAULT ` + now + `,
+		"superseded_at" TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS rate_override_id ON rate_override (id);
+	CREATE INDEX IF NOT EXISTS rate_override_published_date ON rate_override (published_date);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+func (s *Store) CreateApprovalTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS approval (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"kind" TEXT NOT NULL,
+		"published_date" DATE NOT NULL,
+		"override_id" TEXT NOT NULL DEFAULT '',
+		"requested_by" TEXT NOT NULL,
+		"requested_by_key" TEXT NOT NULL DEFAULT '',
+		"requested_at" TIMESTAMP NOT NULL DEFAULT ` + now + `,
+		"status" TEXT NOT NULL DEFAULT 'pending',
+		"decided_by" TEXT NOT NULL DEFAULT '',
+		"decided_by_key" TEXT NOT NULL DEFAULT '',
+		"decided_at" TIMESTAMP,
+		"comment" TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS approval_published_date ON approval (published_date);
+	CREATE INDEX IF NOT EXISTS approval_status ON approval (status);`
+
+	if _, err := s.db.Exec(sql); err != nil {
+		return err
+	}
+	if err := s.addColumn("approval", "requested_by_key", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return s.addColumn("approval", "decided_by_key", "TEXT NOT NULL DEFAULT ''")
+}
+
+func (s *Store) CreateQuarantineTables() error {
+	sql := `
+	CREATE TABLE IF NOT EXISTS quarantined_rate (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"base" TEXT NOT NULL,
+		"quote" TEXT NOT NULL,
+		"rate" TEXT NOT NULL,
+		"published_date" DATE NOT NULL,
+		"rule" TEXT NOT NULL,
+		"detail" TEXT NOT NULL DEFAULT '',
+		"detected_at" TIMESTAMP NOT NULL DEFAULT ` + now + `
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS quarantined_rate_key ON quarantined_rate (base, quote, published_date, rule, rate);
+	CREATE INDEX IF NOT EXISTS quarantined_rate_detected_at ON quarantined_rate (detected_at);`
+
+	_, err := s.db.Exec(sql)
+	return err
+}
+
+// Migrate creates every table that does not exist yet. It is safe to run on
+// every start.
+func (s *Store) Migrate() error {
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"currency rates", s.CreateCurrencyRatesTable},
+		{"alerts", s.CreateAlertTables},
+		{"api keys", s.CreateAPIKeyTables},
+		{"usage", s.CreateUsageTables},
+		{"ingestion", s.CreateIngestionTables},
+		{"overrides", s.CreateOverrideTables},
+		{"approvals", s.CreateApprovalTables},
+		{"quarantine", s.CreateQuarantineTables},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return errors.Wrapf(err, "failed to migrate %s tables", step.name)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Ping(ctx context.Context) (err error) {
+	ctx, end := s.startQuery(ctx, "Ping")
+	defer func() { end(err) }()
+
+	return s.db.PingContext(ctx)
+}
+
+func (s *Store) CheckSchema(ctx context.Context) (err error) {
+	ctx, end := s.startQuery(ctx, "CheckSchema")
+	defer func() { end(err) }()
+
+	var tables []string
+	if err = s.db.SelectContext(ctx, &tables, `SELECT name FROM sqlite_master WHERE type = 'table'`); err != nil {
+		return errors.Wrap(err, "failed to check schema")
+	}
+
+	exists := map[string]bool{}
+	for _, table := range tables {
+		exists[table] = true
+	}
+
+	var missing []string
+	for _, table := range schemaTables {
+		if !exists[table] {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package sqlite implements the storage interfaces on SQLite through a pure
+// Go driver, so that the service can run without a Postgres server.
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/tracing"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.000"
+)
+
+var _ storage.Backend = (*Store)(nil)
+
+type Store struct {
+	db *sqlx.DB
+
+	// Logger receives a debug line per query. It defaults to the global
+	// logger.
+	Logger *zap.Logger
+}
+
+func init() {
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
+// Open opens the SQLite database file at path, creating it if needed, with
+// foreign keys enforced. Use ":memory:" for a database that lives as long
+// as the returned handle.
+func Open(path string) (*sqlx.DB, error) {
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sqlx.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open database")
+	}
+
+	// SQLite allows a single writer, and every connection to ":memory:"
+	// is a database of its own
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "failed to open database")
+	}
+	return db, nil
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+var tracer = otel.Tracer("github.com/syahnur197/rakuten/storage/sqlite")
+
+// startQuery starts a client span around the query run by method. The
+// returned function ends the span and logs the query with its outcome.
+func (s *Store) startQuery(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite),
+	)
+
+	return ctx, func(err error) {
+		tracing.End(span, err)
+
+		fields := []zap.Field{zap.String("method", method), zap.Duration("duration", time.Since(start))}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		logging.With(ctx, s.Logger).Debug("query", fields...)
+	}
+}
+
+// execAffectingOne runs a named statement and returns storage.ErrNotFound
+// when it did not affect any row.
+func (s *Store) execAffectingOne(ctx context.Context, query string, params interface{}, message string) error {
+	result, err := s.db.NamedExecContext(ctx, query, params)
+	if err != nil {
+		return errors.Wrap(err, message)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, message)
+	}
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// SQLite has no date types, dates and timestamps are stored as text in
+// these layouts so that they sort and compare as text. The driver parses
+// them back into time.Time for columns declared DATE or TIMESTAMP.
+func date(t time.Time) string {
+	return t.Format(dateLayout)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
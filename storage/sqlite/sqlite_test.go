@@ -0,0 +1,460 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/storagetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := NewStore(db)
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func day(d int) time.Time {
+	return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestStore_CurrencyRates(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	for _, rate := range []storage.Rate{
+		{Base: "EUR", Quote: "SGD", Rate: "100", Date: day(1)},
+		{Base: "EUR", Quote: "SGD", Rate: "200.50", Date: day(5)},
+		{Base: "EUR", Quote: "USD", Rate: "1.0599", Date: day(5)},
+	} {
+		if _, err := s.CreateCurrencyRate(ctx, rate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rates, err := s.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || !rates[0].Date.Equal(day(5)) || rates[0].Rate != "200.5" || rates[1].Quote != "USD" {
+		t.Fatalf("unexpected latest rates %+v", rates)
+	}
+
+	rates, err = s.GetCurrencyRates(ctx, storage.CurrencyFilter{Date: day(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 || rates[0].Rate != "100" {
+		t.Fatalf("unexpected rates for date %+v", rates)
+	}
+
+	rates, err = s.GetCurrencyRates(ctx, storage.CurrencyFilter{StartDate: day(2), Quotes: []string{"USD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 || rates[0].Quote != "USD" {
+		t.Fatalf("unexpected rates for range %+v", rates)
+	}
+
+	analyzed, err := s.GetAnalyzedCurrencyRates(ctx, storage.AnalysisFilter{Quotes: []string{"SGD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storage.AnalyzedRate{Base: "EUR", Quote: "SGD", Min: "100", Max: "200.5", Avg: "150.25"}
+	if len(analyzed) != 1 || analyzed[0] != want {
+		t.Fatalf("got %+v, want %+v", analyzed, want)
+	}
+
+	if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "SGD", Rate: "abc", Date: day(6)}); err == nil {
+		t.Fatal("expected an error for an invalid rate")
+	}
+}
+
+func TestStore_AlertDeliveries(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	ruleID, err := s.CreateAlertRule(ctx, storage.AlertRule{Kind: "threshold", Base: "EUR", Quote: "USD", Threshold: "1.10", WebhookURL: "https://example.com", Secret: "s", KeyID: "key-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := s.GetAlertRule(ctx, ruleID)
+	if err != nil || rule.Threshold != "1.1" || rule.KeyID != "key-a" {
+		t.Fatalf("unexpected rule %+v: %v", rule, err)
+	}
+	for keyID, want := range map[string]int{"": 1, "key-a": 1, "key-b": 0} {
+		if rules, err := s.GetAlertRules(ctx, keyID); err != nil || len(rules) != want {
+			t.Fatalf("got %d rules for key %q, want %d: %v", len(rules), keyID, want, err)
+		}
+	}
+
+	delivery := storage.AlertDelivery{RuleID: ruleID, Quote: "USD", Date: day(5), Payload: "{}", Status: "pending"}
+	id, err := s.CreateAlertDelivery(ctx, delivery)
+	if err != nil || id == "" {
+		t.Fatalf("expected a delivery id, got %q: %v", id, err)
+	}
+	if id, err := s.CreateAlertDelivery(ctx, delivery); err != nil || id != "" {
+		t.Fatalf("expected the duplicate delivery to be skipped, got %q: %v", id, err)
+	}
+
+	due, err := s.GetDueAlertDeliveries(ctx, 10)
+	if err != nil || len(due) != 1 || due[0].ID != id || due[0].Payload != "{}" {
+		t.Fatalf("expected the new delivery to be due, got %+v: %v", due, err)
+	}
+
+	retry := time.Now().Add(time.Hour)
+	if err := s.UpdateAlertDelivery(ctx, storage.AlertDelivery{ID: id, Status: "pending", Attempts: 1, ResponseStatus: 503, NextAttemptAt: retry}); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := s.GetDueAlertDeliveries(ctx, 10); err != nil || len(due) != 0 {
+		t.Fatalf("expected the retry to be scheduled later, got %+v: %v", due, err)
+	}
+
+	if err := s.UpdateAlertDelivery(ctx, storage.AlertDelivery{ID: id, Status: "delivered", Attempts: 2, ResponseStatus: 200}); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := s.GetAlertDeliveries(ctx, ruleID)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Status != "delivered" || !deliveries[0].Date.Equal(day(5)) {
+		t.Fatalf("unexpected deliveries %+v: %v", deliveries, err)
+	}
+
+	if err := s.DeleteAlertRule(ctx, ruleID, "key-b"); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound for another key's rule", err)
+	}
+	if err := s.DeleteAlertRule(ctx, ruleID, "key-a"); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err = s.GetAlertDeliveries(ctx, ruleID)
+	if err != nil || len(deliveries) != 0 {
+		t.Fatalf("expected deliveries to be deleted with the rule, got %d: %v", len(deliveries), err)
+	}
+	if err := s.DeleteAlertRule(ctx, ruleID, ""); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	id, err := s.CreateAPIKey(ctx, storage.APIKey{Name: "test", Prefix: "rk_abc", Hash: "hash", Scopes: []string{"rates:read"}, RateLimit: 60, Burst: 60, QuotaMode: "block"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateAPIKeyScopes(ctx, id, []string{"rates:read", "alerts"}); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := s.GetAPIKeyByHash(ctx, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.Scopes) != 2 || key.Scopes[1] != "alerts" || key.CreatedAt.IsZero() {
+		t.Fatalf("unexpected key %+v", key)
+	}
+
+	if err := s.RevokeAPIKey(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetAPIKeyByHash(ctx, "hash"); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound for a revoked key", err)
+	}
+	keys, err := s.GetAPIKeys(ctx)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Fatalf("expected the revoked key to be listed, got %+v: %v", keys, err)
+	}
+}
+
+func TestStore_Usage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	hour := time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC)
+	counts := []storage.UsageCount{
+		{KeyID: "key-1", Endpoint: "/rates/{date}", Hour: hour, Requests: 2},
+		{KeyID: "key-1", Endpoint: "/rates/{date}", Hour: hour.Add(time.Hour), Requests: 1},
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.IncrementUsage(ctx, counts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := s.GetUsage(ctx, storage.UsageFilter{KeyID: "key-1", From: hour, To: hour.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || usage[0].Requests != 4 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+}
+
+func TestStore_IngestionRuns(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	first, last := day(2), day(5)
+	for i := 0; i < 3; i++ {
+		started := time.Date(2023, 1, 5+i, 16, 0, 0, 0, time.UTC)
+		run := storage.IngestionRun{
+			Source:     "serve",
+			URL:        "https://example.com/feed.xml",
+			StartedAt:  started,
+			FinishedAt: started.Add(time.Second),
+			HTTPStatus: 200,
+			FeedHash:   "abc",
+			FirstDate:  &first,
+			LastDate:   &last,
+			Inserted:   i,
+		}
+		if i == 2 {
+			run.FirstDate, run.LastDate, run.Error = nil, nil, "unexpected response status 503"
+		}
+		if _, err := s.CreateIngestionRun(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := s.GetIngestionRuns(ctx, storage.IngestionFilter{From: day(6), Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Error == "" || runs[0].FirstDate != nil {
+		t.Fatalf("expected the newest, failed run, got %+v", runs)
+	}
+
+	runs, err = s.GetIngestionRuns(ctx, storage.IngestionFilter{To: day(6)})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one run before the 6th, got %+v: %v", runs, err)
+	}
+
+	run, err := s.GetIngestionRun(ctx, runs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.FirstDate == nil || !run.FirstDate.Equal(first) || !run.StartedAt.Equal(time.Date(2023, 1, 5, 16, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected run %+v", run)
+	}
+
+	if _, err := s.GetIngestionRun(ctx, "missing"); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_MigrateUnversionedRates(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the table as created before rates were versioned
+	_, err = db.Exec(`
+	CREATE TABLE currency_rate (
+		"id" TEXT PRIMARY KEY DEFAULT ` + newID + `,
+		"base" TEXT NOT NULL,
+		"quote" TEXT NOT NULL,
+		"rate" TEXT NOT NULL,
+		"published_date" DATE NOT NULL
+	);
+	INSERT INTO currency_rate VALUES ('1', 'EUR', 'USD', '1.1', '2023-01-05');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(db)
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "USD", Rate: "1.2", Date: day(5)}); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := s.GetCurrencyRates(ctx, storage.CurrencyFilter{Date: day(5)})
+	if err != nil || len(rates) != 1 || rates[0].Rate != "1.2" {
+		t.Fatalf("expected the existing rate to be superseded, got %+v: %v", rates, err)
+	}
+}
+
+func TestStore_RateOverrides(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	override := storage.RateOverride{Base: "EUR", Quote: "USD", Rate: "1.10", Date: day(5), Reason: "feed error", Author: "ops"}
+	if _, err := s.CreateRateOverride(ctx, override); err != nil {
+		t.Fatal(err)
+	}
+	// a second override of the same rate replaces the first
+	override.Rate = "1.11"
+	id, err := s.CreateRateOverride(ctx, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	beforeUpdate := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := s.UpdateRateOverride(ctx, storage.RateOverride{ID: id, Rate: "1.12", Reason: "corrected", Author: "lead"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetRateOverride(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Rate != "1.12" || got.Quote != "USD" || !got.Date.Equal(day(5)) || got.Author != "lead" {
+		t.Fatalf("unexpected override %+v", got)
+	}
+
+	overrides, err := s.GetRateOverrides(ctx, storage.OverrideFilter{StartDate: day(5), EndDate: day(5), Quotes: []string{"USD"}})
+	if err != nil || len(overrides) != 1 || overrides[0].ID != id {
+		t.Fatalf("expected the updated override only, got %+v: %v", overrides, err)
+	}
+	overrides, err = s.GetRateOverrides(ctx, storage.OverrideFilter{AsOf: beforeUpdate})
+	if err != nil || len(overrides) != 1 || overrides[0].Rate != "1.11" {
+		t.Fatalf("expected the override before the update, got %+v: %v", overrides, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	beforeDelete := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := s.DeleteRateOverride(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := s.GetRateOverridesChangedAt(ctx, storage.OverrideFilter{StartDate: day(5), EndDate: day(5)}); err != nil || !changed.After(beforeDelete) {
+		t.Fatalf("expected the overrides changed by the deletion, got %s: %v", changed, err)
+	}
+	if changed, err := s.GetRateOverridesChangedAt(ctx, storage.OverrideFilter{AsOf: beforeUpdate}); err != nil || changed.IsZero() || changed.After(beforeUpdate) {
+		t.Fatalf("expected the overrides changed before the update, got %s: %v", changed, err)
+	}
+	if changed, err := s.GetRateOverridesChangedAt(ctx, storage.OverrideFilter{StartDate: day(6)}); err != nil || !changed.IsZero() {
+		t.Fatalf("expected no override changes from the 6th, got %s: %v", changed, err)
+	}
+	if _, err := s.GetRateOverride(ctx, id); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound for a deleted override", err)
+	}
+	if err := s.UpdateRateOverride(ctx, storage.RateOverride{ID: id, Rate: "1"}); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound updating a deleted override", err)
+	}
+	if overrides, err := s.GetRateOverrides(ctx, storage.OverrideFilter{}); err != nil || len(overrides) != 0 {
+		t.Fatalf("expected no overrides, got %+v: %v", overrides, err)
+	}
+}
+
+func TestStore_Approvals(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	first, err := s.CreateApproval(ctx, storage.Approval{Kind: storage.ApprovalPublication, Date: day(5), RequestedBy: "ingestion"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateApproval(ctx, storage.Approval{Kind: storage.ApprovalOverride, Date: day(6), OverrideID: "o-1", RequestedBy: "ops", RequestedByKey: "key-ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.DecideApproval(ctx, storage.Approval{ID: first, Status: storage.ApprovalApproved, DecidedBy: "lead", DecidedByKey: "key-lead", Comment: "checked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only pending approvals can be decided
+	err = s.DecideApproval(ctx, storage.Approval{ID: first, Status: storage.ApprovalRejected, DecidedBy: "lead"})
+	if err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound deciding twice", err)
+	}
+
+	got, err := s.GetApproval(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != storage.ApprovalApproved || got.DecidedBy != "lead" || got.DecidedByKey != "key-lead" || got.Comment != "checked" || got.DecidedAt == nil ||
+		!got.Date.Equal(day(5)) || got.RequestedBy != "ingestion" || got.RequestedAt.IsZero() {
+		t.Fatalf("unexpected approval %+v", got)
+	}
+	if _, err := s.GetApproval(ctx, "unknown"); err != storage.ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	approvals, err := s.GetApprovals(ctx, storage.ApprovalFilter{})
+	if err != nil || len(approvals) != 2 || approvals[0].ID != first || approvals[1].OverrideID != "o-1" || approvals[1].RequestedByKey != "key-ops" || approvals[1].DecidedAt != nil {
+		t.Fatalf("expected both approvals oldest first, got %+v: %v", approvals, err)
+	}
+	approvals, err = s.GetApprovals(ctx, storage.ApprovalFilter{Status: storage.ApprovalPending})
+	if err != nil || len(approvals) != 1 || approvals[0].ID != second {
+		t.Fatalf("expected the pending approval, got %+v: %v", approvals, err)
+	}
+	approvals, err = s.GetApprovals(ctx, storage.ApprovalFilter{Kind: storage.ApprovalPublication, StartDate: day(6)})
+	if err != nil || len(approvals) != 0 {
+		t.Fatalf("expected no publication approvals from the 6th, got %+v: %v", approvals, err)
+	}
+}
+
+func TestStore_Quarantine(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	rates := []storage.QuarantinedRate{
+		{Base: "EUR", Quote: "USD", Rate: "-1", Date: day(5), Rule: "non_positive", Detail: "rate must be a positive number"},
+		{Base: "EUR", Quote: "XYZ", Rate: "2", Date: day(6), Rule: "unknown_currency"},
+	}
+	if err := s.QuarantineRates(ctx, rates); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// the same failure found by a later run is kept once
+	if err := s.QuarantineRates(ctx, append(rates[:1:1], storage.QuarantinedRate{Base: "EUR", Quote: "JPY", Date: day(6), Rule: "missing_currency"})); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetQuarantinedRates(ctx, storage.QuarantineFilter{})
+	if err != nil || len(got) != 3 || got[0].Quote != "JPY" || got[0].ID == "" || got[0].DetectedAt.IsZero() {
+		t.Fatalf("expected three rates newest first, got %+v: %v", got, err)
+	}
+	got, err = s.GetQuarantinedRates(ctx, storage.QuarantineFilter{StartDate: day(5), EndDate: day(5)})
+	if err != nil || len(got) != 1 || got[0].Rate != "-1" || !got[0].Date.Equal(day(5)) || got[0].Detail == "" {
+		t.Fatalf("expected the rate of the 5th, got %+v: %v", got, err)
+	}
+	got, err = s.GetQuarantinedRates(ctx, storage.QuarantineFilter{Rule: "unknown_currency", Limit: 1})
+	if err != nil || len(got) != 1 || got[0].Quote != "XYZ" {
+		t.Fatalf("expected the unknown currency, got %+v: %v", got, err)
+	}
+}
+
+func TestStore_CheckSchema(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := NewStore(db)
+	if err := s.CheckSchema(context.Background()); err == nil {
+		t.Fatal("expected missing tables before migrating")
+	}
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckSchema(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.RakutenStore {
+		return newTestStore(t)
+	})
+}
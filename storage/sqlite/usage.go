@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	incrementUsageSql = `
+		INSERT INTO usage_hourly (
+			key_id,
+			endpoint,
+			hour,
+			requests
+		) VALUES (
+			:key_id,
+			:endpoint,
+			:hour,
+			:requests
+		)
+		ON CONFLICT (key_id, endpoint, hour)
+		DO UPDATE SET requests = usage_hourly.requests + excluded.requests
+	`
+
+	getUsageSql = `
+		SELECT
+			key_id,
+			endpoint,
+			SUM(requests) as requests
+		FROM usage_hourly
+	`
+)
+
+func (s *Store) IncrementUsage(ctx context.Context, counts []storage.UsageCount) (err error) {
+	ctx, end := s.startQuery(ctx, "IncrementUsage")
+	defer func() { end(err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin usage transaction")
+	}
+	defer tx.Rollback()
+
+	nstmt, err := tx.PrepareNamedContext(ctx, incrementUsageSql)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepared name context")
+	}
+	defer nstmt.Close()
+
+	for _, count := range counts {
+		params := map[string]interface{}{
+			"key_id":   count.KeyID,
+			"endpoint": count.Endpoint,
+			"hour":     timestamp(count.Hour),
+			"requests": count.Requests,
+		}
+		if _, err := nstmt.ExecContext(ctx, params); err != nil {
+			return errors.Wrap(err, "failed to increment usage")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit usage")
+}
+
+func (s *Store) GetUsage(ctx context.Context, filter storage.UsageFilter) (_ []storage.UsageCount, err error) {
+	ctx, end := s.startQuery(ctx, "GetUsage")
+	defer func() { end(err) }()
+
+	var counts []storage.UsageCount
+
+	var conditions []string
+	params := map[string]interface{}{}
+
+	if filter.KeyID != "" {
+		conditions = append(conditions, "key_id = :key_id")
+		params["key_id"] = filter.KeyID
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "hour >= :from")
+		params["from"] = timestamp(filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "hour < :to")
+		params["to"] = timestamp(filter.To)
+	}
+
+	query := fmt.Sprintf("%s %s GROUP BY key_id, endpoint ORDER BY key_id, endpoint", getUsageSql, where(conditions))
+
+	nstmt, err := s.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare statement for retrieving usage")
+	}
+	defer nstmt.Close()
+	if err = nstmt.SelectContext(ctx, &counts, params); err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve usage")
+	}
+	return counts, nil
+}
@@ -5,7 +5,16 @@ package storage
 import (
 	"context"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"time"
+
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/tracing"
 )
 
 type Rate struct {
@@ -13,6 +22,9 @@ type Rate struct {
 	Quote string    `db:"quote"`
 	Rate  string    `db:"rate"`
 	Date  time.Time `db:"published_date"`
+	// RecordedAt is when this version of the rate was stored. It is set
+	// by the store and ignored when writing.
+	RecordedAt time.Time `db:"recorded_at"`
 }
 
 type AnalyzedRate struct {
@@ -23,25 +35,408 @@ type AnalyzedRate struct {
 	Avg   string `db:"avg"`
 }
 
+// RakutenStore keeps every version of a rate. Writing a rate for a base,
+// quote and date that is already stored supersedes the stored version,
+// which remains visible to queries with an AsOf before the write.
 type RakutenStore interface {
 	CreateCurrencyRatesTable() error
 
 	CreateCurrencyRate(ctx context.Context, rate Rate) (string, error)
+	// CreateCurrencyRates writes rates in a single transaction, so that
+	// either all of them are stored or none.
+	CreateCurrencyRates(ctx context.Context, rates []Rate) error
 	GetCurrencyRates(ctx context.Context, filter CurrencyFilter) ([]Rate, error)
-	GetAnalyzedCurrencyRates(ctx context.Context) ([]AnalyzedRate, error)
+	GetAnalyzedCurrencyRates(ctx context.Context, filter AnalysisFilter) ([]AnalyzedRate, error)
 }
 
 type CurrencyFilter struct {
 	Date          time.Time
 	GetLatestDate bool
+
+	// StartDate and EndDate select an inclusive range of published dates,
+	// either bound may be left zero. They are ignored when Date or
+	// GetLatestDate is set.
+	StartDate time.Time
+	EndDate   time.Time
+
+	// Quotes restricts the result to the given quote currencies.
+	Quotes []string
+
+	// AsOf, when set, returns the rates as they were stored at that
+	// moment instead of the current versions.
+	AsOf time.Time
+}
+
+type AnalysisFilter struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Quotes    []string
+	AsOf      time.Time
+}
+
+// AlertRule is a rule created by the API key KeyID, which is empty for rules
+// created before owners were recorded.
+type AlertRule struct {
+	ID         string    `db:"id"`
+	Kind       string    `db:"kind"`
+	Base       string    `db:"base"`
+	Quote      string    `db:"quote"`
+	Direction  string    `db:"direction"`
+	Threshold  string    `db:"threshold"`
+	WebhookURL string    `db:"webhook_url"`
+	Secret     string    `db:"secret"`
+	KeyID      string    `db:"key_id"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type AlertDelivery struct {
+	ID             string    `db:"id"`
+	RuleID         string    `db:"rule_id"`
+	Quote          string    `db:"quote"`
+	Date           time.Time `db:"published_date"`
+	Payload        string    `db:"payload"`
+	Status         string    `db:"status"`
+	Attempts       int       `db:"attempts"`
+	ResponseStatus int       `db:"response_status"`
+	Error          string    `db:"error"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+	// NextAttemptAt is when a pending delivery is due, it is due at once
+	// when created.
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+}
+
+type AlertStore interface {
+	CreateAlertTables() error
+
+	CreateAlertRule(ctx context.Context, rule AlertRule) (string, error)
+	// GetAlertRules returns the rules created by keyID, or every rule when
+	// keyID is empty.
+	GetAlertRules(ctx context.Context, keyID string) ([]AlertRule, error)
+	GetAlertRule(ctx context.Context, id string) (AlertRule, error)
+	// DeleteAlertRule deletes the rule when keyID created it or is empty,
+	// and returns ErrNotFound otherwise.
+	DeleteAlertRule(ctx context.Context, id, keyID string) error
+
+	// CreateAlertDelivery records a delivery unless one already exists for
+	// the same rule, quote and published date, in which case it returns
+	// an empty id.
+	CreateAlertDelivery(ctx context.Context, delivery AlertDelivery) (string, error)
+	UpdateAlertDelivery(ctx context.Context, delivery AlertDelivery) error
+	GetAlertDeliveries(ctx context.Context, ruleID string) ([]AlertDelivery, error)
+	// GetDueAlertDeliveries returns up to limit pending deliveries whose
+	// next attempt is due, the longest due first.
+	GetDueAlertDeliveries(ctx context.Context, limit int) ([]AlertDelivery, error)
+}
+
+type APIKey struct {
+	ID        string         `db:"id"`
+	Name      string         `db:"name"`
+	Prefix    string         `db:"prefix"`
+	Hash      string         `db:"key_hash"`
+	Scopes    pq.StringArray `db:"scopes"`
+	RateLimit int            `db:"rate_limit"`
+	Burst     int            `db:"burst"`
+	// MonthlyQuota of requests, 0 for unlimited. QuotaMode is "block" or
+	// "warn".
+	MonthlyQuota int        `db:"monthly_quota"`
+	QuotaMode    string     `db:"quota_mode"`
+	CreatedAt    time.Time  `db:"created_at"`
+	RevokedAt    *time.Time `db:"revoked_at"`
+}
+
+type APIKeyStore interface {
+	CreateAPIKeyTables() error
+
+	CreateAPIKey(ctx context.Context, key APIKey) (string, error)
+	GetAPIKeys(ctx context.Context) ([]APIKey, error)
+	// GetAPIKeyByHash returns ErrNotFound for unknown and revoked keys.
+	GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	UpdateAPIKeyScopes(ctx context.Context, id string, scopes []string) error
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+type UsageCount struct {
+	KeyID    string    `db:"key_id"`
+	Endpoint string    `db:"endpoint"`
+	Hour     time.Time `db:"hour"`
+	Requests int64     `db:"requests"`
+}
+
+type UsageFilter struct {
+	KeyID string
+	// From is inclusive, To is exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// IngestionRun is the audit record of one ingestion of a feed.
+type IngestionRun struct {
+	ID string `db:"id"`
+	// Source is the command that ran the ingestion: "serve", "ingest" or
+	// "backfill".
+	Source     string    `db:"source"`
+	URL        string    `db:"url"`
+	StartedAt  time.Time `db:"started_at"`
+	FinishedAt time.Time `db:"finished_at"`
+	// HTTPStatus of the feed response, 0 for files and failed requests.
+	HTTPStatus int `db:"http_status"`
+	// FeedHash is the hex SHA-256 of the feed document.
+	FeedHash string `db:"feed_hash"`
+	// FirstDate and LastDate are the publication dates the feed covers,
+	// nil when it could not be read.
+	FirstDate *time.Time `db:"first_date"`
+	LastDate  *time.Time `db:"last_date"`
+	Inserted  int        `db:"rows_inserted"`
+	Updated   int        `db:"rows_updated"`
+	Rejected  int        `db:"rows_rejected"`
+	// Error is empty for successful runs.
+	Error string `db:"error"`
+}
+
+type IngestionFilter struct {
+	// From is inclusive, To is exclusive, both bound StartedAt.
+	From time.Time
+	To   time.Time
+	// Limit caps the number of runs returned, 0 for no limit.
+	Limit int
+}
+
+type IngestionStore interface {
+	CreateIngestionTables() error
+
+	CreateIngestionRun(ctx context.Context, run IngestionRun) (string, error)
+	// GetIngestionRuns returns the runs within filter, newest first.
+	GetIngestionRuns(ctx context.Context, filter IngestionFilter) ([]IngestionRun, error)
+	// GetIngestionRun returns ErrNotFound for unknown ids.
+	GetIngestionRun(ctx context.Context, id string) (IngestionRun, error)
+}
+
+// RateOverride replaces the ingested rate of a base, quote and date, or
+// supplies one the feed is missing. Overrides are versioned like rates:
+// updating or deleting one supersedes its current version, which remains
+// visible to queries with an AsOf before the change.
+type RateOverride struct {
+	ID     string    `db:"id"`
+	Base   string    `db:"base"`
+	Quote  string    `db:"quote"`
+	Rate   string    `db:"rate"`
+	Date   time.Time `db:"published_date"`
+	Reason string    `db:"reason"`
+	// Author is the name of the API key that made the change.
+	Author string `db:"author"`
+	// RecordedAt is when this version of the override was made.
+	RecordedAt time.Time `db:"recorded_at"`
+}
+
+type OverrideFilter struct {
+	// StartDate and EndDate select an inclusive range of dates, either
+	// bound may be left zero.
+	StartDate time.Time
+	EndDate   time.Time
+	Quotes    []string
+	AsOf      time.Time
+}
+
+type OverrideStore interface {
+	CreateOverrideTables() error
+
+	// CreateRateOverride supersedes the current override of the same base,
+	// quote and date, if any, and returns the id of the new one.
+	CreateRateOverride(ctx context.Context, override RateOverride) (string, error)
+	// GetRateOverrides returns the overrides within filter ordered by date
+	// and quote.
+	GetRateOverrides(ctx context.Context, filter OverrideFilter) ([]RateOverride, error)
+	// GetRateOverridesChangedAt returns when the overrides within filter
+	// were last created, updated or deleted, as of filter.AsOf when set,
+	// or the zero time if they never were.
+	GetRateOverridesChangedAt(ctx context.Context, filter OverrideFilter) (time.Time, error)
+	// GetRateOverride returns ErrNotFound for unknown and deleted ids.
+	GetRateOverride(ctx context.Context, id string) (RateOverride, error)
+	// UpdateRateOverride replaces the rate, reason and author of the
+	// override with the id of override.
+	UpdateRateOverride(ctx context.Context, override RateOverride) error
+	DeleteRateOverride(ctx context.Context, id string) error
+}
+
+const (
+	// ApprovalPublication approves the ingested rates of a date.
+	ApprovalPublication = "publication"
+	// ApprovalOverride approves a version of a rate override.
+	ApprovalOverride = "override"
+	// ApprovalOverrideDeletion approves deleting a rate override, which
+	// is deleted once approved.
+	ApprovalOverrideDeletion = "override_delete"
+
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// Approval is a change to the published rates awaiting, or having had, the
+// decision of a checker. Approvals are never deleted, they are the audit
+// trail of the decisions.
+type Approval struct {
+	ID   string    `db:"id"`
+	Kind string    `db:"kind"`
+	Date time.Time `db:"published_date"`
+	// OverrideID is set for ApprovalOverride and ApprovalOverrideDeletion.
+	OverrideID string `db:"override_id"`
+	// RequestedBy is the maker: the API key name of an override's author,
+	// or "ingestion" for ingested rates.
+	RequestedBy string `db:"requested_by"`
+	// RequestedByKey is the API key ID of the maker, empty for ingested
+	// rates. Makers and checkers are told apart by key.
+	RequestedByKey string    `db:"requested_by_key"`
+	RequestedAt    time.Time `db:"requested_at"`
+	Status         string    `db:"status"`
+	DecidedBy      string    `db:"decided_by"`
+	DecidedByKey   string    `db:"decided_by_key"`
+	// DecidedAt is nil while the approval is pending.
+	DecidedAt *time.Time `db:"decided_at"`
+	Comment   string     `db:"comment"`
+}
+
+type ApprovalFilter struct {
+	Kind   string
+	Status string
+	// StartDate and EndDate select an inclusive range of dates, either
+	// bound may be left zero.
+	StartDate time.Time
+	EndDate   time.Time
+	// Limit caps the number of approvals returned, 0 for no limit.
+	Limit int
+}
+
+type ApprovalStore interface {
+	CreateApprovalTables() error
+
+	// CreateApproval stores a pending approval requested now and returns
+	// its id.
+	CreateApproval(ctx context.Context, approval Approval) (string, error)
+	// GetApprovals returns the approvals within filter, oldest request
+	// first.
+	GetApprovals(ctx context.Context, filter ApprovalFilter) ([]Approval, error)
+	// GetApproval returns ErrNotFound for unknown ids.
+	GetApproval(ctx context.Context, id string) (Approval, error)
+	// DecideApproval records the status, decider and comment of approval,
+	// decided now. It returns ErrNotFound unless the approval is pending.
+	DecideApproval(ctx context.Context, approval Approval) error
+}
+
+// QuarantinedRate is a row of a feed that failed a data quality rule and
+// was not stored, or, for rules about what a feed lacks, a report with an
+// empty Rate.
+type QuarantinedRate struct {
+	ID    string `db:"id"`
+	Base  string `db:"base"`
+	Quote string `db:"quote"`
+	// Rate is the value as the feed gave it, which may not be a number.
+	Rate       string    `db:"rate"`
+	Date       time.Time `db:"published_date"`
+	Rule       string    `db:"rule"`
+	Detail     string    `db:"detail"`
+	DetectedAt time.Time `db:"detected_at"`
+}
+
+type QuarantineFilter struct {
+	// StartDate and EndDate select an inclusive range of dates, either
+	// bound may be left zero.
+	StartDate time.Time
+	EndDate   time.Time
+	Rule      string
+	// Limit caps the number of rates returned, 0 for no limit.
+	Limit int
+}
+
+type QuarantineStore interface {
+	CreateQuarantineTables() error
+
+	// QuarantineRates stores rates, detected now, skipping those already
+	// quarantined with the same base, quote, date, rule and value, so that
+	// a feed ingested again is not reported twice.
+	QuarantineRates(ctx context.Context, rates []QuarantinedRate) error
+	// GetQuarantinedRates returns the rates within filter, most recently
+	// detected first.
+	GetQuarantinedRates(ctx context.Context, filter QuarantineFilter) ([]QuarantinedRate, error)
+}
+
+// HealthStore reports whether the database can serve requests.
+type HealthStore interface {
+	Ping(ctx context.Context) error
+	// CheckSchema returns an error naming the tables that do not exist.
+	CheckSchema(ctx context.Context) error
+}
+
+type UsageStore interface {
+	CreateUsageTables() error
+
+	// IncrementUsage adds each count to its hourly bucket.
+	IncrementUsage(ctx context.Context, counts []UsageCount) error
+	// GetUsage returns the requests per key and endpoint within filter,
+	// summed over all hours. Hour is left zero.
+	GetUsage(ctx context.Context, filter UsageFilter) ([]UsageCount, error)
+}
+
+// Backend is a database holding every table of the service, so that a
+// single value serves all the stores.
+type Backend interface {
+	RakutenStore
+	AlertStore
+	APIKeyStore
+	UsageStore
+	IngestionStore
+	OverrideStore
+	ApprovalStore
+	QuarantineStore
+	HealthStore
+
+	// Migrate creates every table that does not exist yet.
+	Migrate() error
 }
 
+var ErrNotFound = errors.New("not found")
+
 var (
-	_ RakutenStore = (*Storage)(nil)
+	_ Backend         = (*Storage)(nil)
+	_ RakutenStore    = (*Storage)(nil)
+	_ AlertStore      = (*Storage)(nil)
+	_ APIKeyStore     = (*Storage)(nil)
+	_ UsageStore      = (*Storage)(nil)
+	_ IngestionStore  = (*Storage)(nil)
+	_ OverrideStore   = (*Storage)(nil)
+	_ ApprovalStore   = (*Storage)(nil)
+	_ QuarantineStore = (*Storage)(nil)
 )
 
 type Storage struct {
 	db *sqlx.DB
+
+	// Logger receives a debug line per query. It defaults to the global
+	// logger.
+	Logger *zap.Logger
+}
+
+var tracer = otel.Tracer("github.com/syahnur197/rakuten/storage")
+
+// startQuery starts a client span around the query run by method. The
+// returned function ends the span and logs the query with its outcome.
+func (s *Storage) startQuery(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+
+	return ctx, func(err error) {
+		tracing.End(span, err)
+
+		fields := []zap.Field{zap.String("method", method), zap.Duration("duration", time.Since(start))}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		logging.With(ctx, s.Logger).Debug("query", fields...)
+	}
 }
 
 func NewStorage(db *sqlx.DB) *Storage {
@@ -0,0 +1,425 @@
+// Package storagetest provides a conformance suite for implementations of
+// storage.RakutenStore, so that every backend behaves as the Postgres one.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+// Factory returns an empty store for a single test. It may call t.Skip
+// when the backend is unavailable, and should release the store with
+// t.Cleanup.
+type Factory func(t *testing.T) storage.RakutenStore
+
+// Run runs every conformance test against stores from newStore, each on a
+// fresh store. The tests do not run in parallel, so newStore may return
+// the same database emptied.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.RakutenStore)
+	}{
+		{"EmptyStore", testEmptyStore},
+		{"CreateCurrencyRate", testCreateCurrencyRate},
+		{"CreateCurrencyRates", testCreateCurrencyRates},
+		{"CreateCurrencyRatesAtomic", testCreateCurrencyRatesAtomic},
+		{"LatestDate", testLatestDate},
+		{"ExactDate", testExactDate},
+		{"DateRange", testDateRange},
+		{"Quotes", testQuotes},
+		{"Formatting", testFormatting},
+		{"Analysis", testAnalysis},
+		{"Revisions", testRevisions},
+		{"Concurrency", testConcurrency},
+		{"ConcurrentRevisions", testConcurrentRevisions},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			if err := s.CreateCurrencyRatesTable(); err != nil {
+				t.Fatalf("CreateCurrencyRatesTable: %v", err)
+			}
+			tt.test(t, s)
+		})
+	}
+}
+
+// Day returns the UTC midnight of 2023-01-d, the dates the suite stores.
+func Day(d int) time.Time {
+	return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func create(t *testing.T, s storage.RakutenStore, rates ...storage.Rate) {
+	t.Helper()
+	for _, rate := range rates {
+		if rate.Base == "" {
+			rate.Base = "EUR"
+		}
+		if _, err := s.CreateCurrencyRate(context.Background(), rate); err != nil {
+			t.Fatalf("CreateCurrencyRate(%+v): %v", rate, err)
+		}
+	}
+}
+
+func get(t *testing.T, s storage.RakutenStore, filter storage.CurrencyFilter) []storage.Rate {
+	t.Helper()
+	rates, err := s.GetCurrencyRates(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetCurrencyRates(%+v): %v", filter, err)
+	}
+	return rates
+}
+
+func analyze(t *testing.T, s storage.RakutenStore, filter storage.AnalysisFilter) []storage.AnalyzedRate {
+	t.Helper()
+	rates, err := s.GetAnalyzedCurrencyRates(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetAnalyzedCurrencyRates(%+v): %v", filter, err)
+	}
+	return rates
+}
+
+// format renders rates as "date quote rate" lines for comparison, dates are
+// compared by day since drivers return them in different locations.
+func format(rates []storage.Rate) []string {
+	lines := make([]string, 0, len(rates))
+	for _, rate := range rates {
+		lines = append(lines, fmt.Sprintf("%s %s/%s %s", rate.Date.Format("2006-01-02"), rate.Base, rate.Quote, rate.Rate))
+	}
+	return lines
+}
+
+func expectRates(t *testing.T, got []storage.Rate, want ...string) {
+	t.Helper()
+	lines := format(got)
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("got rates\n\t%q\nwant\n\t%q", lines, want)
+	}
+}
+
+func testEmptyStore(t *testing.T, s storage.RakutenStore) {
+	expectRates(t, get(t, s, storage.CurrencyFilter{GetLatestDate: true}))
+	expectRates(t, get(t, s, storage.CurrencyFilter{Date: Day(5)}))
+	expectRates(t, get(t, s, storage.CurrencyFilter{}))
+
+	if analyzed := analyze(t, s, storage.AnalysisFilter{}); len(analyzed) != 0 {
+		t.Errorf("got analysis %+v for an empty store", analyzed)
+	}
+}
+
+func testCreateCurrencyRate(t *testing.T, s storage.RakutenStore) {
+	ctx := context.Background()
+
+	ids := map[string]bool{}
+	for _, quote := range []string{"USD", "JPY"} {
+		id, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: quote, Rate: "1.1", Date: Day(5)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == "" || ids[id] {
+			t.Fatalf("got id %q, want a new one", id)
+		}
+		ids[id] = true
+	}
+
+	if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "GBP", Rate: "abc", Date: Day(5)}); err == nil {
+		t.Error("expected an error for a non numeric rate")
+	}
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{}),
+		"2023-01-05 EUR/JPY 1.1",
+		"2023-01-05 EUR/USD 1.1",
+	)
+}
+
+func testCreateCurrencyRates(t *testing.T, s storage.RakutenStore) {
+	ctx := context.Background()
+
+	if err := s.CreateCurrencyRates(ctx, nil); err != nil {
+		t.Fatalf("CreateCurrencyRates of no rates: %v", err)
+	}
+
+	// enough rows for backends that insert in chunks to need several
+	const days, quotes = 30, 40
+	var rates []storage.Rate
+	for d := 1; d <= days; d++ {
+		for q := 0; q < quotes; q++ {
+			rates = append(rates, storage.Rate{Base: "EUR", Quote: fmt.Sprintf("Q%02d", q), Rate: fmt.Sprintf("%d.50", q+1), Date: Day(d)})
+		}
+	}
+	if err := s.CreateCurrencyRates(ctx, rates); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := get(t, s, storage.CurrencyFilter{}); len(got) != days*quotes {
+		t.Fatalf("got %d rates, want %d", len(got), days*quotes)
+	}
+	latest := get(t, s, storage.CurrencyFilter{GetLatestDate: true, Quotes: []string{"Q00", "Q39"}})
+	expectRates(t, latest,
+		"2023-01-30 EUR/Q00 1.5",
+		"2023-01-30 EUR/Q39 40.5",
+	)
+}
+
+func testCreateCurrencyRatesAtomic(t *testing.T, s storage.RakutenStore) {
+	create(t, s, storage.Rate{Quote: "USD", Rate: "1.05", Date: Day(4)})
+
+	err := s.CreateCurrencyRates(context.Background(), []storage.Rate{
+		{Base: "EUR", Quote: "USD", Rate: "1.1", Date: Day(5)},
+		{Base: "EUR", Quote: "JPY", Rate: "140", Date: Day(5)},
+		{Base: "EUR", Quote: "GBP", Rate: "abc", Date: Day(5)},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a non numeric rate")
+	}
+
+	// none of the batch may be visible
+	expectRates(t, get(t, s, storage.CurrencyFilter{}), "2023-01-04 EUR/USD 1.05")
+}
+
+func testLatestDate(t *testing.T, s storage.RakutenStore) {
+	create(t, s,
+		storage.Rate{Quote: "USD", Rate: "1.05", Date: Day(4)},
+		storage.Rate{Quote: "USD", Rate: "1.1", Date: Day(5)},
+		storage.Rate{Quote: "JPY", Rate: "140", Date: Day(5)},
+		storage.Rate{Quote: "USD", Rate: "1", Date: Day(2)},
+	)
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{GetLatestDate: true}),
+		"2023-01-05 EUR/JPY 140",
+		"2023-01-05 EUR/USD 1.1",
+	)
+
+	// an exact date wins over the latest one
+	expectRates(t, get(t, s, storage.CurrencyFilter{GetLatestDate: true, Date: Day(2)}),
+		"2023-01-02 EUR/USD 1",
+	)
+}
+
+func testExactDate(t *testing.T, s storage.RakutenStore) {
+	create(t, s,
+		storage.Rate{Quote: "USD", Rate: "1.05", Date: Day(4)},
+		storage.Rate{Quote: "USD", Rate: "1.1", Date: Day(5)},
+	)
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{Date: Day(4)}), "2023-01-04 EUR/USD 1.05")
+	expectRates(t, get(t, s, storage.CurrencyFilter{Date: Day(3)}))
+}
+
+func testDateRange(t *testing.T, s storage.RakutenStore) {
+	for d := 2; d <= 6; d++ {
+		create(t, s, storage.Rate{Quote: "USD", Rate: fmt.Sprintf("1.%d", d), Date: Day(d)})
+	}
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{StartDate: Day(3), EndDate: Day(5)}),
+		"2023-01-03 EUR/USD 1.3",
+		"2023-01-04 EUR/USD 1.4",
+		"2023-01-05 EUR/USD 1.5",
+	)
+	expectRates(t, get(t, s, storage.CurrencyFilter{StartDate: Day(6)}), "2023-01-06 EUR/USD 1.6")
+	expectRates(t, get(t, s, storage.CurrencyFilter{EndDate: Day(2)}), "2023-01-02 EUR/USD 1.2")
+	expectRates(t, get(t, s, storage.CurrencyFilter{StartDate: Day(7)}))
+}
+
+func testQuotes(t *testing.T, s storage.RakutenStore) {
+	create(t, s,
+		storage.Rate{Quote: "USD", Rate: "1.1", Date: Day(5)},
+		storage.Rate{Quote: "JPY", Rate: "140", Date: Day(5)},
+		storage.Rate{Quote: "GBP", Rate: "0.88", Date: Day(5)},
+	)
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{GetLatestDate: true, Quotes: []string{"USD", "GBP"}}),
+		"2023-01-05 EUR/GBP 0.88",
+		"2023-01-05 EUR/USD 1.1",
+	)
+	expectRates(t, get(t, s, storage.CurrencyFilter{Quotes: []string{"CHF"}}))
+}
+
+func testFormatting(t *testing.T, s storage.RakutenStore) {
+	create(t, s,
+		storage.Rate{Quote: "AAA", Rate: "100", Date: Day(5)},
+		storage.Rate{Quote: "BBB", Rate: "1.50", Date: Day(5)},
+		storage.Rate{Quote: "CCC", Rate: "139.2800", Date: Day(5)},
+		storage.Rate{Quote: "DDD", Rate: "0.0001", Date: Day(5)},
+		storage.Rate{Quote: "EEE", Rate: "1.23456789016", Date: Day(5)},
+		storage.Rate{Quote: "FFF", Rate: "15000.0", Date: Day(5)},
+	)
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{Date: Day(5)}),
+		"2023-01-05 EUR/AAA 100",
+		"2023-01-05 EUR/BBB 1.5",
+		"2023-01-05 EUR/CCC 139.28",
+		"2023-01-05 EUR/DDD 0.0001",
+		"2023-01-05 EUR/EEE 1.2345678902",
+		"2023-01-05 EUR/FFF 15000",
+	)
+}
+
+func testAnalysis(t *testing.T, s storage.RakutenStore) {
+	create(t, s,
+		storage.Rate{Quote: "USD", Rate: "1.1", Date: Day(2)},
+		storage.Rate{Quote: "USD", Rate: "1.3", Date: Day(3)},
+		storage.Rate{Quote: "USD", Rate: "1.2", Date: Day(4)},
+		storage.Rate{Quote: "JPY", Rate: "100", Date: Day(3)},
+		storage.Rate{Quote: "JPY", Rate: "200.5", Date: Day(4)},
+	)
+
+	expect := func(filter storage.AnalysisFilter, want ...storage.AnalyzedRate) {
+		t.Helper()
+		got := analyze(t, s, filter)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("analysis of %+v: got %+v, want %+v", filter, got, want)
+		}
+	}
+
+	expect(storage.AnalysisFilter{},
+		storage.AnalyzedRate{Base: "EUR", Quote: "JPY", Min: "100", Max: "200.5", Avg: "150.25"},
+		storage.AnalyzedRate{Base: "EUR", Quote: "USD", Min: "1.1", Max: "1.3", Avg: "1.2"},
+	)
+	expect(storage.AnalysisFilter{StartDate: Day(3), EndDate: Day(3)},
+		storage.AnalyzedRate{Base: "EUR", Quote: "JPY", Min: "100", Max: "100", Avg: "100"},
+		storage.AnalyzedRate{Base: "EUR", Quote: "USD", Min: "1.3", Max: "1.3", Avg: "1.3"},
+	)
+	expect(storage.AnalysisFilter{StartDate: Day(4), Quotes: []string{"USD"}},
+		storage.AnalyzedRate{Base: "EUR", Quote: "USD", Min: "1.2", Max: "1.2", Avg: "1.2"},
+	)
+	expect(storage.AnalysisFilter{StartDate: Day(5)})
+}
+
+// tick returns the current time between two pauses, so that writes before
+// and after it are recorded at different times whatever the precision of
+// the backend.
+func tick() time.Time {
+	time.Sleep(10 * time.Millisecond)
+	defer time.Sleep(10 * time.Millisecond)
+	return time.Now()
+}
+
+func testRevisions(t *testing.T, s storage.RakutenStore) {
+	ctx := context.Background()
+
+	beforeAll := tick()
+	create(t, s,
+		storage.Rate{Quote: "USD", Rate: "1.05", Date: Day(4)},
+		storage.Rate{Quote: "USD", Rate: "1.1", Date: Day(5)},
+	)
+
+	original := tick()
+	err := s.CreateCurrencyRates(ctx, []storage.Rate{
+		{Base: "EUR", Quote: "USD", Rate: "1.12", Date: Day(5)},
+		{Base: "EUR", Quote: "JPY", Rate: "140", Date: Day(5)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := tick()
+	create(t, s, storage.Rate{Quote: "USD", Rate: "1.06", Date: Day(4)})
+
+	// only the latest version of each rate is current
+	expectRates(t, get(t, s, storage.CurrencyFilter{}),
+		"2023-01-04 EUR/USD 1.06",
+		"2023-01-05 EUR/JPY 140",
+		"2023-01-05 EUR/USD 1.12",
+	)
+
+	expectRates(t, get(t, s, storage.CurrencyFilter{AsOf: original}),
+		"2023-01-04 EUR/USD 1.05",
+		"2023-01-05 EUR/USD 1.1",
+	)
+	expectRates(t, get(t, s, storage.CurrencyFilter{AsOf: batch, StartDate: Day(4), EndDate: Day(4)}),
+		"2023-01-04 EUR/USD 1.05",
+	)
+	expectRates(t, get(t, s, storage.CurrencyFilter{AsOf: original, GetLatestDate: true}),
+		"2023-01-05 EUR/USD 1.1",
+	)
+	expectRates(t, get(t, s, storage.CurrencyFilter{AsOf: batch, Date: Day(5)}),
+		"2023-01-05 EUR/JPY 140",
+		"2023-01-05 EUR/USD 1.12",
+	)
+	expectRates(t, get(t, s, storage.CurrencyFilter{AsOf: beforeAll, GetLatestDate: true}))
+
+	got := analyze(t, s, storage.AnalysisFilter{AsOf: original})
+	want := []storage.AnalyzedRate{{Base: "EUR", Quote: "USD", Min: "1.05", Max: "1.1", Avg: "1.075"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("analysis as of the original rates: got %+v, want %+v", got, want)
+	}
+}
+
+func testConcurrency(t *testing.T, s storage.RakutenStore) {
+	const writers, ratesPerWriter = 8, 5
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ratesPerWriter; i++ {
+				rate := storage.Rate{Base: "EUR", Quote: fmt.Sprintf("Q%02d", w), Rate: "1.1", Date: Day(i + 1)}
+				if _, err := s.CreateCurrencyRate(ctx, rate); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			if _, err := s.GetCurrencyRates(ctx, storage.CurrencyFilter{GetLatestDate: true}); err != nil {
+				t.Error(err)
+			}
+			if _, err := s.GetAnalyzedCurrencyRates(ctx, storage.AnalysisFilter{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if rates := get(t, s, storage.CurrencyFilter{}); len(rates) != writers*ratesPerWriter {
+		t.Errorf("got %d rates, want %d", len(rates), writers*ratesPerWriter)
+	}
+	if rates := get(t, s, storage.CurrencyFilter{GetLatestDate: true}); len(rates) != writers {
+		t.Errorf("got %d latest rates, want %d", len(rates), writers)
+	}
+}
+
+// testConcurrentRevisions writes the same rates at once, as a scheduled
+// ingestion and a manual one may, which must leave a single current
+// version of each.
+func testConcurrentRevisions(t *testing.T, s storage.RakutenStore) {
+	const writers = 8
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			err := s.CreateCurrencyRates(ctx, []storage.Rate{
+				{Base: "EUR", Quote: "USD", Rate: fmt.Sprintf("1.1%d", w), Date: Day(5)},
+				{Base: "EUR", Quote: "JPY", Rate: fmt.Sprintf("14%d", w), Date: Day(5)},
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			if _, err := s.CreateCurrencyRate(ctx, storage.Rate{Base: "EUR", Quote: "USD", Rate: fmt.Sprintf("1.2%d", w), Date: Day(5)}); err != nil {
+				t.Error(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if rates := get(t, s, storage.CurrencyFilter{Date: Day(5)}); len(rates) != 2 {
+		t.Errorf("got current rates %v, want one per quote", format(rates))
+	}
+}
@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/syahnur197/rakuten/response"
+)
+
+type Config struct {
+	// Endpoint is the URL of an OTLP/HTTP collector, e.g.
+	// "http://localhost:4318", to which /v1/traces is appended as for
+	// OTEL_EXPORTER_OTLP_ENDPOINT. A bare host:port is exported to over
+	// HTTPS unless Insecure is set. Tracing is disabled when it is empty.
+	Endpoint string
+	Insecure bool
+
+	ServiceName string
+}
+
+// Setup installs the global tracer provider and the W3C trace-context
+// propagator. When cfg.Endpoint is empty spans are not exported, but
+// incoming trace context is still propagated to outgoing requests. The
+// returned function flushes and stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, err := parseEndpoint(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.host), otlptracehttp.WithURLPath(endpoint.path)}
+	if cfg.Insecure || endpoint.insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// tracesPath is where OTLP/HTTP collectors receive traces.
+const tracesPath = "/v1/traces"
+
+type endpoint struct {
+	host     string
+	path     string
+	insecure bool
+}
+
+// parseEndpoint splits an OTLP endpoint URL into the options of the
+// exporter, which of this version only takes a host:port and a path.
+func parseEndpoint(value string) (endpoint, error) {
+	if !strings.Contains(value, "://") {
+		return endpoint{host: value, path: tracesPath}, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return endpoint{}, errors.Wrap(err, "invalid tracing endpoint")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return endpoint{}, errors.Errorf("invalid tracing endpoint %q, must be an http or https URL", value)
+	}
+	return endpoint{
+		host:     u.Host,
+		path:     strings.TrimSuffix(u.Path, "/") + tracesPath,
+		insecure: u.Scheme == "http",
+	}, nil
+}
+
+// Middleware starts a server span named after route for every request to
+// next, continuing the trace of the caller if it sent a traceparent header.
+func Middleware(route string, next http.HandlerFunc) http.HandlerFunc {
+	tracer := otel.Tracer("github.com/syahnur197/rakuten/router")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPRouteKey.String(route),
+				semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+			),
+		)
+		defer span.End()
+
+		rec := response.NewRecorder(w)
+		next(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rec.Status()))
+		if rec.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status()))
+		}
+	}
+}
+
+// Transport wraps base so that outgoing requests carry the trace context of
+// their request context.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper{base: base}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	otel.GetTextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+	return t.base.RoundTrip(r)
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	var inner trace.SpanContext
+	handler := Middleware("/rates/{date}", func(w http.ResponseWriter, r *http.Request) {
+		inner = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/rates/2023-01-02", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "GET /rates/{date}" {
+		t.Errorf("got span name %q", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("got span kind %v, want server", span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceID {
+		t.Errorf("got trace id %s, want %s", span.SpanContext().TraceID(), traceID)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("got status %v, want error", span.Status().Code)
+	}
+	if inner.SpanID() != span.SpanContext().SpanID() {
+		t.Error("handler context does not carry the server span")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	setupRecorder(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: Transport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("got traceparent %q, want %q", traceparent, want)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Error("Transport modified the caller's request")
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "query")
+	End(span, errors.New("connection refused"))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error || spans[0].Status().Description != "connection refused" {
+		t.Errorf("got status %+v", spans[0].Status())
+	}
+	if len(spans[0].Events()) != 1 {
+		t.Errorf("got %d events, want the recorded error", len(spans[0].Events()))
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		value string
+		want  endpoint
+	}{
+		{"localhost:4318", endpoint{host: "localhost:4318", path: "/v1/traces"}},
+		{"http://otel-collector:4318", endpoint{host: "otel-collector:4318", path: "/v1/traces", insecure: true}},
+		{"https://collector.example.com/otlp/", endpoint{host: "collector.example.com", path: "/otlp/v1/traces"}},
+	}
+	for _, tt := range tests {
+		got, err := parseEndpoint(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %+v, %v, want %+v", tt.value, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseEndpoint("grpc://collector:4317"); err == nil {
+		t.Error("expected an error for a non-HTTP endpoint")
+	}
+}
@@ -0,0 +1,251 @@
+package usage
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/logging"
+	"github.com/syahnur197/rakuten/storage"
+)
+
+const (
+	DefaultFlushInterval = time.Minute
+
+	QuotaLimitHeader   = "X-Quota-Limit"
+	QuotaUsedHeader    = "X-Quota-Used"
+	QuotaWarningHeader = "X-Quota-Warning"
+)
+
+// Meter counts requests per API key and endpoint. Counts are buffered in
+// memory and added to the hourly buckets in storage on every Flush, so a
+// request never waits on a write. Quotas are checked against the usage in
+// storage, which includes the requests of every process, plus the counts
+// this process has not flushed yet.
+type Meter struct {
+	Store storage.UsageStore
+	Auth  *auth.Service
+
+	// Logger defaults to the global logger.
+	Logger *zap.Logger
+
+	// flushMu runs one Flush at a time, the ticker of Run and reports may
+	// flush at once
+	flushMu  sync.Mutex
+	mu       sync.Mutex
+	pending  map[bucketKey]int64
+	flushing map[bucketKey]int64
+	flushes  int
+	stored   map[string]*storedUsage
+	now      func() time.Time
+}
+
+type bucketKey struct {
+	keyID    string
+	endpoint string
+	hour     time.Time
+}
+
+// storedUsage is the usage of a key this month read from storage, after
+// the given number of flushes.
+type storedUsage struct {
+	month    time.Time
+	requests int64
+	flushes  int
+	read     time.Time
+}
+
+func NewMeter(store storage.UsageStore, a *auth.Service) *Meter {
+	return &Meter{
+		Store:   store,
+		Auth:    a,
+		pending: map[bucketKey]int64{},
+		stored:  map[string]*storedUsage{},
+		now:     time.Now,
+	}
+}
+
+// Track counts requests to next under endpoint, a route name such as
+// "/rates/{date}" rather than the raw path. It must be wrapped by
+// auth.Service.Require so the calling key is known. Requests of keys over
+// their monthly quota are rejected with 429, and not counted, in block
+// mode, or flagged with a X-Quota-Warning header in warn mode.
+func (m *Meter) Track(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := auth.KeyFromContext(r.Context())
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		used, admitted, err := m.admit(r.Context(), key, endpoint)
+		if err != nil {
+			// metering must not take the API down with it
+			logging.With(r.Context(), m.Logger).Error("failed to read usage", zap.Error(err))
+			next(w, r)
+			return
+		}
+
+		if key.MonthlyQuota > 0 {
+			w.Header().Set(QuotaLimitHeader, strconv.Itoa(key.MonthlyQuota))
+			w.Header().Set(QuotaUsedHeader, strconv.FormatInt(used, 10))
+
+			if !admitted {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"message":"monthly quota exceeded"}`))
+				return
+			}
+			if used > int64(key.MonthlyQuota) {
+				w.Header().Set(QuotaWarningHeader, "monthly quota exceeded")
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// admit counts one request unless it exceeds the key's quota in block
+// mode, and returns the key's usage this month including the request if
+// it was counted. A request is counted even if the usage cannot be read.
+func (m *Meter) admit(ctx context.Context, key *auth.Key, endpoint string) (used int64, admitted bool, err error) {
+	now := m.now().UTC()
+	month := startOfMonth(now)
+	bucket := bucketKey{keyID: key.ID, endpoint: endpoint, hour: now.Truncate(time.Hour)}
+
+	var stored int64
+	if key.MonthlyQuota > 0 {
+		stored, err = m.storedUsage(ctx, key.ID, month, now)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key.MonthlyQuota <= 0 || err != nil {
+		m.pending[bucket]++
+		return 0, true, err
+	}
+
+	used = stored + m.unflushed(key.ID, month)
+	if used >= int64(key.MonthlyQuota) && key.QuotaMode != auth.QuotaWarn {
+		return used, false, nil
+	}
+	m.pending[bucket]++
+	return used + 1, true, nil
+}
+
+// storedUsage returns the requests of keyID since month in storage. It is
+// read again once this process has flushed since, or after
+// DefaultFlushInterval to catch up with other processes.
+func (m *Meter) storedUsage(ctx context.Context, keyID string, month, now time.Time) (int64, error) {
+	m.mu.Lock()
+	usage, ok := m.stored[keyID]
+	flushes := m.flushes
+	m.mu.Unlock()
+
+	if ok && usage.month.Equal(month) && usage.flushes == flushes && now.Sub(usage.read) < DefaultFlushInterval {
+		return usage.requests, nil
+	}
+
+	counts, err := m.Store.GetUsage(ctx, storage.UsageFilter{KeyID: keyID, From: month})
+	if err != nil {
+		return 0, err
+	}
+
+	var requests int64
+	for _, count := range counts {
+		requests += count.Requests
+	}
+
+	m.mu.Lock()
+	m.stored[keyID] = &storedUsage{month: month, requests: requests, flushes: flushes, read: now}
+	m.mu.Unlock()
+	return requests, nil
+}
+
+// unflushed returns the requests of keyID since month that are not in
+// storage yet. m.mu must be held.
+func (m *Meter) unflushed(keyID string, month time.Time) int64 {
+	var n int64
+	for _, counts := range []map[bucketKey]int64{m.pending, m.flushing} {
+		for b, count := range counts {
+			if b.keyID == keyID && !b.hour.Before(month) {
+				n += count
+			}
+		}
+	}
+	return n
+}
+
+// Flush writes the buffered counts to storage. Counts that fail to be
+// written are kept for the next Flush. Concurrent calls flush one after
+// the other.
+func (m *Meter) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = map[bucketKey]int64{}
+	// still counted against quotas until they are in storage
+	m.flushing = pending
+	m.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	counts := make([]storage.UsageCount, 0, len(pending))
+	for b, requests := range pending {
+		counts = append(counts, storage.UsageCount{
+			KeyID:    b.keyID,
+			Endpoint: b.endpoint,
+			Hour:     b.hour,
+			Requests: requests,
+		})
+	}
+
+	err := m.Store.IncrementUsage(ctx, counts)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushing = nil
+	if err != nil {
+		for b, requests := range pending {
+			m.pending[b] += requests
+		}
+		return err
+	}
+	// the stored usage read so far misses these counts
+	m.flushes++
+	return nil
+}
+
+// Run flushes every interval until ctx is done, then flushes once more.
+func (m *Meter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.Flush(context.Background()); err != nil {
+				logging.With(ctx, m.Logger).Error("failed to flush usage", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			if err := m.Flush(ctx); err != nil {
+				logging.With(ctx, m.Logger).Error("failed to flush usage", zap.Error(err))
+			}
+		}
+	}
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,144 @@
+package usage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/syahnur197/rakuten/auth"
+	"github.com/syahnur197/rakuten/storage"
+	"github.com/syahnur197/rakuten/storage/mock_storage"
+)
+
+func TestMeter_Track(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	now := time.Date(2023, 1, 5, 10, 30, 0, 0, time.UTC)
+	month := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	hour := time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC)
+
+	// the stored usage grows as other processes flush theirs
+	stored := []int64{1, 3, 5}
+	usageStore := mock_storage.NewMockUsageStore(ctrl)
+	usageStore.EXPECT().GetUsage(gAny, storage.UsageFilter{KeyID: "key-1", From: month}).DoAndReturn(func(context.Context, storage.UsageFilter) ([]storage.UsageCount, error) {
+		requests := stored[0]
+		stored = stored[1:]
+		return []storage.UsageCount{{KeyID: "key-1", Endpoint: "/rates/{date}", Requests: requests}}, nil
+	}).Times(3)
+	usageStore.EXPECT().IncrementUsage(gAny, []storage.UsageCount{
+		{KeyID: "key-1", Endpoint: "/rates/{date}", Hour: hour, Requests: 1},
+	}).Return(nil)
+
+	m := NewMeter(usageStore, nil)
+	m.now = func() time.Time { return now }
+
+	key := &auth.Key{ID: "key-1", MonthlyQuota: 2, QuotaMode: auth.QuotaBlock}
+
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/rates/latest", nil)
+		r = r.WithContext(auth.NewContext(r.Context(), key))
+		w := httptest.NewRecorder()
+		m.Track("/rates/{date}", func(w http.ResponseWriter, r *http.Request) {})(w, r)
+		return w
+	}
+
+	if w := serve(); w.Code != http.StatusOK || w.Header().Get(QuotaUsedHeader) != "2" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if w := serve(); w.Code != http.StatusTooManyRequests || w.Header().Get(QuotaUsedHeader) != "2" {
+		t.Fatalf("expected 429 over quota, got %d %v", w.Code, w.Header())
+	}
+
+	// only the admitted request is metered
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.pending) != 0 {
+		t.Fatal("expected pending counts to be flushed")
+	}
+
+	// read again after the flush, the usage includes another process's
+	key.QuotaMode = auth.QuotaWarn
+	if w := serve(); w.Code != http.StatusOK || w.Header().Get(QuotaUsedHeader) != "4" || w.Header().Get(QuotaWarningHeader) == "" {
+		t.Fatalf("expected warning over quota, got %d %v", w.Code, w.Header())
+	}
+	if w := serve(); w.Header().Get(QuotaUsedHeader) != "5" {
+		t.Fatalf("expected unflushed requests to count, got %v", w.Header())
+	}
+
+	// and again once it may be stale
+	now = now.Add(DefaultFlushInterval)
+	if w := serve(); w.Header().Get(QuotaUsedHeader) != "8" {
+		t.Fatalf("expected the stored usage to be read again, got %v", w.Header())
+	}
+}
+
+func TestMeter_Track_NoQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// keys without a quota never read their usage
+	usageStore := mock_storage.NewMockUsageStore(ctrl)
+
+	m := NewMeter(usageStore, nil)
+	r := httptest.NewRequest(http.MethodGet, "/rates/latest", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Key{ID: "key-1"}))
+	w := httptest.NewRecorder()
+	m.Track("/rates/{date}", func(w http.ResponseWriter, r *http.Request) {})(w, r)
+
+	if w.Header().Get(QuotaUsedHeader) != "" || len(m.pending) != 1 {
+		t.Fatalf("expected the request to be counted without quota headers, got %v %v", w.Header(), m.pending)
+	}
+}
+
+func TestMeter_Flush_Concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gAny := gomock.Any()
+
+	month := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := bucketKey{keyID: "key-1", endpoint: "/rates/{date}", hour: time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC)}
+
+	writing, release := make(chan struct{}), make(chan struct{})
+	usageStore := mock_storage.NewMockUsageStore(ctrl)
+	gomock.InOrder(
+		usageStore.EXPECT().IncrementUsage(gAny, gAny).DoAndReturn(func(context.Context, []storage.UsageCount) error {
+			close(writing)
+			<-release
+			return nil
+		}),
+		usageStore.EXPECT().IncrementUsage(gAny, gAny).Return(nil),
+	)
+
+	m := NewMeter(usageStore, nil)
+	m.pending[bucket] = 1
+
+	done := make(chan error, 2)
+	go func() { done <- m.Flush(context.Background()) }()
+	<-writing
+
+	// a report flushing while the ticker does waits for it, the counts in
+	// flight still count against the quota meanwhile
+	m.mu.Lock()
+	m.pending[bucket]++
+	m.mu.Unlock()
+	go func() { done <- m.Flush(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	m.mu.Lock()
+	unflushed := m.unflushed("key-1", month)
+	m.mu.Unlock()
+	if unflushed != 2 {
+		t.Errorf("got %d unflushed requests, want 2", unflushed)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
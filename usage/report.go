@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"github.com/syahnur197/rakuten/storage"
+)
+
+type Report struct {
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Clients []ClientReport `json:"clients"`
+}
+
+type ClientReport struct {
+	KeyID        string           `json:"key_id"`
+	Name         string           `json:"name"`
+	MonthlyQuota int              `json:"monthly_quota"`
+	QuotaMode    string           `json:"quota_mode,omitempty"`
+	Requests     int64            `json:"requests"`
+	Endpoints    map[string]int64 `json:"endpoints"`
+}
+
+// Report returns the flushed usage between from (inclusive) and to
+// (exclusive) per client and endpoint. Counts still buffered in memory are
+// flushed first.
+func (m *Meter) Report(ctx context.Context, from, to time.Time) (*Report, error) {
+	if err := m.Flush(ctx); err != nil {
+		return nil, err
+	}
+
+	counts, err := m.Store.GetUsage(ctx, storage.UsageFilter{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := m.Auth.GetKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{From: from, To: to, Clients: []ClientReport{}}
+	index := map[string]int{}
+
+	for _, key := range keys {
+		index[key.ID] = len(report.Clients)
+		report.Clients = append(report.Clients, ClientReport{
+			KeyID:        key.ID,
+			Name:         key.Name,
+			MonthlyQuota: key.MonthlyQuota,
+			QuotaMode:    key.QuotaMode,
+			Endpoints:    map[string]int64{},
+		})
+	}
+
+	for _, count := range counts {
+		i, ok := index[count.KeyID]
+		if !ok {
+			// e.g. the bootstrap admin key, which is not stored
+			i = len(report.Clients)
+			index[count.KeyID] = i
+			report.Clients = append(report.Clients, ClientReport{
+				KeyID:     count.KeyID,
+				Name:      count.KeyID,
+				Endpoints: map[string]int64{},
+			})
+		}
+
+		report.Clients[i].Requests += count.Requests
+		report.Clients[i].Endpoints[count.Endpoint] += count.Requests
+	}
+
+	return report, nil
+}
+
+// MonthRange returns the start of t's month and the start of the next one.
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	start := startOfMonth(t.UTC())
+	return start, start.AddDate(0, 1, 0)
+}